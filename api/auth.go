@@ -67,9 +67,30 @@ type TokenCredentials struct {
 type TokenPayload struct {
 	ID  ksuid.KSUID
 	UID ksuid.KSUID
+	// OID is the active organisation, nil means the personal one
+	OID       ksuid.KSUID
+	ExpiresAt time.Time
+}
+
+// TokenPair is what a bearer client gets at login and at every refresh
+type TokenPair struct {
+	Access    string    `json:"token_access"`
+	Refresh   string    `json:"token_refresh"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type TokenRefresh struct {
+	Refresh string `json:"token_refresh"`
 }
 
 type TokenService interface {
-	Create(context.Context, TokenCredentials) (string, error)
+	Create(context.Context, TokenCredentials) (*TokenPair, error)
 	Read(context.Context, string) (*TokenPayload, error)
+	// Refresh rotates a refresh token, a reused one revokes its whole family
+	Refresh(context.Context, string) (*TokenPair, error)
+	// Revoke kills the access token and the refresh tokens issued with it
+	Revoke(context.Context, *TokenPayload, string) error
+	IsRevoked(context.Context, ksuid.KSUID) (bool, error)
+	// Switch replaces the token with one acting for another organisation
+	Switch(context.Context, *TokenPayload, ksuid.KSUID) (*TokenPair, error)
 }
@@ -8,9 +8,12 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/innermond/dots"
 	"github.com/innermond/dots/http"
+	"github.com/innermond/dots/http/oidc"
 	"github.com/innermond/dots/postgres"
 	"github.com/joho/godotenv"
 )
@@ -43,6 +46,9 @@ func main() {
 
 	clientId := os.Getenv("DOTS_GOOGLE_CLIENT_ID")
 	clientSecret := os.Getenv("DOTS_GOOGLE_CLIENT_SECRET")
+	// github login is optional
+	githubClientId := os.Getenv("DOTS_GITHUB_CLIENT_ID")
+	githubClientSecret := os.Getenv("DOTS_GITHUB_CLIENT_SECRET")
 
 	tokenSecret := os.Getenv("DOTS_TOKEN_SECRET")
 	tokenTTL64, err := strconv.ParseUint(os.Getenv("DOTS_TOKEN_TTL"), 10, 64)
@@ -53,10 +59,15 @@ func main() {
 	log.Println("token ttl: ", tokenTTL)
 	tokenPrefix := os.Getenv("DOTS_TOKEN_PREFIX")
 
-	if clientId == "" || clientSecret == "" || tokenSecret == "" || tokenPrefix == "" {
+	if tokenSecret == "" || tokenPrefix == "" {
 		log.Fatal("app credentials are missing")
 	}
 
+	oauthProviders, err := oauthProviders(clientId, clientSecret)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	dsn := os.Getenv("DOTS_DSN")
 	db := postgres.NewDB(dsn)
 	if err := db.Open(); err != nil {
@@ -70,27 +81,68 @@ func main() {
 		log.Fatal(err)
 	}
 
-	server.ClientID = clientId
-	server.ClientSecret = clientSecret
+	server.OAuthProviders = oauthProviders
+	if base := os.Getenv("DOTS_OAUTH_REDIRECT_BASE"); base != "" {
+		server.OAuthRedirectBase = base
+	}
+	server.GithubClientID = githubClientId
+	server.GithubClientSecret = githubClientSecret
+	server.RealIPHeader = os.Getenv("DOTS_REAL_IP_HEADER")
+	if err := rateLimits(server.RateLimits); err != nil {
+		log.Fatal(err)
+	}
+	// many servers behind a balancer need to share their buckets
+	if os.Getenv("DOTS_RATE_LIMIT_STORE") == "postgres" {
+		server.RateLimiter = postgres.NewRateLimitService(db)
+	}
 
 	authService := postgres.NewAuthService(db)
 	userService := postgres.NewUserService(db)
 	tokenService := postgres.NewTokenService(db, tokenSecret, tokenPrefix, tokenTTL, userService)
+	passwordService := postgres.NewPasswordService(db)
+	personalTokenService := postgres.NewPersonalTokenService(db)
+	userAdminService := postgres.NewUserAdminService(db)
+	organisationService := postgres.NewOrganisationService(db)
+	planService := postgres.NewPlanService(db)
+	loginEventService := postgres.NewLoginEventService(db, dots.DefaultLockoutPolicy)
 
 	entryTypeService := postgres.NewEntryTypeService(db)
 	entryService := postgres.NewEntryService(db)
 	drainService := postgres.NewDrainService(db)
 	companyService := postgres.NewCompanyService(db)
 	deedService := postgres.NewDeedService(db)
+	valuationService := postgres.NewValuationService(db)
+	profitService := postgres.NewProfitService(db)
+	ledgerService := postgres.NewLedgerService(db)
+	stockThresholdService := postgres.NewStockThresholdService(db)
+	unitService := postgres.NewUnitService(db)
+	transferService := postgres.NewTransferService(db)
+	inventoryCountService := postgres.NewInventoryCountService(db)
+	reservationService := postgres.NewReservationService(db)
 
 	server.UserService = userService
 	server.AuthService = authService
 	server.TokenService = tokenService
+	server.PasswordService = passwordService
+	server.PasswordResetSender = logPasswordResetSender{}
+	server.PersonalTokenService = personalTokenService
+	server.UserAdminService = userAdminService
+	server.OrganisationService = organisationService
+	server.PlanService = planService
+	server.LoginEventService = loginEventService
 	server.EntryTypeService = entryTypeService
 	server.EntryService = entryService
 	server.DrainService = drainService
 	server.CompanyService = companyService
 	server.DeedService = deedService
+	server.ValuationService = valuationService
+	server.ProfitService = profitService
+	server.LedgerService = ledgerService
+	server.StockThresholdService = stockThresholdService
+	server.UnitService = unitService
+	server.TransferService = transferService
+	server.InventoryCountService = inventoryCountService
+	server.ReservationService = reservationService
 
 	ctx, cancel := context.WithCancel(context.Background())
 	c := make(chan os.Signal, 1)
@@ -118,3 +170,77 @@ func main() {
 		log.Printf("shutdown: %v\n", err)
 	}
 }
+
+// oauthProviders registers google, when configured, next to
+// the providers coming from env and from DOTS_OIDC_FILE
+func oauthProviders(googleClientId, googleClientSecret string) (*oidc.Registry, error) {
+	cc := oidc.FromEnv(os.Getenv)
+
+	if googleClientId != "" {
+		cc = append(cc, oidc.Config{
+			Name:         dots.AuthSourceGoogle,
+			Issuer:       "https://accounts.google.com",
+			ClientID:     googleClientId,
+			ClientSecret: googleClientSecret,
+		})
+	}
+
+	if f := os.Getenv("DOTS_OIDC_FILE"); f != "" {
+		fromFile, err := oidc.LoadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		cc = append(cc, fromFile...)
+	}
+
+	redirectBase := os.Getenv("DOTS_OAUTH_REDIRECT_BASE")
+	if redirectBase == "" {
+		redirectBase = "http://localhost:8080"
+	}
+
+	registry := oidc.NewRegistry(nil)
+	for _, c := range cc {
+		if c.RedirectBase == "" {
+			c.RedirectBase = redirectBase
+		}
+		if err := registry.Add(c); err != nil {
+			return nil, err
+		}
+	}
+	log.Println("oauth providers: ", registry.Names())
+
+	return registry, nil
+}
+
+// rateLimits overrides the limit of a route group from env as in
+// DOTS_RATE_LIMIT_AUTH=10/1m:20, "off" drops the limit of the group
+func rateLimits(ll map[string]dots.RateLimit) error {
+	for _, group := range []string{http.RateLimitAuth, http.RateLimitAPI, http.RateLimitDepletion} {
+		v := os.Getenv("DOTS_RATE_LIMIT_" + strings.ToUpper(group))
+		switch v {
+		case "":
+			continue
+		case "off":
+			delete(ll, group)
+			continue
+		}
+
+		l, err := dots.ParseRateLimit(v)
+		if err != nil {
+			return err
+		}
+		ll[group] = l
+	}
+	log.Println("rate limits: ", ll)
+
+	return nil
+}
+
+// logPasswordResetSender only logs that a reset was asked for
+// until a mailer is wired in; the token never reaches the log
+type logPasswordResetSender struct{}
+
+func (logPasswordResetSender) SendPasswordReset(ctx context.Context, prt *dots.PasswordResetToken) error {
+	log.Printf("password reset requested for user %s, no mailer to send it, expires at %s", prt.UserID, prt.ExpiresAt)
+	return nil
+}
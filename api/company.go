@@ -3,6 +3,7 @@ package dots
 import (
 	"context"
 	"strings"
+	"time"
 )
 
 type Company struct {
@@ -10,6 +11,8 @@ type Company struct {
 	Longname string `json:"longname"`
 	TIN      string `json:"tin"`
 	RN       string `json:"rn"`
+
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 func (c *Company) Validate() error {
@@ -43,6 +46,9 @@ type CompanyFilter struct {
 
 	DeletedAtFrom *PartialTime `json:"deleted_at_from,omitempty"`
 	DeletedAtTo   *PartialTime `json:"deleted_at_to,omitempty"`
+
+	// AsOf sets the time depletion looks at, now by default
+	AsOf *PartialTime `json:"as_of,omitempty"`
 }
 
 type CompanyDelete struct {
@@ -60,8 +66,12 @@ type CompanyService interface {
 	UpdateCompany(context.Context, int, CompanyUpdate) (*Company, error)
 	FindCompany(context.Context, CompanyFilter) ([]*Company, int, error)
 	DeleteCompany(context.Context, int, CompanyDelete) (int, error)
+	RestoreCompany(context.Context, TrashRestore) (int, error)
 	StatsCompany(context.Context, CompanyFilter) (*CompanyStats, error)
 	DepletionCompany(context.Context, CompanyFilter) ([]*CompanyDepletion, int, error)
+	ForecastCompany(context.Context, ForecastFilter) ([]*CompanyForecast, int, error)
+	FindCompanySetting(context.Context, int) (*CompanySetting, error)
+	UpdateCompanySetting(context.Context, int, CompanySettingUpdate) (*CompanySetting, error)
 }
 
 type CompanyUpdate struct {
@@ -106,10 +116,17 @@ type CompanyStats struct {
 	CountEntryTypes int `json:"count_entry_types"`
 }
 
+// CompanyDepletion is an entry type of a company below its reorder level
 type CompanyDepletion struct {
+	CompanyID       *int     `json:"company_id"`
 	EntryTypeID     *int     `json:"entry_type_id"`
 	Code            *string  `json:"code"`
 	Description     *string  `json:"description,omitempty"`
 	QuantityInitial *float64 `json:"quantity_initial"`
 	QuantityDrained *float64 `json:"quantity_drained"`
+	Quantity        float64  `json:"quantity"`
+	ReorderLevel    float64  `json:"reorder_level"`
+	TargetLevel     float64  `json:"target_level"`
+	// ReorderQuantity brings the stock back to the target level
+	ReorderQuantity float64 `json:"reorder_quantity"`
 }
@@ -0,0 +1,23 @@
+package dots
+
+// CompanySetting holds the choices a company makes once for all its documents
+type CompanySetting struct {
+	CompanyID int `json:"company_id"`
+	// DistributeStrategy is used when a distribution does not ask for one
+	DistributeStrategy *DistributeDrain `json:"distribute_strategy"`
+}
+
+type CompanySettingUpdate struct {
+	// DistributeStrategy set to an empty string goes back to the default of each document
+	DistributeStrategy *DistributeDrain `json:"distribute_strategy"`
+}
+
+func (u *CompanySettingUpdate) Validate() error {
+	if u.DistributeStrategy == nil {
+		return Errorf(EINVALID, "nothing to update")
+	}
+	if *u.DistributeStrategy == "" {
+		return nil
+	}
+	return u.DistributeStrategy.Validate()
+}
@@ -10,6 +10,8 @@ type key int
 const (
 	userContextKey = key(iota + 1)
 	flashContextKey
+	tokenContextKey
+	personalTokenContextKey
 )
 
 func NewContextWithUser(ctx context.Context, u *User) context.Context {
@@ -26,6 +28,28 @@ func UserFromContext(ctx context.Context) *User {
 	return u
 }
 
+// NewContextWithToken keeps the bearer token payload of the request
+func NewContextWithToken(ctx context.Context, p *TokenPayload) context.Context {
+	return context.WithValue(ctx, tokenContextKey, p)
+}
+
+// TokenFromContext returns nil for requests not using a bearer token
+func TokenFromContext(ctx context.Context) *TokenPayload {
+	p, _ := ctx.Value(tokenContextKey).(*TokenPayload)
+	return p
+}
+
+// NewContextWithPersonalToken marks the request as made with a personal token
+func NewContextWithPersonalToken(ctx context.Context) context.Context {
+	return context.WithValue(ctx, personalTokenContextKey, true)
+}
+
+// FromPersonalToken tells whether the request was made with a personal token
+func FromPersonalToken(ctx context.Context) bool {
+	v, _ := ctx.Value(personalTokenContextKey).(bool)
+	return v
+}
+
 type touristKey string
 
 const touristContextKey touristKey = "channelTouristKey"
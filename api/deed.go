@@ -2,6 +2,7 @@ package dots
 
 import (
 	"context"
+	"time"
 
 	"github.com/shopspring/decimal"
 )
@@ -9,8 +10,13 @@ import (
 type Deed struct {
 	ID *int `json:"id"`
 	DeedUpdate
+
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
+// DistributeDrain is the order entries get drained in when distributing over entry types.
+// The first word says what orders first, the second what breaks the ties
 type DistributeDrain string
 
 const (
@@ -18,10 +24,40 @@ const (
 	DistributeNewFew  DistributeDrain = "new_few"
 	DistributeOldMany DistributeDrain = "old_many"
 	DistributeOldFew  DistributeDrain = "old_few"
+	DistributeManyNew DistributeDrain = "many_new"
+	DistributeFewNew  DistributeDrain = "few_new"
+	DistributeManyOld DistributeDrain = "many_old"
+	DistributeFewOld  DistributeDrain = "few_old"
+	// DistributeFefo takes first the entries that expire first, the ones that never expire last
+	DistributeFefo DistributeDrain = "fefo"
+	// DistributeLargestFirst takes first the entries with most left, the ones expiring first on ties
+	DistributeLargestFirst DistributeDrain = "largest_first"
+	// DistributeExactFit touches the fewest entries, the one drained in part
+	// being the smallest that still covers what is left to take
+	DistributeExactFit DistributeDrain = "exact_fit"
 )
 
+var distributeDrains = []DistributeDrain{
+	DistributeNewMany, DistributeNewFew, DistributeOldMany, DistributeOldFew,
+	DistributeManyNew, DistributeFewNew, DistributeManyOld, DistributeFewOld,
+	DistributeFefo, DistributeLargestFirst, DistributeExactFit,
+}
+
+func (s DistributeDrain) Validate() error {
+	for _, known := range distributeDrains {
+		if s == known {
+			return nil
+		}
+	}
+	known := make([]string, len(distributeDrains))
+	for i, s := range distributeDrains {
+		known[i] = string(s)
+	}
+	return Errorf(EINVALID, "unknown distribute strategy %q", s).WithData(map[string]interface{}{"known": known})
+}
+
 func (d *Deed) Validate() error {
-	return nil
+	return d.DeedUpdate.validStrategy()
 }
 
 type DeedService interface {
@@ -29,6 +65,9 @@ type DeedService interface {
 	UpdateDeed(context.Context, int, DeedUpdate) (*Deed, error)
 	FindDeed(context.Context, DeedFilter) ([]*Deed, int, error)
 	DeleteDeed(context.Context, int, DeedDelete) (int, error)
+	RestoreDeed(context.Context, TrashRestore) (int, error)
+	// PreviewDistribute tells what a deed would drain, nothing is kept
+	PreviewDistribute(context.Context, DistributePreview) (*DistributePreviewed, error)
 }
 
 type DeedFilter struct {
@@ -42,6 +81,8 @@ type DeedFilter struct {
 	Offset int `json:"offset"`
 	Limit  int `json:"limit"`
 
+	IsDeleted *bool `json:"is_deleted"`
+
 	DeletedAtFrom *PartialTime `json:"deleted_at_from,omitempty"`
 	DeletedAtTo   *PartialTime `json:"deleted_at_to,omitempty"`
 }
@@ -62,6 +103,9 @@ type DeedUpdate struct {
 
 	EntryTypeDistribute map[int]float64  `json:"entry_type_distribute,omitempty"`
 	DistributeStrategy  *DistributeDrain `json:"distribute_strategy,omitempty"`
+	// DistributeUnit is the one distributed quantities are given in,
+	// without it they are in the units of their entry types
+	DistributeUnit *string `json:"distribute_unit,omitempty"`
 }
 
 // TODO it panics violently!!!
@@ -74,6 +118,13 @@ type DeedUpdate struct {
 	return
 }*/
 
+func (du *DeedUpdate) validStrategy() error {
+	if du.DistributeStrategy == nil {
+		return nil
+	}
+	return du.DistributeStrategy.Validate()
+}
+
 func (du *DeedUpdate) Valid() error {
 	if du.Title == nil && du.Quantity == nil && du.Unit == nil {
 		return Errorf(EINVALID, "at least title, quantity and unit are required")
@@ -81,3 +132,36 @@ func (du *DeedUpdate) Valid() error {
 
 	return nil
 }
+
+// DistributePreview asks how a deed would distribute over entry types
+type DistributePreview struct {
+	CompanyID           *int             `json:"company_id"`
+	EntryTypeDistribute map[int]float64  `json:"entry_type_distribute"`
+	DistributeStrategy  *DistributeDrain `json:"distribute_strategy,omitempty"`
+}
+
+func (p *DistributePreview) Validate() error {
+	if p.CompanyID == nil || len(p.EntryTypeDistribute) == 0 {
+		return Errorf(EINVALID, "company and entry type distribute are required")
+	}
+	for etid, qty := range p.EntryTypeDistribute {
+		if qty <= 0 {
+			return Errorf(EINVALID, "quantity for entry type %d must be greater than zero", etid)
+		}
+	}
+	if p.DistributeStrategy != nil {
+		return p.DistributeStrategy.Validate()
+	}
+	return nil
+}
+
+type DistributePreviewed struct {
+	// Strategy is the one used, asked for or the company default
+	Strategy DistributeDrain `json:"strategy"`
+	// Distribute is the quantity each entry would give
+	Distribute map[int]float64 `json:"distribute"`
+	// Remaining is what each of those entries would keep
+	Remaining map[int]float64 `json:"remaining"`
+	// NeedMore is what each entry type lacks, nothing is distributed while it has any
+	NeedMore map[int]float64 `json:"needmore,omitempty"`
+}
@@ -2,6 +2,7 @@ package dots
 
 import (
 	"context"
+	"time"
 
 	"github.com/segmentio/ksuid"
 )
@@ -11,6 +12,11 @@ type Drain struct {
 	EntryID   int     `json:"entry_id"`
 	Quantity  float64 `json:"quantity"`
 	IsDeleted bool    `json:"is_deleted"`
+	// Unit is the one Quantity is given in when not the unit of the entry type
+	Unit *string `json:"unit,omitempty"`
+
+	DrainedAt *time.Time `json:"drained_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 func (d *Drain) Validate() error {
@@ -19,32 +25,42 @@ func (d *Drain) Validate() error {
 
 type DrainService interface {
 	CreateOrUpdateDrain(context.Context, Drain) error
-	//UpdateDrain(context.Context, int, *Drain) (*Drain, error)
-	//FindDrain(context.Context, *DrainFilter) ([]*Drain, int, error)
+	FindDrain(context.Context, DrainFilter) ([]*Drain, int, error)
+	// UpdateDrain changes the quantity a deed takes from an entry
+	UpdateDrain(context.Context, int, int, DrainUpdate) (*Drain, error)
+	DeleteDrain(context.Context, int, int, DrainDelete) (int, error)
 }
 
 type DrainFilter struct {
-	DeedID   *int     `json:"deed_id"`
-	EntryID  *int     `json:"entry_id"`
-	Quantity *float64 `json:"quantity"`
+	DeedID      *int     `json:"deed_id"`
+	EntryID     *int     `json:"entry_id"`
+	EntryTypeID *int     `json:"entry_type_id"`
+	CompanyID   *int     `json:"company_id"`
+	Quantity    *float64 `json:"quantity"`
 
-	IsDeleted *bool `json:"is_deleted"`
-	TID       *ksuid.KSUID
+	IsDeleted *bool        `json:"is_deleted"`
+	TID       *ksuid.KSUID `json:"-"`
 
 	Offset int `json:"offset"`
 	Limit  int `json:"limit"`
 }
 
+// DrainUpdate fixes a single consumption, deed and entry stay the same
 type DrainUpdate struct {
-	DeedID   *int     `json:"deed_id"`
-	EntryID  *int     `json:"entry_id"`
 	Quantity *float64 `json:"quantity"`
 }
 
-func (d *DrainUpdate) Valid() error {
-	if d.DeedID == nil && d.EntryID == nil && d.Quantity == nil {
-		return Errorf(EINVALID, "all drain data is nil")
+func (d *DrainUpdate) Validate() error {
+	if d.Quantity == nil {
+		return Errorf(EINVALID, "quantity required")
+	}
+	if *d.Quantity <= 0 {
+		return Errorf(EINVALID, "quantity must be greater than zero")
 	}
 
 	return nil
 }
+
+type DrainDelete struct {
+	Resurect bool `json:"resurect" presence_is:"true"`
+}
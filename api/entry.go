@@ -2,7 +2,10 @@ package dots
 
 import (
 	"context"
+	"strings"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 type Entry struct {
@@ -11,20 +14,48 @@ type Entry struct {
 	DateAdded   time.Time `json:"date_added"`
 	Quantity    *float64  `json:"quantity"`
 	CompanyID   *int      `json:"company_id"`
+	// UnitCost is the purchase price of a unit
+	UnitCost *decimal.Decimal `json:"unitcost"`
+	// Lot is the batch number of the supplier, ExpiresAt the last day the entry can be used
+	Lot       *string      `json:"lot,omitempty"`
+	ExpiresAt *PartialTime `json:"expires_at,omitempty"`
+
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 func (e *Entry) Validate() error {
 	if e.EntryTypeID == nil || e.Quantity == nil || e.CompanyID == nil {
 		return Errorf(EINVALID, "entry type, company and quantity are required")
 	}
+	if e.UnitCost != nil && e.UnitCost.IsNegative() {
+		return Errorf(EINVALID, "unit cost cannot be negative")
+	}
+	e.Lot = trimLot(e.Lot)
 	return nil
 }
 
+// trimLot takes a blank lot as no lot
+func trimLot(lot *string) *string {
+	if lot == nil {
+		return nil
+	}
+	v := strings.TrimSpace(*lot)
+	if v == "" {
+		return nil
+	}
+	return &v
+}
+
 type EntryService interface {
 	CreateEntry(context.Context, *Entry) error
 	UpdateEntry(context.Context, int, EntryUpdate) (*Entry, error)
 	FindEntry(context.Context, EntryFilter) ([]*Entry, int, error)
 	DeleteEntry(context.Context, int, EntryDelete) (int, error)
+	RestoreEntry(context.Context, TrashRestore) (int, error)
+	// FindStock tells what was left per company and entry type at a time
+	FindStock(context.Context, StockFilter) ([]*StockLevel, int, error)
+	// FindExpiring lists the entries with stock left that expire soon or already did
+	FindExpiring(context.Context, ExpiringFilter) ([]*ExpiringStock, int, error)
 }
 
 type EntryFilter struct {
@@ -33,11 +64,12 @@ type EntryFilter struct {
 	DateAdded   *time.Time `json:"date_added"`
 	Quantity    *float64   `json:"quantity"`
 	CompanyID   *int       `json:"company_id"`
+	Lot         *string    `json:"lot"`
 
 	Offset int `json:"offset"`
 	Limit  int `json:"limit"`
 
-	IsDeleted     bool         `json:"is_deleted"`
+	IsDeleted     *bool        `json:"is_deleted"`
 	DeletedAtFrom *PartialTime `json:"deleted_at_from,omitempty"`
 	DeletedAtTo   *PartialTime `json:"deleted_at_to,omitempty"`
 }
@@ -48,10 +80,13 @@ type EntryDelete struct {
 }
 
 type EntryUpdate struct {
-	EntryTypeID *int       `json:"entry_type_id"`
-	DateAdded   *time.Time `json:"date_added"`
-	Quantity    *float64   `json:"quantity"`
-	CompanyID   *int       `json:"company_id"`
+	EntryTypeID *int             `json:"entry_type_id"`
+	DateAdded   *time.Time       `json:"date_added"`
+	Quantity    *float64         `json:"quantity"`
+	CompanyID   *int             `json:"company_id"`
+	UnitCost    *decimal.Decimal `json:"unitcost"`
+	Lot         *string          `json:"lot"`
+	ExpiresAt   *PartialTime     `json:"expires_at"`
 }
 
 func (eu *EntryUpdate) Valid() error {
@@ -61,3 +96,57 @@ func (eu *EntryUpdate) Valid() error {
 
 	return nil
 }
+
+// StockLevel is the stock of an entry type of a company at a time
+type StockLevel struct {
+	CompanyID       int       `json:"company_id"`
+	EntryTypeID     int       `json:"entry_type_id"`
+	AsOf            time.Time `json:"as_of"`
+	QuantityInitial float64   `json:"quantity_initial"`
+	QuantityDrained float64   `json:"quantity_drained"`
+	Quantity        float64   `json:"quantity"`
+}
+
+type StockFilter struct {
+	CompanyID   *int `json:"company_id"`
+	EntryTypeID *int `json:"entry_type_id"`
+	// AsOf defaults to now; drain edits count from when they were made,
+	// those made before drain movements were recorded count from the drain itself
+	AsOf *PartialTime `json:"as_of,omitempty"`
+
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// ExpiringStock is what is left of an entry that expires within the days asked for
+type ExpiringStock struct {
+	CompanyID   int       `json:"company_id"`
+	EntryTypeID int       `json:"entry_type_id"`
+	EntryID     int       `json:"entry_id"`
+	Lot         *string   `json:"lot,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Quantity    float64   `json:"quantity"`
+	// DaysLeft is negative for expired entries, they are no longer distributed
+	DaysLeft int `json:"days_left"`
+}
+
+type ExpiringFilter struct {
+	CompanyID   *int `json:"company_id"`
+	EntryTypeID *int `json:"entry_type_id"`
+	// Days defaults to 30
+	Days *int `json:"days"`
+
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+func (f *ExpiringFilter) Validate() error {
+	if f.Days == nil {
+		days := 30
+		f.Days = &days
+	}
+	if *f.Days < 0 || *f.Days > 3660 {
+		return Errorf(EINVALID, "days must be between 0 and 3660")
+	}
+	return nil
+}
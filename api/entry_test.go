@@ -0,0 +1,35 @@
+package dots
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEntry_ExpiresAtDate(t *testing.T) {
+	var e Entry
+	if err := json.Unmarshal([]byte(`{"entry_type_id": 1, "quantity": 2, "company_id": 3, "expires_at": "2026-12-31"}`), &e); err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2026, 12, 31, 0, 0, 0, 0, time.Local)
+	if e.ExpiresAt == nil || !time.Time(*e.ExpiresAt).Equal(want) {
+		t.Fatalf("expires at=%v, want %v", e.ExpiresAt, want)
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"expires_at":"2026-12-31T00:00:00`) {
+		t.Fatalf("got %s", b)
+	}
+
+	var upd EntryUpdate
+	if err := json.Unmarshal([]byte(`{"expires_at": "2026-12"}`), &upd); err != nil {
+		t.Fatal(err)
+	}
+	if upd.ExpiresAt == nil || time.Time(*upd.ExpiresAt).Month() != time.December {
+		t.Fatalf("expires at=%v", upd.ExpiresAt)
+	}
+}
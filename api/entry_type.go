@@ -2,6 +2,7 @@ package dots
 
 import (
 	"context"
+	"time"
 )
 
 type EntryType struct {
@@ -9,6 +10,8 @@ type EntryType struct {
 	Code        *string `json:"code"`
 	Description *string `json:"description"`
 	Unit        *string `json:"unit"`
+
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 func (et *EntryType) Validate() error {
@@ -36,6 +39,7 @@ type EntryTypeService interface {
 	FindEntryTypeUnit(context.Context) ([]string, int, error)
 	FindEntryTypeStats(context.Context, StatsFilter) (map[string]string, error)
 	DeleteEntryType(context.Context, int, EntryTypeDelete) (int, error)
+	RestoreEntryType(context.Context, TrashRestore) (int, error)
 }
 
 type EntryTypeFilter struct {
@@ -13,6 +13,8 @@ const (
 	ENOTIMPLEMENTED = "not_implemented"
 	EUNAUTHORIZED   = "unauthorized"
 	ENOTAFFECTED    = "not_affected"
+	EQUOTA          = "quota_exceeded"
+	ETOOMANY        = "too_many_requests"
 )
 
 type Error struct {
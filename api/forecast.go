@@ -0,0 +1,128 @@
+package dots
+
+import (
+	"math"
+	"time"
+)
+
+// ways of projecting the daily consumption
+const (
+	ForecastMovingAverage = "moving_average"
+	ForecastExponential   = "exponential"
+)
+
+// forecast defaults
+const (
+	forecastWindow = 30
+	forecastAlpha  = 0.3
+)
+
+// CompanyForecast projects when an entry type of a company runs out
+type CompanyForecast struct {
+	CompanyID   int       `json:"company_id"`
+	EntryTypeID int       `json:"entry_type_id"`
+	Code        string    `json:"code"`
+	Method      string    `json:"method"`
+	AsOf        time.Time `json:"as_of"`
+	// Quantity is the stock left at AsOf
+	Quantity float64 `json:"quantity"`
+	// Consumed is what was drained within the window
+	Consumed  float64 `json:"consumed"`
+	DailyRate float64 `json:"daily_rate"`
+	// DaysLeft and DepletesAt are nil when nothing gets consumed
+	DaysLeft   *float64   `json:"days_left"`
+	DepletesAt *time.Time `json:"depletes_at"`
+}
+
+type ForecastFilter struct {
+	CompanyID   *int         `json:"company_id"`
+	EntryTypeID *int         `json:"entry_type_id"`
+	AsOf        *PartialTime `json:"as_of,omitempty"`
+	// Window is the number of days looked back, 30 by default
+	Window int    `json:"window"`
+	Method string `json:"method"`
+	// Alpha weights the latest days when smoothing exponentially, 0.3 by default
+	Alpha *float64 `json:"alpha"`
+
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// Validate defaults to a moving average over 30 days
+func (f *ForecastFilter) Validate() error {
+	if f.Method == "" {
+		f.Method = ForecastMovingAverage
+	}
+	if f.Method != ForecastMovingAverage && f.Method != ForecastExponential {
+		return Errorf(EINVALID, "unknown forecast method %q, want moving_average or exponential", f.Method)
+	}
+	if f.Window == 0 {
+		f.Window = forecastWindow
+	}
+	if f.Window < 1 || f.Window > 366 {
+		return Errorf(EINVALID, "window must be between 1 and 366 days")
+	}
+	if f.Alpha == nil {
+		alpha := forecastAlpha
+		f.Alpha = &alpha
+	}
+	if *f.Alpha <= 0 || *f.Alpha > 1 {
+		return Errorf(EINVALID, "alpha must be above 0 and at most 1")
+	}
+	return nil
+}
+
+// ForecastDay tells the day, oldest first, a drain made at falls in within the window
+// of days ending at as of. The window leaves out its start and keeps as of
+func ForecastDay(asOf, at time.Time, window int) (int, bool) {
+	from := asOf.AddDate(0, 0, -window)
+	if !at.After(from) || at.After(asOf) {
+		return 0, false
+	}
+
+	day := int(math.Ceil(at.Sub(from).Hours()/24)) - 1
+	// days around a daylight saving change are an hour longer or shorter
+	if day < 0 {
+		day = 0
+	}
+	if day >= window {
+		day = window - 1
+	}
+	return day, true
+}
+
+// DailyRate tells the consumption per day out of daily drains, oldest day first
+func DailyRate(method string, daily []float64, alpha float64) float64 {
+	if len(daily) == 0 {
+		return 0
+	}
+
+	if method == ForecastExponential {
+		rate := daily[0]
+		for _, q := range daily[1:] {
+			rate = alpha*q + (1-alpha)*rate
+		}
+		return rate
+	}
+
+	sum := 0.0
+	for _, q := range daily {
+		sum += q
+	}
+	return sum / float64(len(daily))
+}
+
+// Project sets when the stock runs out at the daily rate
+func (cf *CompanyForecast) Project() {
+	cf.DaysLeft, cf.DepletesAt = nil, nil
+	if cf.DailyRate <= stockEpsilon {
+		return
+	}
+
+	days := cf.Quantity / cf.DailyRate
+	if days < 0 {
+		days = 0
+	}
+	at := cf.AsOf.Add(time.Duration(days * float64(24*time.Hour)))
+	cf.DaysLeft, cf.DepletesAt = &days, &at
+}
@@ -0,0 +1,54 @@
+package dots
+
+import (
+	"testing"
+	"time"
+)
+
+func TestForecastDay(t *testing.T) {
+	asOf := time.Date(2023, 6, 30, 12, 0, 0, 0, time.UTC)
+	from := asOf.AddDate(0, 0, -3)
+
+	tests := []struct {
+		name string
+		at   time.Time
+		day  int
+		ok   bool
+	}{
+		{"at as of is the last day", asOf, 2, true},
+		{"just before as of", asOf.Add(-time.Second), 2, true},
+		{"past as of is left out", asOf.Add(time.Second), 0, false},
+		{"at the window start is left out", from, 0, false},
+		{"just after the window start is the first day", from.Add(time.Second), 0, true},
+		{"a whole day in is still the first day", from.Add(24 * time.Hour), 0, true},
+		{"a day and a second in is the second day", from.Add(24*time.Hour + time.Second), 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day, ok := ForecastDay(asOf, tt.at, 3)
+			if day != tt.day || ok != tt.ok {
+				t.Fatalf("day=%d ok=%v, want %d %v", day, ok, tt.day, tt.ok)
+			}
+		})
+	}
+}
+
+func TestDailyRate(t *testing.T) {
+	daily := []float64{0, 4, 2}
+
+	// 6 drained in 3 days
+	if got := DailyRate(ForecastMovingAverage, daily, 0.5); got != 2 {
+		t.Fatalf("moving average=%v, want 2", got)
+	}
+	// 0, then 0.5*4, then 0.5*2 + 0.5*2
+	if got := DailyRate(ForecastExponential, daily, 0.5); got != 2 {
+		t.Fatalf("exponential=%v, want 2", got)
+	}
+
+	cf := &CompanyForecast{Quantity: 12, DailyRate: 2}
+	cf.Project()
+	if cf.DaysLeft == nil || *cf.DaysLeft != 6 {
+		t.Fatalf("days left=%v, want 6", cf.DaysLeft)
+	}
+}
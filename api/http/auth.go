@@ -1,6 +1,7 @@
 package http
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
@@ -8,17 +9,27 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/innermond/dots"
+	"github.com/innermond/dots/http/oidc"
+	"golang.org/x/oauth2"
 )
 
 func (s *Server) registerAuthRoutes(router *mux.Router) {
 	router.HandleFunc("/login", s.handleLogin).Methods("GET")
 	router.HandleFunc("/login", s.handleTokening).Methods("POST")
-	router.HandleFunc("/logout", s.handleLogout).Methods("GET")
-	router.HandleFunc("/oauth/google", s.handleOAuthGoogle).Methods("GET")
-	router.HandleFunc("/oauth/google/callback", s.handleOAuthGoogleCallback).Methods("GET")
+	router.HandleFunc("/token/refresh", s.handleTokenRefresh).Methods("POST")
+	router.HandleFunc("/register", s.handleRegister).Methods("POST")
+	router.HandleFunc("/password/forgot", s.handlePasswordForgot).Methods("POST")
+	router.HandleFunc("/password/reset", s.handlePasswordReset).Methods("POST")
+	router.HandleFunc("/logout", s.handleLogout).Methods("GET", "POST")
+	// github is not an openid connect provider so it goes before the generic routes
+	router.HandleFunc("/oauth/github", s.handleOAuthGithub).Methods("GET")
+	router.HandleFunc("/oauth/github/callback", s.handleOAuthGithubCallback).Methods("GET")
+	router.HandleFunc("/oauth/{provider}", s.handleOAuthProvider).Methods("GET")
+	router.HandleFunc("/oauth/{provider}/callback", s.handleOAuthProviderCallback).Methods("GET")
 }
 
 func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
@@ -47,19 +58,66 @@ func (s *Server) handleTokening(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	str, err := s.TokenService.Create(r.Context(), cc)
+	var pair *dots.TokenPair
+	e := s.loginEvent(r, &dots.LoginEvent{Email: cc.Email})
+	wait, err := s.LoginEventService.PasswordLogin(r.Context(), e, func() (err error) {
+		pair, err = s.TokenService.Create(r.Context(), cc)
+		return err
+	})
 	if err != nil {
-		Error(w, r, dots.Errorf(dots.EUNAUTHORIZED, "[create token]: %v", err))
+		Error(w, r, err)
+		return
+	}
+	if wait > 0 {
+		secs := retryAfter(w, wait)
+		Error(w, r, dots.Errorf(dots.ETOOMANY, "too many failed logins, retry in %d seconds", secs))
 		return
 	}
 
-	type token struct {
-		Access string `json:"token_access"`
+	outputJSON(w, r, http.StatusOK, pair)
+}
+
+func (s *Server) handleTokenRefresh(w http.ResponseWriter, r *http.Request) {
+	var tr dots.TokenRefresh
+	if ok := inputJSON(w, r, &tr, "refresh token"); !ok {
+		return
 	}
-	outputJSON(w, r, http.StatusOK, &token{str})
+
+	pair, err := s.TokenService.Refresh(r.Context(), tr.Refresh)
+	if err != nil {
+		s.auditLogin(r, &dots.LoginEvent{Kind: dots.LoginRefresh, Reason: dots.ErrorMessage(err)})
+		Error(w, r, err)
+		return
+	}
+
+	e := &dots.LoginEvent{Kind: dots.LoginRefresh, Success: true}
+	if payload, err := s.TokenService.Read(r.Context(), pair.Access); err == nil {
+		e.UserID = &payload.UID
+	}
+	s.auditLogin(r, e)
+
+	outputJSON(w, r, http.StatusOK, pair)
 }
 
 func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	// bearer clients have no session, their tokens get revoked
+	if payload := dots.TokenFromContext(r.Context()); payload != nil {
+		var tr dots.TokenRefresh
+		if r.Body != http.NoBody {
+			if ok := inputJSON(w, r, &tr, "logout"); !ok {
+				return
+			}
+		}
+
+		if err := s.TokenService.Revoke(r.Context(), payload, tr.Refresh); err != nil {
+			Error(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
 	err := s.setSession(w, Session{})
 	if err != nil {
 		Error(w, r, err)
@@ -69,113 +127,277 @@ func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusFound)
 }
 
-func (s *Server) handleOAuthGoogle(w http.ResponseWriter, r *http.Request) {
-	session, err := s.getSession(r)
+func (s *Server) handleOAuthProvider(w http.ResponseWriter, r *http.Request) {
+	p, err := s.oauthProvider(r)
 	if err != nil {
 		Error(w, r, err)
 		return
 	}
 
-	state := make([]byte, 64)
-	_, err = io.ReadFull(rand.Reader, state)
+	s.oauthRedirect(w, r, p.OAuth2Config())
+}
+
+func (s *Server) handleOAuthProviderCallback(w http.ResponseWriter, r *http.Request) {
+	p, err := s.oauthProvider(r)
 	if err != nil {
 		Error(w, r, err)
 		return
 	}
-	session.State = hex.EncodeToString(state)
 
-	err = s.setSession(w, session)
+	session, tok, oauth, err := s.oauthExchange(r, p.OAuth2Config())
 	if err != nil {
 		Error(w, r, err)
 		return
 	}
 
-	authUrl := s.OAuth2Config().AuthCodeURL(session.State)
-	http.Redirect(w, r, authUrl, http.StatusFound)
+	rawIDToken, _ := tok.Extra("id_token").(string)
+	if rawIDToken == "" {
+		Error(w, r, dots.Errorf(dots.EUNAUTHORIZED, "oauth: missing id token"))
+		return
+	}
+
+	claims, err := p.Verify(r.Context(), rawIDToken, session.Nonce)
+	if err != nil {
+		s.auditLogin(r, &dots.LoginEvent{Kind: dots.LoginOAuth, Source: p.Name, Reason: "id token rejected"})
+		Error(w, r, dots.Errorf(dots.EUNAUTHORIZED, "oauth: id token rejected").Wrap(err))
+		return
+	}
+
+	if claims.Email == "" || claims.Name == "" {
+		client := oauth.Client(r.Context(), tok)
+		if err := p.UserInfo(r.Context(), client, claims); err != nil {
+			Error(w, r, fmt.Errorf("http: cannot read user info %s", err))
+			return
+		}
+	}
+
+	// only a verified email is allowed to link with an existing user
+	var email string
+	if claims.EmailVerified {
+		email = claims.Email
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = claims.PreferredUsername
+	}
+	if name == "" {
+		name = claims.Subject
+	}
+
+	auth := &dots.Auth{
+		Source:       p.Name,
+		SourceID:     claims.Subject,
+		AccessToken:  tok.AccessToken,
+		RefreshToken: tok.RefreshToken,
+		User:         &dots.User{Name: name, Email: email},
+	}
+
+	s.oauthLogin(w, r, session, tok, auth)
 }
 
-func (s *Server) handleOAuthGoogleCallback(w http.ResponseWriter, r *http.Request) {
-	session, err := s.getSession(r)
+func (s *Server) oauthProvider(r *http.Request) (*oidc.Provider, error) {
+	name := mux.Vars(r)["provider"]
+	p, err := s.OAuthProviders.Provider(r.Context(), name)
+	if errors.Is(err, oidc.ErrProviderNotFound) {
+		return nil, dots.Errorf(dots.ENOTFOUND, "oauth provider %s not found", name)
+	}
 	if err != nil {
-		Error(w, r, err)
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (s *Server) handleOAuthGithub(w http.ResponseWriter, r *http.Request) {
+	if s.GithubClientID == "" {
+		Error(w, r, dots.Errorf(dots.ENOTIMPLEMENTED, "github login is not configured"))
 		return
 	}
 
-	code, state := r.FormValue("code"), r.FormValue("state")
-	if state != session.State {
-		Error(w, r, errors.New("oauth state mismatch"))
+	s.oauthRedirect(w, r, s.GithubOAuth2Config())
+}
+
+func (s *Server) handleOAuthGithubCallback(w http.ResponseWriter, r *http.Request) {
+	if s.GithubClientID == "" {
+		Error(w, r, dots.Errorf(dots.ENOTIMPLEMENTED, "github login is not configured"))
+		return
 	}
 
-	oauth := s.OAuth2Config()
-	tok, err := oauth.Exchange(r.Context(), code)
+	session, tok, oauth, err := s.oauthExchange(r, s.GithubOAuth2Config())
 	if err != nil {
-		Error(w, r, fmt.Errorf("oauth exchange error: %s", err))
+		Error(w, r, err)
 		return
 	}
 
-	const oauthGoogleURLAPI = "https://www.googleapis.com/oauth2/v2/userinfo"
 	client := oauth.Client(r.Context(), tok)
-	resp, err := client.Get(oauthGoogleURLAPI)
+	gu, err := fetchGithubUser(r.Context(), client, s.githubAPIURL)
 	if err != nil {
-		Error(w, r, fmt.Errorf("http: response error %s", err))
+		s.auditLogin(r, &dots.LoginEvent{Kind: dots.LoginOAuth, Source: dots.AuthSourceGithub, Reason: dots.ErrorMessage(err)})
+		Error(w, r, err)
 		return
 	}
-	cnt, err := io.ReadAll(resp.Body)
+
+	name := gu.Name
+	if name == "" {
+		name = gu.Login
+	}
+
+	auth := &dots.Auth{
+		Source:       dots.AuthSourceGithub,
+		SourceID:     strconv.FormatInt(gu.ID, 10),
+		AccessToken:  tok.AccessToken,
+		RefreshToken: tok.RefreshToken,
+		User:         &dots.User{Name: name, Email: gu.Email},
+	}
+
+	s.oauthLogin(w, r, session, tok, auth)
+}
+
+type githubUser struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// fetchGithubUser reads the profile and replaces its public email
+// with the primary verified one, the only email we trust to link accounts
+func fetchGithubUser(ctx context.Context, client *http.Client, apiURL string) (*githubUser, error) {
+	var gu githubUser
+	if err := githubGet(ctx, client, apiURL+"/user", &gu); err != nil {
+		return nil, err
+	}
+	if gu.ID == 0 {
+		return nil, errors.New("http: github user without id")
+	}
+
+	var ee []githubEmail
+	if err := githubGet(ctx, client, apiURL+"/user/emails", &ee); err != nil {
+		return nil, err
+	}
+
+	gu.Email = ""
+	for _, e := range ee {
+		if e.Primary && e.Verified {
+			gu.Email = e.Email
+			break
+		}
+	}
+	if gu.Email == "" {
+		return nil, dots.Errorf(dots.EUNAUTHORIZED, "github account has no verified primary email")
+	}
+
+	return &gu, nil
+}
+
+func githubGet(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		Error(w, r, fmt.Errorf("http: cannot read response %s", err))
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("http: response error %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("http: github %s responded %d", url, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("http: cannot decode response %s", err)
+	}
+
+	return nil
+}
+
+// oauthRedirect stores a random state into session and sends the user to provider
+func (s *Server) oauthRedirect(w http.ResponseWriter, r *http.Request, oauth *oauth2.Config) {
+	session, err := s.getSession(r)
+	if err != nil && err != http.ErrNoCookie {
+		Error(w, r, err)
 		return
 	}
 
-	type authResponse struct {
-		ID            *string `json:"id"`
-		Name          *string `json:"name"`
-		Login         *string `json:"login"`
-		Email         *string `json:"email"`
-		VerifiedEmail *bool   `json:"verified_email"`
+	state := make([]byte, 64)
+	_, err = io.ReadFull(rand.Reader, state)
+	if err != nil {
+		Error(w, r, err)
+		return
 	}
+	session.State = hex.EncodeToString(state)
 
-	var u authResponse
-	err = json.Unmarshal(cnt, &u)
+	nonce := make([]byte, 32)
+	_, err = io.ReadFull(rand.Reader, nonce)
 	if err != nil {
-		Error(w, r, fmt.Errorf("http: cannot decode response %s", err))
+		Error(w, r, err)
 		return
 	}
+	session.Nonce = hex.EncodeToString(nonce)
 
-	var name string
-	if u.Name != nil {
-		name = *u.Name
-	} else if u.Login != nil {
-		name = *u.Login
+	err = s.setSession(w, session)
+	if err != nil {
+		Error(w, r, err)
+		return
 	}
 
-	var email string
-	if u.Email != nil {
-		email = *u.Email
+	authUrl := oauth.AuthCodeURL(session.State, oauth2.SetAuthURLParam("nonce", session.Nonce))
+	http.Redirect(w, r, authUrl, http.StatusFound)
+}
+
+// oauthExchange checks the state and trades the code for a token
+func (s *Server) oauthExchange(r *http.Request, oauth *oauth2.Config) (Session, *oauth2.Token, *oauth2.Config, error) {
+	session, err := s.getSession(r)
+	if err != nil {
+		return session, nil, nil, err
 	}
 
-	auth := &dots.Auth{
-		Source:       dots.AuthSourceGoogle,
-		SourceID:     *u.ID,
-		AccessToken:  tok.AccessToken,
-		RefreshToken: tok.RefreshToken,
-		User:         &dots.User{Name: name, Email: email},
+	code, state := r.FormValue("code"), r.FormValue("state")
+	if session.State == "" || state != session.State {
+		return session, nil, nil, dots.Errorf(dots.EUNAUTHORIZED, "oauth state mismatch")
+	}
+
+	tok, err := oauth.Exchange(r.Context(), code)
+	if err != nil {
+		return session, nil, nil, fmt.Errorf("oauth exchange error: %s", err)
 	}
 
+	return session, tok, oauth, nil
+}
+
+// oauthLogin persists the auth, links it to a user and logs the user in
+func (s *Server) oauthLogin(w http.ResponseWriter, r *http.Request, session Session, tok *oauth2.Token, auth *dots.Auth) {
 	if !tok.Expiry.IsZero() {
 		auth.Expiry = &tok.Expiry
 	}
 
-	err = s.AuthService.CreateAuth(r.Context(), auth)
+	e := &dots.LoginEvent{Kind: dots.LoginOAuth, Source: auth.Source, Email: auth.User.Email}
+	err := s.AuthService.CreateAuth(r.Context(), auth)
 	if err != nil {
-		Error(w, r, fmt.Errorf("http: cannot create auth: %s", err))
+		e.Reason = dots.ErrorMessage(err)
+		s.auditLogin(r, e)
+		Error(w, r, fmt.Errorf("http: cannot create auth: %w", err))
 		return
 	}
+	e.UserID, e.Success = &auth.UserID, true
+	s.auditLogin(r, e)
 
 	redirectURL := session.RedirectURL
 
 	session.UserID = auth.UserID
 	session.RedirectURL = ""
 	session.State = ""
+	session.Nonce = ""
 	if err := s.setSession(w, session); err != nil {
 		Error(w, r, fmt.Errorf("cannot set session cookie: %s", err))
 		return
@@ -185,5 +407,4 @@ func (s *Server) handleOAuthGoogleCallback(w http.ResponseWriter, r *http.Reques
 		redirectURL = "/"
 	}
 	http.Redirect(w, r, redirectURL, http.StatusFound)
-
 }
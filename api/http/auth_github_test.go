@@ -0,0 +1,177 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/innermond/dots"
+	"github.com/segmentio/ksuid"
+	"golang.org/x/oauth2"
+)
+
+type fakeAuthService struct {
+	created *dots.Auth
+	err     error
+}
+
+func (s *fakeAuthService) CreateAuth(ctx context.Context, a *dots.Auth) error {
+	if s.err != nil {
+		return s.err
+	}
+	a.UserID = ksuid.New()
+	s.created = a
+	return nil
+}
+
+func newFakeGithub(t *testing.T, emails []githubEmail) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "ACCESS_TOKEN",
+			"token_type":   "bearer",
+		})
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ACCESS_TOKEN" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(githubUser{ID: 42, Login: "octocat", Email: "public@example.com"})
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(emails)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func newGithubTestServer(t *testing.T, fake *httptest.Server) (*Server, *fakeAuthService) {
+	t.Helper()
+
+	s := NewServer()
+	s.sc = securecookie.New(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+	s.sc.SetSerializer(securecookie.JSONEncoder{})
+	s.LoginEventService = &fakeLoginEventService{}
+	s.GithubClientID = "CLIENT_ID"
+	s.GithubClientSecret = "CLIENT_SECRET"
+	s.githubEndpoint = oauth2.Endpoint{
+		AuthURL:  fake.URL + "/login/oauth/authorize",
+		TokenURL: fake.URL + "/login/oauth/access_token",
+	}
+	s.githubAPIURL = fake.URL
+
+	as := &fakeAuthService{}
+	s.AuthService = as
+
+	return s, as
+}
+
+func githubCallbackRequest(t *testing.T, s *Server, state string) *http.Request {
+	t.Helper()
+
+	cookie, err := s.MarshalSession(Session{State: "STATE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := url.Values{"code": {"CODE"}, "state": {state}}
+	r := httptest.NewRequest("GET", "/oauth/github/callback?"+q.Encode(), nil)
+	r.AddCookie(&http.Cookie{Name: SessionCookieName, Value: cookie})
+
+	return r
+}
+
+func TestServer_handleOAuthGithubCallback(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		fake := newFakeGithub(t, []githubEmail{
+			{Email: "unverified@example.com", Primary: false, Verified: false},
+			{Email: "primary@example.com", Primary: true, Verified: true},
+		})
+		defer fake.Close()
+
+		s, as := newGithubTestServer(t, fake)
+		w := httptest.NewRecorder()
+		s.handleOAuthGithubCallback(w, githubCallbackRequest(t, s, "STATE"))
+
+		if w.Code != http.StatusFound {
+			t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusFound, w.Body.String())
+		}
+		if as.created == nil {
+			t.Fatal("expected auth to be created")
+		}
+		if got, want := as.created.Source, dots.AuthSourceGithub; got != want {
+			t.Fatalf("source=%v, want %v", got, want)
+		}
+		if got, want := as.created.SourceID, "42"; got != want {
+			t.Fatalf("source id=%v, want %v", got, want)
+		}
+		if got, want := as.created.User.Email, "primary@example.com"; got != want {
+			t.Fatalf("email=%v, want %v", got, want)
+		}
+		if got, want := as.created.User.Name, "octocat"; got != want {
+			t.Fatalf("name=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("ErrNoVerifiedEmail", func(t *testing.T) {
+		fake := newFakeGithub(t, []githubEmail{
+			{Email: "primary@example.com", Primary: true, Verified: false},
+		})
+		defer fake.Close()
+
+		s, as := newGithubTestServer(t, fake)
+		w := httptest.NewRecorder()
+		s.handleOAuthGithubCallback(w, githubCallbackRequest(t, s, "STATE"))
+
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("status=%d, want %d", w.Code, http.StatusUnauthorized)
+		}
+		if as.created != nil {
+			t.Fatal("unexpected auth created")
+		}
+	})
+
+	t.Run("ErrUnverifiedAccount", func(t *testing.T) {
+		fake := newFakeGithub(t, []githubEmail{
+			{Email: "primary@example.com", Primary: true, Verified: true},
+		})
+		defer fake.Close()
+
+		s, as := newGithubTestServer(t, fake)
+		as.err = dots.Errorf(dots.ECONFLICT, "email belongs to an account that never verified it")
+		w := httptest.NewRecorder()
+		s.handleOAuthGithubCallback(w, githubCallbackRequest(t, s, "STATE"))
+
+		if w.Code != http.StatusConflict {
+			t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusConflict, w.Body.String())
+		}
+		var got errorResponse
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Error != "email belongs to an account that never verified it" {
+			t.Fatalf("error=%q", got.Error)
+		}
+	})
+
+	t.Run("ErrStateMismatch", func(t *testing.T) {
+		fake := newFakeGithub(t, nil)
+		defer fake.Close()
+
+		s, _ := newGithubTestServer(t, fake)
+		w := httptest.NewRecorder()
+		s.handleOAuthGithubCallback(w, githubCallbackRequest(t, s, "OTHER"))
+
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("status=%d, want %d", w.Code, http.StatusUnauthorized)
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/securecookie"
+	"github.com/innermond/dots/http/oidc"
+	"github.com/innermond/dots/http/oidc/oidctest"
+)
+
+func newOIDCTestServer(t *testing.T, issuer *oidctest.Server) (*Server, *fakeAuthService) {
+	t.Helper()
+
+	s := NewServer()
+	s.sc = securecookie.New(securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32))
+	s.sc.SetSerializer(securecookie.JSONEncoder{})
+	s.LoginEventService = &fakeLoginEventService{}
+
+	s.OAuthProviders = oidc.NewRegistry(nil)
+	err := s.OAuthProviders.Add(oidc.Config{
+		Name:         "test",
+		Issuer:       issuer.Issuer(),
+		ClientID:     issuer.ClientID,
+		ClientSecret: "CLIENT_SECRET",
+		RedirectBase: "http://localhost:8080",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	as := &fakeAuthService{}
+	s.AuthService = as
+
+	return s, as
+}
+
+func oidcCallbackRequest(t *testing.T, s *Server, nonce string) *http.Request {
+	t.Helper()
+
+	cookie, err := s.MarshalSession(Session{State: "STATE", Nonce: nonce})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := url.Values{"code": {"CODE"}, "state": {"STATE"}}
+	r := httptest.NewRequest("GET", "/oauth/test/callback?"+q.Encode(), nil)
+	r.AddCookie(&http.Cookie{Name: SessionCookieName, Value: cookie})
+
+	return mux.SetURLVars(r, map[string]string{"provider": "test"})
+}
+
+func TestServer_handleOAuthProviderCallback(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		issuer := oidctest.NewServer("CLIENT_ID")
+		defer issuer.Close()
+
+		claims := issuer.DefaultClaims()
+		claims["nonce"] = "NONCE"
+		issuer.SetClaims(claims)
+
+		s, as := newOIDCTestServer(t, issuer)
+		w := httptest.NewRecorder()
+		s.handleOAuthProviderCallback(w, oidcCallbackRequest(t, s, "NONCE"))
+
+		if w.Code != http.StatusFound {
+			t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusFound, w.Body.String())
+		}
+		if as.created == nil {
+			t.Fatal("expected auth to be created")
+		}
+		if got, want := as.created.Source, "test"; got != want {
+			t.Fatalf("source=%v, want %v", got, want)
+		}
+		if got, want := as.created.SourceID, "SUBJECT"; got != want {
+			t.Fatalf("source id=%v, want %v", got, want)
+		}
+		if got, want := as.created.User.Email, "user@example.com"; got != want {
+			t.Fatalf("email=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("UserInfo", func(t *testing.T) {
+		issuer := oidctest.NewServer("CLIENT_ID")
+		defer issuer.Close()
+
+		claims := issuer.DefaultClaims()
+		claims["nonce"] = "NONCE"
+		delete(claims, "email")
+		delete(claims, "email_verified")
+		delete(claims, "name")
+		issuer.SetClaims(claims)
+
+		s, as := newOIDCTestServer(t, issuer)
+		w := httptest.NewRecorder()
+		s.handleOAuthProviderCallback(w, oidcCallbackRequest(t, s, "NONCE"))
+
+		if w.Code != http.StatusFound {
+			t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusFound, w.Body.String())
+		}
+		if got, want := as.created.User.Email, "userinfo@example.com"; got != want {
+			t.Fatalf("email=%v, want %v", got, want)
+		}
+		if got, want := as.created.User.Name, "Userinfo Name"; got != want {
+			t.Fatalf("name=%v, want %v", got, want)
+		}
+	})
+
+	t.Run("UnverifiedEmail", func(t *testing.T) {
+		issuer := oidctest.NewServer("CLIENT_ID")
+		defer issuer.Close()
+
+		claims := issuer.DefaultClaims()
+		claims["nonce"] = "NONCE"
+		claims["email_verified"] = false
+		issuer.SetClaims(claims)
+
+		s, as := newOIDCTestServer(t, issuer)
+		w := httptest.NewRecorder()
+		s.handleOAuthProviderCallback(w, oidcCallbackRequest(t, s, "NONCE"))
+
+		if w.Code != http.StatusFound {
+			t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusFound, w.Body.String())
+		}
+		if got := as.created.User.Email; got != "" {
+			t.Fatalf("unverified email %v must not be used for linking", got)
+		}
+	})
+
+	t.Run("ErrNonce", func(t *testing.T) {
+		issuer := oidctest.NewServer("CLIENT_ID")
+		defer issuer.Close()
+
+		claims := issuer.DefaultClaims()
+		claims["nonce"] = "REPLAYED"
+		issuer.SetClaims(claims)
+
+		s, as := newOIDCTestServer(t, issuer)
+		w := httptest.NewRecorder()
+		s.handleOAuthProviderCallback(w, oidcCallbackRequest(t, s, "NONCE"))
+
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("status=%d, want %d", w.Code, http.StatusUnauthorized)
+		}
+		if as.created != nil {
+			t.Fatal("unexpected auth created")
+		}
+	})
+
+	t.Run("ErrProviderNotFound", func(t *testing.T) {
+		issuer := oidctest.NewServer("CLIENT_ID")
+		defer issuer.Close()
+
+		s, _ := newOIDCTestServer(t, issuer)
+		r := mux.SetURLVars(oidcCallbackRequest(t, s, "NONCE"), map[string]string{"provider": "missing"})
+		w := httptest.NewRecorder()
+		s.handleOAuthProviderCallback(w, r)
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("status=%d, want %d", w.Code, http.StatusNotFound)
+		}
+	})
+}
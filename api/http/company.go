@@ -10,11 +10,16 @@ import (
 
 func (s *Server) registerCompanyRoutes(router *mux.Router) {
 	router.HandleFunc("", s.handleCompanyCreate).Methods("POST")
+	router.HandleFunc("/restore", s.handleCompanyRestore).Methods("POST")
 	router.HandleFunc("/{id}", s.handleCompanyPatch).Methods("PATCH")
 	router.HandleFunc("", s.handleCompanyFind).Methods("GET")
 	router.HandleFunc("/{id}", s.handleCompanyHardDelete).Methods("DELETE")
+	router.HandleFunc("/{id}/settings", s.handleCompanySettingFind).Methods("GET")
+	router.HandleFunc("/{id}/settings", s.handleCompanySettingUpdate).Methods("PATCH")
 	router.HandleFunc("/stats", s.handleCompanyStats).Methods("GET")
-	router.HandleFunc("/depletion", s.handleCompanyDepletion).Methods("GET")
+	// depletion is the costly query, it has its own limit
+	router.Handle("/depletion", s.rateLimit(RateLimitDepletion)(http.HandlerFunc(s.handleCompanyDepletion))).Methods("GET")
+	router.Handle("/forecast", s.rateLimit(RateLimitDepletion)(http.HandlerFunc(s.handleCompanyForecast))).Methods("GET")
 }
 
 func (s *Server) handleCompanyCreate(w http.ResponseWriter, r *http.Request) {
@@ -157,3 +162,68 @@ func (s *Server) handleCompanyDepletion(w http.ResponseWriter, r *http.Request)
 
 	outputJSON(w, r, http.StatusOK, &foundResponse[[]*dots.CompanyDepletion]{ee, affected{n}})
 }
+
+func (s *Server) handleCompanyForecast(w http.ResponseWriter, r *http.Request) {
+	filter := dots.ForecastFilter{}
+	input(w, r, &filter, "forecast company")
+
+	ff, n, err := s.CompanyService.ForecastCompany(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &foundResponse[[]*dots.CompanyForecast]{ff, affected{n}})
+}
+
+func (s *Server) handleCompanyRestore(w http.ResponseWriter, r *http.Request) {
+	var restore dots.TrashRestore
+	if ok := inputJSON(w, r, &restore, "restore companies"); !ok {
+		return
+	}
+
+	n, err := s.CompanyService.RestoreCompany(r.Context(), restore)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &affected{n})
+}
+
+func (s *Server) handleCompanySettingFind(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, dots.Errorf(dots.EINVALID, "invalid ID format"))
+		return
+	}
+
+	cs, err := s.CompanyService.FindCompanySetting(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, cs)
+}
+
+func (s *Server) handleCompanySettingUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, dots.Errorf(dots.EINVALID, "invalid ID format"))
+		return
+	}
+
+	var upd dots.CompanySettingUpdate
+	if ok := inputJSON(w, r, &upd, "update company settings"); !ok {
+		return
+	}
+
+	cs, err := s.CompanyService.UpdateCompanySetting(r.Context(), id, upd)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, cs)
+}
@@ -0,0 +1,60 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/innermond/dots"
+)
+
+func (s *fakeCompanyService) UpdateCompanySetting(ctx context.Context, id int, upd dots.CompanySettingUpdate) (*dots.CompanySetting, error) {
+	return &dots.CompanySetting{CompanyID: id, DistributeStrategy: upd.DistributeStrategy}, nil
+}
+
+func (s *fakeDeedService) CreateDeed(ctx context.Context, d *dots.Deed) error {
+	id := 1
+	d.ID = &id
+	s.created = d
+	return nil
+}
+
+func TestServer_handleCompanySettingUpdate(t *testing.T) {
+	s, _ := newTestServer()
+	s.CompanyService = &fakeCompanyService{}
+
+	w := serve(s, "PATCH", "/v1/companies/2/settings", `{"distribute_strategy": "exact_fit"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var got dots.CompanySetting
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.CompanyID != 2 || got.DistributeStrategy == nil || *got.DistributeStrategy != dots.DistributeExactFit {
+		t.Fatalf("got %+v", got)
+	}
+
+	w = serve(s, "PATCH", "/v1/companies/2/settings", `{"distribute_strategy": ""}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+}
+
+func TestServer_handleDeedCreate_strategy(t *testing.T) {
+	s, _ := newTestServer()
+	ds := &fakeDeedService{}
+	s.DeedService = ds
+
+	for _, strategy := range []dots.DistributeDrain{dots.DistributeFewOld, dots.DistributeLargestFirst, dots.DistributeFefo} {
+		body := `{"company_id": 1, "title": "t", "quantity": 1, "unit": "H87", "entry_type_distribute": {"2": 1}, "distribute_strategy": "` + string(strategy) + `"}`
+		w := serve(s, "POST", "/v1/deeds", body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("%s: status=%d, want %d: %s", strategy, w.Code, http.StatusCreated, w.Body)
+		}
+		if v := ds.created.DistributeStrategy; v == nil || *v != strategy {
+			t.Fatalf("%s: strategy=%v", strategy, v)
+		}
+	}
+}
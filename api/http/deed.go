@@ -10,8 +10,14 @@ import (
 
 func (s *Server) registerDeedRoutes(router *mux.Router) {
 	router.HandleFunc("", s.handleDeedCreate).Methods("POST")
+	router.HandleFunc("/restore", s.handleDeedRestore).Methods("POST")
+	router.HandleFunc("/distribute/preview", s.handleDistributePreview).Methods("POST")
 	router.HandleFunc("/{id}", s.handleDeedPatch).Methods("PATCH")
 	router.HandleFunc("", s.handleDeedFind).Methods("GET")
+	router.HandleFunc("/{id}/cost", s.handleDeedCost).Methods("GET")
+	router.HandleFunc("/{id}/profit", s.handleDeedProfit).Methods("GET")
+	router.HandleFunc("/{id}/confirm", s.handleDeedConfirm).Methods("POST")
+	router.HandleFunc("/{id}/cancel", s.handleDeedCancel).Methods("POST")
 }
 
 func (s *Server) handleDeedCreate(w http.ResponseWriter, r *http.Request) {
@@ -92,3 +98,33 @@ func (s *Server) handleDeedDelete(w http.ResponseWriter, r *http.Request) {
 
 	outputJSON(w, r, http.StatusFound, &affected{n})
 }
+
+func (s *Server) handleDeedRestore(w http.ResponseWriter, r *http.Request) {
+	var restore dots.TrashRestore
+	if ok := inputJSON(w, r, &restore, "restore deeds"); !ok {
+		return
+	}
+
+	n, err := s.DeedService.RestoreDeed(r.Context(), restore)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &affected{n})
+}
+
+func (s *Server) handleDistributePreview(w http.ResponseWriter, r *http.Request) {
+	var preview dots.DistributePreview
+	if ok := inputJSON(w, r, &preview, "preview distribute"); !ok {
+		return
+	}
+
+	p, err := s.DeedService.PreviewDistribute(r.Context(), preview)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, p)
+}
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/innermond/dots"
+)
+
+func (s *fakeDeedService) PreviewDistribute(ctx context.Context, preview dots.DistributePreview) (*dots.DistributePreviewed, error) {
+	if preview.EntryTypeDistribute[2] > 10 {
+		return &dots.DistributePreviewed{
+			Strategy:   dots.DistributeNewMany,
+			Distribute: map[int]float64{},
+			Remaining:  map[int]float64{},
+			NeedMore:   map[int]float64{2: preview.EntryTypeDistribute[2] - 10},
+		}, nil
+	}
+	return &dots.DistributePreviewed{
+		Strategy:   *preview.DistributeStrategy,
+		Distribute: map[int]float64{7: preview.EntryTypeDistribute[2]},
+		Remaining:  map[int]float64{7: 10 - preview.EntryTypeDistribute[2]},
+	}, nil
+}
+
+func TestServer_handleDistributePreview(t *testing.T) {
+	s, _ := newTestServer()
+	s.DeedService = &fakeDeedService{}
+
+	w := serve(s, "POST", "/v1/deeds/distribute/preview", `{"company_id": 1, "entry_type_distribute": {"2": 4}, "distribute_strategy": "old_few"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var got dots.DistributePreviewed
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Strategy != dots.DistributeOldFew || got.Distribute[7] != 4 || got.Remaining[7] != 6 || len(got.NeedMore) != 0 {
+		t.Fatalf("got %+v", got)
+	}
+
+	w = serve(s, "POST", "/v1/deeds/distribute/preview", `{"company_id": 1, "entry_type_distribute": {"2": 12}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	got = dots.DistributePreviewed{}
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.NeedMore[2] != 2 || len(got.Distribute) != 0 {
+		t.Fatalf("got %+v, want a shortfall of 2", got)
+	}
+}
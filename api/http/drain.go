@@ -3,6 +3,7 @@ package http
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/innermond/dots"
@@ -10,8 +11,8 @@ import (
 
 func (s *Server) registerDrainRoutes(router *mux.Router) {
 	router.HandleFunc("", s.handleDrainCreate).Methods("POST")
-	//router.HandleFunc("/{id}/edit", s.handleDrainUpdate).Methods("PATCH")
-	//router.HandleFunc("", s.handleDrainFind).Methods("GET")
+	router.HandleFunc("", s.handleDrainFind).Methods("GET")
+	router.HandleFunc("/{deed_id}/{entry_id}", s.handleDrainPatch).Methods("PATCH")
 }
 
 func (s *Server) handleDrainCreate(w http.ResponseWriter, r *http.Request) {
@@ -36,55 +37,82 @@ func (s *Server) handleDrainCreate(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-/*
-func (s *Server) handleDrainUpdate(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(mux.Vars(r)["id"])
+func (s *Server) handleDrainFind(w http.ResponseWriter, r *http.Request) {
+	// can accept missing r.Body
+	filter := dots.DrainFilter{}
+	input(w, r, &filter, "find drain")
+
+	dd, n, err := s.DrainService.FindDrain(r.Context(), filter)
 	if err != nil {
-		Error(w, r, dots.Errorf(dots.EINVALID, "invalid ID format"))
+		Error(w, r, err)
 		return
 	}
 
-	var updata dots.DrainUpdate
-	if err := json.NewDecoder(r.Body).Decode(&updata); err != nil {
-		Error(w, r, dots.Errorf(dots.EINVALID, "edit entry type: invalid json body"))
+	outputJSON(w, r, http.StatusOK, &foundResponse[[]*dots.Drain]{dd, affected{n}})
+}
+
+func (s *Server) handleDrainPatch(w http.ResponseWriter, r *http.Request) {
+	if _, found := r.URL.Query()["del"]; found {
+		s.handleDrainDelete(w, r)
 		return
 	}
 
-	u := dots.UserFromContext(r.Context())
-	updata.TID = &u.ID
+	s.handleDrainUpdate(w, r)
+}
 
-	if err := updata.Valid(); err != nil {
-		Error(w, r, err)
+func (s *Server) handleDrainUpdate(w http.ResponseWriter, r *http.Request) {
+	deedID, entryID, ok := drainVars(w, r)
+	if !ok {
 		return
 	}
 
-	et, err := s.DrainService.UpdateDrain(r.Context(), id, &updata)
+	var upd dots.DrainUpdate
+	if ok := inputJSON(w, r, &upd, "update drain"); !ok {
+		return
+	}
+
+	d, err := s.DrainService.UpdateDrain(r.Context(), deedID, entryID, upd)
 	if err != nil {
 		Error(w, r, err)
 		return
 	}
 
-	outputJSON[dots.Drain](w, r, http.StatusOK, et)
+	outputJSON(w, r, http.StatusOK, d)
 }
 
-func (s *Server) handleDrainFind(w http.ResponseWriter, r *http.Request) {
-	var filter dots.DrainFilter
-	ok := inputJSON[dots.DrainFilter](w, r, &filter)
+func (s *Server) handleDrainDelete(w http.ResponseWriter, r *http.Request) {
+	deedID, entryID, ok := drainVars(w, r)
 	if !ok {
 		return
 	}
 
-	ee, n, err := s.DrainService.FindDrain(r.Context(), &filter)
+	filter := dots.DrainDelete{}
+	if _, found := r.URL.Query()["resurect"]; found {
+		filter.Resurect = true
+	}
+
+	n, err := s.DrainService.DeleteDrain(r.Context(), deedID, entryID, filter)
 	if err != nil {
 		Error(w, r, err)
 		return
 	}
 
-	outputJSON[findDrainResponse](w, r, http.StatusFound, &findDrainResponse{Drains: ee, N: n})
+	outputJSON(w, r, http.StatusOK, &affected{n})
 }
 
-type findDrainResponse struct {
-	Drains []*dots.Drain `json:"entry_types"`
-	N          int               `json:"n"`
+// drainVars reads the deed and entry a drain is keyed by
+func drainVars(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	vars := mux.Vars(r)
+	deedID, err := strconv.Atoi(vars["deed_id"])
+	if err != nil {
+		Error(w, r, dots.Errorf(dots.EINVALID, "invalid deed ID format"))
+		return 0, 0, false
+	}
+	entryID, err := strconv.Atoi(vars["entry_id"])
+	if err != nil {
+		Error(w, r, dots.Errorf(dots.EINVALID, "invalid entry ID format"))
+		return 0, 0, false
+	}
+
+	return deedID, entryID, true
 }
-*/
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/innermond/dots"
+)
+
+type fakeDrainService struct {
+	dots.DrainService
+
+	filter dots.DrainFilter
+	del    dots.DrainDelete
+}
+
+func (s *fakeDrainService) FindDrain(ctx context.Context, filter dots.DrainFilter) ([]*dots.Drain, int, error) {
+	s.filter = filter
+	return []*dots.Drain{{DeedID: 1, EntryID: 2, Quantity: 3}}, 1, nil
+}
+
+func (s *fakeDrainService) UpdateDrain(ctx context.Context, deedID, entryID int, upd dots.DrainUpdate) (*dots.Drain, error) {
+	if *upd.Quantity > 10 {
+		return nil, dots.Errorf(dots.ECONFLICT, "not enough entries")
+	}
+	return &dots.Drain{DeedID: deedID, EntryID: entryID, Quantity: *upd.Quantity}, nil
+}
+
+func (s *fakeDrainService) DeleteDrain(ctx context.Context, deedID, entryID int, filter dots.DrainDelete) (int, error) {
+	s.del = filter
+	return 1, nil
+}
+
+func newDrainTestServer() (*Server, *fakeDrainService) {
+	s, _ := newTestServer()
+	ds := &fakeDrainService{}
+	s.DrainService = ds
+	return s, ds
+}
+
+func TestServer_handleDrainFind(t *testing.T) {
+	s, ds := newDrainTestServer()
+
+	w := serve(s, "GET", "/v1/drains?deed_id=1&entry_type_id=4&is_deleted=true", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if ds.filter.DeedID == nil || *ds.filter.DeedID != 1 || ds.filter.EntryTypeID == nil || *ds.filter.EntryTypeID != 4 {
+		t.Fatalf("filter=%+v", ds.filter)
+	}
+	if ds.filter.IsDeleted == nil || !*ds.filter.IsDeleted {
+		t.Fatalf("is_deleted not passed")
+	}
+
+	var got foundResponse[[]*dots.Drain]
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.N != 1 || got.Data[0].Quantity != 3 {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestServer_handleDrainPatch(t *testing.T) {
+	t.Run("Update", func(t *testing.T) {
+		s, _ := newDrainTestServer()
+
+		w := serve(s, "PATCH", "/v1/drains/1/2", `{"quantity":2.5}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+		}
+		var d dots.Drain
+		if err := json.NewDecoder(w.Body).Decode(&d); err != nil {
+			t.Fatal(err)
+		}
+		if d.DeedID != 1 || d.EntryID != 2 || d.Quantity != 2.5 {
+			t.Fatalf("drain=%+v", d)
+		}
+	})
+
+	t.Run("ErrNotEnough", func(t *testing.T) {
+		s, _ := newDrainTestServer()
+
+		if w := serve(s, "PATCH", "/v1/drains/1/2", `{"quantity":20}`); w.Code != http.StatusConflict {
+			t.Fatalf("status=%d, want %d", w.Code, http.StatusConflict)
+		}
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		s, ds := newDrainTestServer()
+
+		if w := serve(s, "PATCH", "/v1/drains/1/2?del&resurect", ""); w.Code != http.StatusOK {
+			t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+		}
+		if !ds.del.Resurect {
+			t.Fatal("expected resurect")
+		}
+	})
+
+	t.Run("ErrID", func(t *testing.T) {
+		s, _ := newDrainTestServer()
+
+		if w := serve(s, "PATCH", "/v1/drains/x/2?del", ""); w.Code != http.StatusBadRequest {
+			t.Fatalf("status=%d, want %d", w.Code, http.StatusBadRequest)
+		}
+	})
+}
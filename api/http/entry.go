@@ -10,8 +10,13 @@ import (
 
 func (s *Server) registerEntryRoutes(router *mux.Router) {
 	router.HandleFunc("", s.handleEntryCreate).Methods("POST")
+	router.HandleFunc("/restore", s.handleEntryRestore).Methods("POST")
 	router.HandleFunc("/{id}", s.handleEntryPatch).Methods("PATCH")
 	router.HandleFunc("", s.handleEntryFind).Methods("GET")
+	router.HandleFunc("/stock", s.handleStock).Methods("GET")
+	router.HandleFunc("/expiring", s.handleExpiring).Methods("GET")
+	router.HandleFunc("/value", s.handleStockValue).Methods("GET")
+	router.HandleFunc("/ledger", s.handleLedger).Methods("GET")
 	router.HandleFunc("/{id}", s.handleEntryHardDelete).Methods("DELETE")
 }
 
@@ -131,3 +136,44 @@ type findEntryResponse struct {
 type deleteEntryResponse struct {
 	N int `json:"n"`
 }
+
+func (s *Server) handleEntryRestore(w http.ResponseWriter, r *http.Request) {
+	var restore dots.TrashRestore
+	if ok := inputJSON(w, r, &restore, "restore entries"); !ok {
+		return
+	}
+
+	n, err := s.EntryService.RestoreEntry(r.Context(), restore)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &affected{n})
+}
+
+func (s *Server) handleStock(w http.ResponseWriter, r *http.Request) {
+	filter := dots.StockFilter{}
+	input(w, r, &filter, "stock")
+
+	ll, n, err := s.EntryService.FindStock(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &foundResponse[[]*dots.StockLevel]{ll, affected{n}})
+}
+
+func (s *Server) handleExpiring(w http.ResponseWriter, r *http.Request) {
+	filter := dots.ExpiringFilter{}
+	input(w, r, &filter, "expiring")
+
+	ee, n, err := s.EntryService.FindExpiring(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &foundResponse[[]*dots.ExpiringStock]{ee, affected{n}})
+}
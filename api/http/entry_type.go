@@ -10,6 +10,7 @@ import (
 
 func (s *Server) registerEntryTypeRoutes(router *mux.Router) {
 	router.HandleFunc("", s.handleEntryTypeCreate).Methods("POST")
+	router.HandleFunc("/restore", s.handleEntryTypeRestore).Methods("POST")
 	router.HandleFunc("/{id}", s.handleEntryTypePatch).Methods("PATCH")
 	router.HandleFunc("", s.handleEntryTypeUnitFind).Methods("GET").Queries("units", "{^$}")
 	router.HandleFunc("", s.handleEntryTypeStats).Methods("GET").Queries("stats", "{^$}", "id", "{^$\\d+$}", "kind", "default")
@@ -73,7 +74,7 @@ func (s *Server) handleEntryTypeFind(w http.ResponseWriter, r *http.Request) {
 	//input(w, r, &filter, "find entry type")
 
 	filterOrdered := dots.EntryTypeFilterOrdered{}
-	keys := []string{"id", "code", "description", "unit", "limit", "offset", "_mask_id", "_mask_code", "_mask_description", "_mask_unit"}
+	keys := []string{"id", "code", "description", "unit", "limit", "offset", "_mask_id", "_mask_code", "_mask_description", "_mask_unit", "is_deleted", "deleted_at_from", "deleted_at_to"}
 	qp := r.URL.Query()
 
 	for k, vv := range qp {
@@ -113,6 +114,21 @@ func (s *Server) handleEntryTypeFind(w http.ResponseWriter, r *http.Request) {
 			filterOrdered.MaskDescription = qp.Get(k)
 		case "_mask_unit":
 			filterOrdered.MaskUnit = qp.Get(k)
+		case "is_deleted":
+			if v, err := strconv.ParseBool(qp.Get(k)); err == nil {
+				filterOrdered.IsDeleted = &v
+			}
+		case "deleted_at_from", "deleted_at_to":
+			var v dots.PartialTime
+			if err := v.UnmarshalJSON([]byte(strconv.Quote(qp.Get(k)))); err != nil {
+				Error(w, r, dots.Errorf(dots.EINVALID, "find entry type: bad %s", k))
+				return
+			}
+			if k == "deleted_at_from" {
+				filterOrdered.DeletedAtFrom = &v
+			} else {
+				filterOrdered.DeletedAtTo = &v
+			}
 		}
 	}
 
@@ -204,3 +220,18 @@ func (s *Server) handleEntryTypeHardDelete(w http.ResponseWriter, r *http.Reques
 
 	outputJSON(w, r, http.StatusOK, &affected{n})
 }
+
+func (s *Server) handleEntryTypeRestore(w http.ResponseWriter, r *http.Request) {
+	var restore dots.TrashRestore
+	if ok := inputJSON(w, r, &restore, "restore entry types"); !ok {
+		return
+	}
+
+	n, err := s.EntryTypeService.RestoreEntryType(r.Context(), restore)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &affected{n})
+}
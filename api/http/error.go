@@ -40,6 +40,8 @@ var codes = map[string]int{
 	dots.ENOTFOUND:       http.StatusNotFound,
 	dots.ENOTIMPLEMENTED: http.StatusNotImplemented,
 	dots.EUNAUTHORIZED:   http.StatusUnauthorized,
+	dots.EQUOTA:          http.StatusForbidden,
+	dots.ETOOMANY:        http.StatusTooManyRequests,
 	dots.EINTERNAL:       http.StatusInternalServerError,
 }
 
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/innermond/dots"
+	"github.com/segmentio/ksuid"
+)
+
+type fakeTokenService struct {
+	dots.TokenService
+
+	payload *dots.TokenPayload
+	revoked map[ksuid.KSUID]bool
+
+	revokedRefresh string
+}
+
+func (s *fakeTokenService) Read(ctx context.Context, str string) (*dots.TokenPayload, error) {
+	return s.payload, nil
+}
+
+func (s *fakeTokenService) IsRevoked(ctx context.Context, id ksuid.KSUID) (bool, error) {
+	return s.revoked[id], nil
+}
+
+func (s *fakeTokenService) Revoke(ctx context.Context, p *dots.TokenPayload, refresh string) error {
+	s.revoked[p.ID] = true
+	s.revokedRefresh = refresh
+	return nil
+}
+
+type fakeUserService struct {
+	dots.UserService
+}
+
+func (s *fakeUserService) FindUserByID(ctx context.Context, id ksuid.KSUID) (*dots.User, error) {
+	return &dots.User{ID: id, ApiKey: "API_KEY", Powers: dots.PowerToManageOwn}, nil
+}
+
+func newTestServer() (*Server, *fakeTokenService) {
+	ts := &fakeTokenService{
+		payload: &dots.TokenPayload{ID: ksuid.New(), UID: ksuid.New()},
+		revoked: map[ksuid.KSUID]bool{},
+	}
+
+	s := NewServer()
+	s.TokenService = ts
+	s.UserService = &fakeUserService{}
+	s.OrganisationService = &fakeOrganisationService{}
+	s.LoginEventService = &fakeLoginEventService{}
+
+	return s, ts
+}
+
+// serve sends a request carrying a bearer token through the router
+func serve(s *Server, method, target, body string) *httptest.ResponseRecorder {
+	var r *http.Request
+	if body == "" {
+		r = httptest.NewRequest(method, target, nil)
+	} else {
+		r = httptest.NewRequest(method, target, strings.NewReader(body))
+	}
+	r.Header.Set("Authorization", "Bearer TOKEN")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, r)
+	return w
+}
@@ -102,9 +102,8 @@ func unknownFieldsJSON(s interface{}, m map[string]interface{}) ([]string, error
 				if err != nil {
 					return nil, err
 				}
-				if len(nestedUnknownFields) > 0 {
-					unknownFields = append(unknownFields, nestedUnknownFields...)
-				} else {
+				// a field unknown here may still be a sibling of the nested struct
+				if len(nestedUnknownFields) == 0 {
 					// no unknown fields means we found the wanted in this struct
 					found = true
 					break
@@ -156,7 +155,7 @@ func queryInto[T any](qp url.Values, s *T) error {
 	v := reflect.ValueOf(s).Elem()
 	for i := 0; i < e.NumField(); i++ {
 		f := e.Field(i)
-		fn := f.Tag.Get("json")
+		fn, _, _ := strings.Cut(f.Tag.Get("json"), ",")
 		if fn == "" {
 			fn = f.Name
 		}
@@ -201,6 +200,29 @@ func queryInto[T any](qp url.Values, s *T) error {
 					return err
 				}
 				fv.Set(reflect.ValueOf(&iv))
+			case reflect.Float64:
+				f, err := strconv.ParseFloat(pv, 64)
+				if err != nil {
+					return err
+				}
+				fv.Set(reflect.ValueOf(&f))
+			case reflect.Bool:
+				bv, err := strconv.ParseBool(pv)
+				if err != nil {
+					return err
+				}
+				fv.Set(reflect.ValueOf(&bv))
+			default:
+				// types as dots.PartialTime know how to read themselves
+				pt := reflect.New(fv.Type().Elem())
+				u, ok := pt.Interface().(json.Unmarshaler)
+				if !ok {
+					continue
+				}
+				if err := u.UnmarshalJSON([]byte(strconv.Quote(pv))); err != nil {
+					return err
+				}
+				fv.Set(pt)
 			}
 		}
 	}
@@ -208,7 +230,7 @@ func queryInto[T any](qp url.Values, s *T) error {
 }
 
 type Filter interface {
-	dots.StatsFilter | dots.CompanyFilter | dots.EntryTypeFilter | dots.EntryFilter | dots.DeedFilter | dots.DeedDelete
+	dots.StatsFilter | dots.CompanyFilter | dots.EntryTypeFilter | dots.EntryFilter | dots.DeedFilter | dots.DeedDelete | dots.PersonalTokenFilter | dots.UserFilter | dots.LoginEventFilter | dots.DrainFilter | dots.StockValueFilter | dots.DeedCostFilter | dots.ProfitFilter | dots.LedgerFilter | dots.StockFilter | dots.StockThresholdFilter | dots.StockAlertFilter | dots.ForecastFilter | dots.UnitConversionFilter | dots.UnitConvert | dots.TransferFilter | dots.InventoryCountFilter | dots.ExpiringFilter | dots.ReservationFilter
 }
 
 func input[T Filter](w http.ResponseWriter, r *http.Request, filterPtr *T, msg string) {
@@ -230,7 +252,7 @@ type affected struct {
 }
 
 type data interface {
-	[]*dots.Company | *dots.CompanyStats | []*dots.CompanyDepletion | []*dots.EntryType | []*dots.Entry | []*dots.Deed | []string | map[string]string
+	[]*dots.Company | *dots.CompanyStats | []*dots.CompanyDepletion | []*dots.EntryType | []*dots.Entry | []*dots.Deed | []*dots.PersonalToken | []*dots.User | []*dots.Organisation | []*dots.Member | []*dots.LoginEvent | []*dots.Drain | []*dots.StockValue | []*dots.LedgerLine | []*dots.StockLevel | []*dots.StockThreshold | []*dots.StockAlert | []*dots.CompanyForecast | []*dots.Unit | []*dots.UnitConversion | []*dots.Transfer | []*dots.InventoryCount | []*dots.ExpiringStock | []*dots.Reservation | []string | map[string]string
 }
 
 type foundResponse[T data] struct {
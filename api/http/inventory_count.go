@@ -0,0 +1,98 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/innermond/dots"
+)
+
+func (s *Server) registerInventoryCountRoutes(router *mux.Router) {
+	router.HandleFunc("", s.handleInventoryCountCreate).Methods("POST")
+	router.HandleFunc("", s.handleInventoryCountFind).Methods("GET")
+	router.HandleFunc("/{id}", s.handleInventoryCountUpdate).Methods("PATCH")
+	router.HandleFunc("/{id}/approve", s.handleInventoryCountApprove).Methods("POST")
+	router.HandleFunc("/{id}/cancel", s.handleInventoryCountCancel).Methods("POST")
+}
+
+func (s *Server) handleInventoryCountCreate(w http.ResponseWriter, r *http.Request) {
+	var ic dots.InventoryCount
+	if ok := inputJSON(w, r, &ic, "create inventory count"); !ok {
+		return
+	}
+
+	err := s.InventoryCountService.CreateInventoryCount(r.Context(), &ic)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusCreated, &ic)
+}
+
+func (s *Server) handleInventoryCountFind(w http.ResponseWriter, r *http.Request) {
+	filter := dots.InventoryCountFilter{}
+	input(w, r, &filter, "find inventory count")
+
+	cc, n, err := s.InventoryCountService.FindInventoryCount(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &foundResponse[[]*dots.InventoryCount]{cc, affected{n}})
+}
+
+func (s *Server) handleInventoryCountUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, dots.Errorf(dots.EINVALID, "invalid ID format"))
+		return
+	}
+
+	var upd dots.InventoryCountUpdate
+	if ok := inputJSON(w, r, &upd, "update inventory count"); !ok {
+		return
+	}
+
+	ic, err := s.InventoryCountService.UpdateInventoryCount(r.Context(), id, upd)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, ic)
+}
+
+func (s *Server) handleInventoryCountApprove(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, dots.Errorf(dots.EINVALID, "invalid ID format"))
+		return
+	}
+
+	ic, err := s.InventoryCountService.ApproveInventoryCount(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, ic)
+}
+
+func (s *Server) handleInventoryCountCancel(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, dots.Errorf(dots.EINVALID, "invalid ID format"))
+		return
+	}
+
+	ic, err := s.InventoryCountService.CancelInventoryCount(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, ic)
+}
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/innermond/dots"
+)
+
+type fakeInventoryCountService struct {
+	dots.InventoryCountService
+
+	updated  *dots.InventoryCountUpdate
+	approved int
+	closed   bool
+}
+
+func (s *fakeInventoryCountService) UpdateInventoryCount(ctx context.Context, id int, upd dots.InventoryCountUpdate) (*dots.InventoryCount, error) {
+	s.updated = &upd
+	return &dots.InventoryCount{ID: id, Status: dots.CountOpen, Lines: upd.Lines}, nil
+}
+
+func (s *fakeInventoryCountService) ApproveInventoryCount(ctx context.Context, id int) (*dots.InventoryCount, error) {
+	if s.closed {
+		return nil, dots.Errorf(dots.ECONFLICT, "inventory count is %s", dots.CountApproved)
+	}
+	s.approved, s.closed = id, true
+	return &dots.InventoryCount{ID: id, Status: dots.CountApproved, Lines: []*dots.InventoryCountLine{}}, nil
+}
+
+func TestServer_handleInventoryCountUpdate(t *testing.T) {
+	s, _ := newTestServer()
+	cs := &fakeInventoryCountService{}
+	s.InventoryCountService = cs
+
+	w := serve(s, "PATCH", "/v1/inventory-counts/4", `{"lines": [{"company_id": 1, "entry_type_id": 2, "counted": 9, "reason": "damage"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if cs.updated == nil || len(cs.updated.Lines) != 1 || *cs.updated.Lines[0].Reason != dots.ReasonDamage {
+		t.Fatalf("updated=%+v", cs.updated)
+	}
+}
+
+func TestServer_handleInventoryCountApprove(t *testing.T) {
+	s, _ := newTestServer()
+	cs := &fakeInventoryCountService{}
+	s.InventoryCountService = cs
+
+	w := serve(s, "POST", "/v1/inventory-counts/6/approve", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var got dots.InventoryCount
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if cs.approved != 6 || got.Status != dots.CountApproved {
+		t.Fatalf("approved=%d, got %+v", cs.approved, got)
+	}
+
+	w = serve(s, "POST", "/v1/inventory-counts/6/approve", "")
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status=%d, want %d", w.Code, http.StatusConflict)
+	}
+
+	w = serve(s, "POST", "/v1/inventory-counts/x/approve", "")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status=%d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/innermond/dots"
+)
+
+func (s *Server) handleLedger(w http.ResponseWriter, r *http.Request) {
+	filter := dots.LedgerFilter{}
+	input(w, r, &filter, "ledger")
+
+	ll, n, err := s.LedgerService.FindLedger(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "csv" {
+		outputLedgerCSV(w, r, ll)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &foundResponse[[]*dots.LedgerLine]{ll, affected{n}})
+}
+
+func outputLedgerCSV(w http.ResponseWriter, r *http.Request, ll []*dots.LedgerLine) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="ledger.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"company_id", "entry_type_id", "at", "kind", "entry_id", "deed_id", "quantity", "balance"})
+	for _, l := range ll {
+		deedID := ""
+		if l.DeedID != nil {
+			deedID = strconv.Itoa(*l.DeedID)
+		}
+		cw.Write([]string{
+			strconv.Itoa(l.CompanyID),
+			strconv.Itoa(l.EntryTypeID),
+			l.At.Format(time.RFC3339),
+			l.Kind,
+			strconv.Itoa(l.EntryID),
+			deedID,
+			strconv.FormatFloat(l.Quantity, 'f', -1, 64),
+			strconv.FormatFloat(l.Balance, 'f', -1, 64),
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		LogError(r, err)
+	}
+}
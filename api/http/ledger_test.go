@@ -0,0 +1,35 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/innermond/dots"
+)
+
+type fakeLedgerService struct {
+	filter dots.LedgerFilter
+}
+
+func (s *fakeLedgerService) FindLedger(ctx context.Context, filter dots.LedgerFilter) ([]*dots.LedgerLine, int, error) {
+	s.filter = filter
+	return []*dots.LedgerLine{}, 0, nil
+}
+
+func TestServer_handleLedger(t *testing.T) {
+	s, _ := newTestServer()
+	ls := &fakeLedgerService{}
+	s.LedgerService = ls
+
+	w := serve(s, "GET", "/v1/entries/ledger?company_id=1&entry_type_id=2&from=2023-04&format=csv", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/csv" {
+		t.Fatalf("content type %q", ct)
+	}
+	if ls.filter.CompanyID == nil || *ls.filter.CompanyID != 1 || ls.filter.EntryTypeID == nil || ls.filter.From == nil || ls.filter.To != nil {
+		t.Fatalf("filter=%+v", ls.filter)
+	}
+}
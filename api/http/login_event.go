@@ -0,0 +1,36 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/innermond/dots"
+)
+
+func (s *Server) handleLoginEventFind(w http.ResponseWriter, r *http.Request) {
+	// can accept missing r.Body
+	filter := dots.LoginEventFilter{}
+	input(w, r, &filter, "find login event")
+
+	ee, n, err := s.LoginEventService.FindLoginEvent(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &foundResponse[[]*dots.LoginEvent]{ee, affected{n}})
+}
+
+// auditLogin records where an attempt to get in came from,
+// losing the record must not fail the login
+func (s *Server) auditLogin(r *http.Request, e *dots.LoginEvent) {
+	if err := s.LoginEventService.CreateLoginEvent(r.Context(), s.loginEvent(r, e)); err != nil {
+		LogError(r, err)
+	}
+}
+
+// loginEvent tells where the attempt of e came from
+func (s *Server) loginEvent(r *http.Request, e *dots.LoginEvent) *dots.LoginEvent {
+	e.IP = s.clientIP(r)
+	e.UserAgent = r.UserAgent()
+	return e
+}
@@ -0,0 +1,156 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/innermond/dots"
+)
+
+type fakeLoginEventService struct {
+	events []*dots.LoginEvent
+	wait   time.Duration
+}
+
+func (s *fakeLoginEventService) CreateLoginEvent(ctx context.Context, e *dots.LoginEvent) error {
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (s *fakeLoginEventService) FindLoginEvent(ctx context.Context, filter dots.LoginEventFilter) ([]*dots.LoginEvent, int, error) {
+	return s.events, len(s.events), nil
+}
+
+func (s *fakeLoginEventService) PasswordLogin(ctx context.Context, e *dots.LoginEvent, login func() error) (time.Duration, error) {
+	e.Kind = dots.LoginPassword
+	if s.wait > 0 {
+		e.Reason = dots.LoginReasonLocked
+		s.events = append(s.events, e)
+		return s.wait, nil
+	}
+
+	err := login()
+	e.Success = err == nil
+	if err != nil {
+		e.Reason = dots.ErrorMessage(err)
+	}
+	s.events = append(s.events, e)
+	return 0, err
+}
+
+func (s *fakeTokenService) Create(ctx context.Context, cc dots.TokenCredentials) (*dots.TokenPair, error) {
+	if cc.Pass != "secret" {
+		return nil, dots.Errorf(dots.EUNAUTHORIZED, "invalid credentials")
+	}
+	return &dots.TokenPair{Access: "ACCESS", Refresh: "REFRESH"}, nil
+}
+
+func TestServer_handleTokeningAudit(t *testing.T) {
+	login := func(s *Server, pass string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/v1/login", strings.NewReader(`{"usr":"a@example.com","pwd":"`+pass+`"}`))
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.Header.Set("User-Agent", "tester")
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		s, _ := newTestServer()
+		les := &fakeLoginEventService{}
+		s.LoginEventService = les
+
+		if w := login(s, "secret"); w.Code != http.StatusOK {
+			t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+		}
+		if len(les.events) != 1 {
+			t.Fatalf("events=%d, want 1", len(les.events))
+		}
+		e := les.events[0]
+		if !e.Success || e.Kind != dots.LoginPassword || e.Email != "a@example.com" {
+			t.Fatalf("event=%+v", e)
+		}
+		if e.IP != "192.0.2.1" || e.UserAgent != "tester" {
+			t.Fatalf("ip=%q user agent=%q", e.IP, e.UserAgent)
+		}
+	})
+
+	t.Run("ErrCredentials", func(t *testing.T) {
+		s, _ := newTestServer()
+		les := &fakeLoginEventService{}
+		s.LoginEventService = les
+
+		if w := login(s, "wrong"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("status=%d, want %d", w.Code, http.StatusUnauthorized)
+		}
+		if len(les.events) != 1 || les.events[0].Success || les.events[0].Reason != "invalid credentials" {
+			t.Fatalf("events=%+v", les.events)
+		}
+	})
+
+	t.Run("Locked", func(t *testing.T) {
+		s, _ := newTestServer()
+		les := &fakeLoginEventService{wait: 90 * time.Second}
+		s.LoginEventService = les
+
+		w := login(s, "secret")
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("status=%d, want %d", w.Code, http.StatusTooManyRequests)
+		}
+		if got := w.Header().Get("Retry-After"); got != "90" {
+			t.Fatalf("Retry-After=%q, want 90", got)
+		}
+		if len(les.events) != 1 || les.events[0].Reason != dots.LoginReasonLocked {
+			t.Fatalf("events=%+v", les.events)
+		}
+	})
+}
+
+func TestServer_handleLoginEventFind(t *testing.T) {
+	s, ts := newTestServer()
+	uid := ts.payload.UID
+	s.LoginEventService = &fakeLoginEventService{events: []*dots.LoginEvent{
+		{ID: 1, UserID: &uid, Kind: dots.LoginPassword, IP: "192.0.2.1"},
+	}}
+
+	w := serve(s, "GET", "/v1/me/security/events", "")
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var got foundResponse[[]*dots.LoginEvent]
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.N != 1 || got.Data[0].IP != "192.0.2.1" {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestLockoutPolicy_Wait(t *testing.T) {
+	p := dots.LockoutPolicy{Threshold: 3, Base: time.Minute, Max: 10 * time.Minute}
+	last := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		failures int
+		after    time.Duration
+		want     time.Duration
+	}{
+		{2, 0, 0},
+		{3, 0, time.Minute},
+		{4, 0, 2 * time.Minute},
+		{5, 30 * time.Second, 4*time.Minute - 30*time.Second},
+		{20, 0, 10 * time.Minute},
+		{3, 2 * time.Minute, 0},
+	}
+	for _, tt := range tests {
+		if got := p.Wait(tt.failures, last, last.Add(tt.after)); got != tt.want {
+			t.Errorf("Wait(%d, +%s)=%s, want %s", tt.failures, tt.after, got, tt.want)
+		}
+	}
+}
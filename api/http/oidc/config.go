@@ -0,0 +1,49 @@
+package oidc
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+// LoadFile reads a json array of provider configs
+func LoadFile(path string) ([]Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cc []Config
+	if err := json.Unmarshal(b, &cc); err != nil {
+		return nil, err
+	}
+
+	return cc, nil
+}
+
+// FromEnv reads providers listed by DOTS_OIDC_PROVIDERS, for each name
+// DOTS_OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET and _SCOPES are looked up
+func FromEnv(getenv func(string) string) []Config {
+	names := splitList(getenv("DOTS_OIDC_PROVIDERS"))
+
+	cc := []Config{}
+	for _, name := range names {
+		prefix := "DOTS_OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		cc = append(cc, Config{
+			Name:         name,
+			Issuer:       getenv(prefix + "ISSUER"),
+			ClientID:     getenv(prefix + "CLIENT_ID"),
+			ClientSecret: getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       splitList(getenv(prefix + "SCOPES")),
+			RedirectBase: getenv(prefix + "REDIRECT_BASE"),
+		})
+	}
+
+	return cc
+}
+
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
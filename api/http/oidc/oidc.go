@@ -0,0 +1,236 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrProviderNotFound = errors.New("oidc: provider not found")
+	ErrIssuerMismatch   = errors.New("oidc: issuer mismatch")
+)
+
+// Config describes a provider as written in env or config file
+type Config struct {
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+	RedirectBase string   `json:"redirect_base"`
+}
+
+func (c Config) Validate() error {
+	if c.Name == "" || c.Issuer == "" || c.ClientID == "" {
+		return fmt.Errorf("oidc: provider %q needs name, issuer and client id", c.Name)
+	}
+	return nil
+}
+
+// RedirectURL is where the provider sends the user back
+func (c Config) RedirectURL() string {
+	return strings.TrimRight(c.RedirectBase, "/") + "/oauth/" + c.Name + "/callback"
+}
+
+type discovery struct {
+	Issuer      string `json:"issuer"`
+	AuthURL     string `json:"authorization_endpoint"`
+	TokenURL    string `json:"token_endpoint"`
+	UserInfoURL string `json:"userinfo_endpoint"`
+	JWKSURL     string `json:"jwks_uri"`
+}
+
+// Provider is a discovered issuer able to verify its own ID tokens
+type Provider struct {
+	Config
+
+	client      *http.Client
+	endpoint    oauth2.Endpoint
+	userInfoURL string
+	jwksURL     string
+
+	mu   sync.Mutex
+	keys map[string]interface{}
+
+	Now func() time.Time
+}
+
+// Discover reads the issuer's openid-configuration
+func Discover(ctx context.Context, client *http.Client, cfg Config) (*Provider, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	wellKnown := strings.TrimRight(cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	var d discovery
+	if err := getJSON(ctx, client, wellKnown, &d); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimRight(d.Issuer, "/") != strings.TrimRight(cfg.Issuer, "/") {
+		return nil, fmt.Errorf("%w: want %s got %s", ErrIssuerMismatch, cfg.Issuer, d.Issuer)
+	}
+	if d.AuthURL == "" || d.TokenURL == "" || d.JWKSURL == "" {
+		return nil, fmt.Errorf("oidc: incomplete discovery for %s", cfg.Issuer)
+	}
+
+	return &Provider{
+		Config:      cfg,
+		client:      client,
+		endpoint:    oauth2.Endpoint{AuthURL: d.AuthURL, TokenURL: d.TokenURL},
+		userInfoURL: d.UserInfoURL,
+		jwksURL:     d.JWKSURL,
+		Now:         time.Now,
+	}, nil
+}
+
+func (p *Provider) OAuth2Config() *oauth2.Config {
+	scopes := p.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		Scopes:       scopes,
+		RedirectURL:  p.RedirectURL(),
+		Endpoint:     p.endpoint,
+	}
+}
+
+// Client is the http client used toward the provider
+func (p *Provider) Client() *http.Client {
+	return p.client
+}
+
+// UserInfo completes the claims when the ID token is too thin
+func (p *Provider) UserInfo(ctx context.Context, client *http.Client, claims *Claims) error {
+	if p.userInfoURL == "" {
+		return nil
+	}
+
+	var info Claims
+	if err := getJSON(ctx, client, p.userInfoURL, &info); err != nil {
+		return err
+	}
+	// userinfo must talk about the same subject
+	if info.Subject != claims.Subject {
+		return errors.New("oidc: userinfo subject mismatch")
+	}
+
+	if claims.Email == "" {
+		claims.Email = info.Email
+		claims.EmailVerified = info.EmailVerified
+	}
+	if claims.Name == "" {
+		claims.Name = info.Name
+	}
+	if claims.PreferredUsername == "" {
+		claims.PreferredUsername = info.PreferredUsername
+	}
+
+	return nil
+}
+
+// Registry holds providers by name, discovering each one on first use
+type Registry struct {
+	client *http.Client
+
+	mu        sync.Mutex
+	configs   map[string]Config
+	providers map[string]*Provider
+}
+
+func NewRegistry(client *http.Client) *Registry {
+	return &Registry{
+		client:    client,
+		configs:   map[string]Config{},
+		providers: map[string]*Provider{},
+	}
+}
+
+func (r *Registry) Add(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.configs[cfg.Name] = cfg
+	delete(r.providers, cfg.Name)
+
+	return nil
+}
+
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := []string{}
+	for name := range r.configs {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (r *Registry) Provider(ctx context.Context, name string) (*Provider, error) {
+	if r == nil {
+		return nil, ErrProviderNotFound
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if p, found := r.providers[name]; found {
+		return p, nil
+	}
+
+	cfg, found := r.configs[name]
+	if !found {
+		return nil, ErrProviderNotFound
+	}
+
+	p, err := Discover(ctx, r.client, cfg)
+	if err != nil {
+		return nil, err
+	}
+	r.providers[name] = p
+
+	return p, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("oidc: %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: %s responded %d", url, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("oidc: cannot decode %s: %w", url, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+// Package oidctest runs a local openid connect issuer for tests
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+const (
+	KeyID       = "test-key"
+	AccessToken = "ACCESS_TOKEN"
+)
+
+// Server issues ID tokens for a single client, claims given to the token
+// endpoint are the ones set with SetClaims
+type Server struct {
+	*httptest.Server
+
+	ClientID string
+	Key      *rsa.PrivateKey
+
+	mu     sync.Mutex
+	claims map[string]interface{}
+}
+
+func NewServer(clientID string) *Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	s := &Server{ClientID: clientID, Key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.handleDiscovery)
+	mux.HandleFunc("/keys", s.handleKeys)
+	mux.HandleFunc("/token", s.handleToken)
+	mux.HandleFunc("/userinfo", s.handleUserInfo)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+func (s *Server) Issuer() string {
+	return s.URL
+}
+
+// DefaultClaims are valid claims for the client, to be altered by tests
+func (s *Server) DefaultClaims() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":            s.Issuer(),
+		"sub":            "SUBJECT",
+		"aud":            s.ClientID,
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"email":          "user@example.com",
+		"email_verified": true,
+		"name":           "Test User",
+	}
+}
+
+func (s *Server) SetClaims(claims map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.claims = claims
+}
+
+// Sign makes a RS256 JWT
+func (s *Server) Sign(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": KeyID, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.Key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (s *Server) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{
+		"issuer":                 s.Issuer(),
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"userinfo_endpoint":      s.URL + "/userinfo",
+		"jwks_uri":               s.URL + "/keys",
+	})
+}
+
+func (s *Server) handleKeys(w http.ResponseWriter, r *http.Request) {
+	pub := s.Key.PublicKey
+	writeJSON(w, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": KeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	claims := s.claims
+	s.mu.Unlock()
+	if claims == nil {
+		claims = s.DefaultClaims()
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"access_token": AccessToken,
+		"token_type":   "bearer",
+		"expires_in":   3600,
+		"id_token":     s.Sign(claims),
+	})
+}
+
+func (s *Server) handleUserInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+AccessToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	claims := s.claims
+	s.mu.Unlock()
+	if claims == nil {
+		claims = s.DefaultClaims()
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"sub":            claims["sub"],
+		"email":          "userinfo@example.com",
+		"email_verified": true,
+		"name":           "Userinfo Name",
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,134 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/innermond/dots/http/oidc"
+	"github.com/innermond/dots/http/oidc/oidctest"
+)
+
+func discover(t *testing.T, issuer *oidctest.Server) *oidc.Provider {
+	t.Helper()
+
+	p, err := oidc.Discover(context.Background(), nil, oidc.Config{
+		Name:     "test",
+		Issuer:   issuer.Issuer(),
+		ClientID: issuer.ClientID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestDiscover(t *testing.T) {
+	t.Run("ErrIssuerMismatch", func(t *testing.T) {
+		issuer := oidctest.NewServer("CLIENT_ID")
+		defer issuer.Close()
+
+		_, err := oidc.Discover(context.Background(), nil, oidc.Config{
+			Name:     "test",
+			Issuer:   issuer.Issuer() + "/other",
+			ClientID: "CLIENT_ID",
+		})
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func TestProvider_Verify(t *testing.T) {
+	issuer := oidctest.NewServer("CLIENT_ID")
+	defer issuer.Close()
+
+	t.Run("OK", func(t *testing.T) {
+		p := discover(t, issuer)
+
+		claims := issuer.DefaultClaims()
+		claims["nonce"] = "NONCE"
+		got, err := p.Verify(context.Background(), issuer.Sign(claims), "NONCE")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Subject != "SUBJECT" || got.Email != "user@example.com" || !got.EmailVerified {
+			t.Fatalf("unexpected claims %+v", got)
+		}
+	})
+
+	t.Run("AudienceArray", func(t *testing.T) {
+		p := discover(t, issuer)
+
+		claims := issuer.DefaultClaims()
+		claims["aud"] = []string{"OTHER", "CLIENT_ID"}
+		if _, err := p.Verify(context.Background(), issuer.Sign(claims), ""); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("ErrTokenSignature", func(t *testing.T) {
+		p := discover(t, issuer)
+
+		other := oidctest.NewServer("CLIENT_ID")
+		defer other.Close()
+
+		claims := issuer.DefaultClaims()
+		if _, err := p.Verify(context.Background(), other.Sign(claims), ""); !errors.Is(err, oidc.ErrTokenSignature) {
+			t.Fatalf("err=%v, want %v", err, oidc.ErrTokenSignature)
+		}
+	})
+
+	t.Run("ErrTokenMalformed", func(t *testing.T) {
+		p := discover(t, issuer)
+
+		if _, err := p.Verify(context.Background(), "not.a-token", ""); !errors.Is(err, oidc.ErrTokenMalformed) {
+			t.Fatalf("err=%v, want %v", err, oidc.ErrTokenMalformed)
+		}
+	})
+
+	claimsErrors := map[string]func(map[string]interface{}){
+		"ErrAudience": func(c map[string]interface{}) { c["aud"] = "OTHER" },
+		"ErrIssuer":   func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" },
+		"ErrExpired":  func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"ErrFuture":   func(c map[string]interface{}) { c["iat"] = time.Now().Add(time.Hour).Unix() },
+		"ErrNonce":    func(c map[string]interface{}) { c["nonce"] = "REPLAYED" },
+		"ErrSubject":  func(c map[string]interface{}) { delete(c, "sub") },
+	}
+	for name, alter := range claimsErrors {
+		alter := alter
+		t.Run(name, func(t *testing.T) {
+			p := discover(t, issuer)
+
+			claims := issuer.DefaultClaims()
+			claims["nonce"] = "NONCE"
+			alter(claims)
+			if _, err := p.Verify(context.Background(), issuer.Sign(claims), "NONCE"); !errors.Is(err, oidc.ErrTokenClaims) {
+				t.Fatalf("err=%v, want %v", err, oidc.ErrTokenClaims)
+			}
+		})
+	}
+}
+
+func TestRegistry_Provider(t *testing.T) {
+	issuer := oidctest.NewServer("CLIENT_ID")
+	defer issuer.Close()
+
+	reg := oidc.NewRegistry(nil)
+	if err := reg.Add(oidc.Config{Name: "test", Issuer: issuer.Issuer(), ClientID: "CLIENT_ID", RedirectBase: "http://localhost:8080/"}); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := reg.Provider(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.OAuth2Config().RedirectURL, "http://localhost:8080/oauth/test/callback"; got != want {
+		t.Fatalf("redirect=%v, want %v", got, want)
+	}
+
+	if _, err := reg.Provider(context.Background(), "missing"); !errors.Is(err, oidc.ErrProviderNotFound) {
+		t.Fatalf("err=%v, want %v", err, oidc.ErrProviderNotFound)
+	}
+}
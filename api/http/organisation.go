@@ -0,0 +1,168 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/innermond/dots"
+	"github.com/segmentio/ksuid"
+)
+
+func (s *Server) registerOrganisationRoutes(router *mux.Router) {
+	router.HandleFunc("", s.handleOrganisationCreate).Methods("POST")
+	router.HandleFunc("", s.handleOrganisationFind).Methods("GET")
+	router.HandleFunc("/{id}/switch", s.handleOrganisationSwitch).Methods("POST")
+	router.HandleFunc("/{id}/members", s.handleMemberFind).Methods("GET")
+	router.HandleFunc("/{id}/members", s.handleMemberAdd).Methods("POST")
+	router.HandleFunc("/{id}/members/{uid}", s.handleMemberUpdate).Methods("PATCH")
+	router.HandleFunc("/{id}/members/{uid}", s.handleMemberRemove).Methods("DELETE")
+}
+
+func (s *Server) handleOrganisationCreate(w http.ResponseWriter, r *http.Request) {
+	var o dots.Organisation
+	if ok := inputJSON(w, r, &o, "create organisation"); !ok {
+		return
+	}
+
+	if err := s.OrganisationService.CreateOrganisation(r.Context(), &o); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusCreated, &o)
+}
+
+func (s *Server) handleOrganisationFind(w http.ResponseWriter, r *http.Request) {
+	oo, n, err := s.OrganisationService.FindOrganisation(r.Context())
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &foundResponse[[]*dots.Organisation]{oo, affected{n}})
+}
+
+// handleOrganisationSwitch makes the organisation the active one,
+// inside the session for browsers and inside a new token pair for bearer clients
+func (s *Server) handleOrganisationSwitch(w http.ResponseWriter, r *http.Request) {
+	oid, ok := ksuidVar(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if payload := dots.TokenFromContext(r.Context()); payload != nil {
+		pair, err := s.TokenService.Switch(r.Context(), payload, oid)
+		if err != nil {
+			Error(w, r, err)
+			return
+		}
+
+		outputJSON(w, r, http.StatusOK, pair)
+		return
+	}
+
+	u := dots.UserFromContext(r.Context())
+	if _, err := s.OrganisationService.Membership(r.Context(), oid, u.ID); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	ses, err := s.getSession(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	ses.OrganisationID = oid
+	if err := s.setSession(w, ses); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleMemberFind(w http.ResponseWriter, r *http.Request) {
+	oid, ok := ksuidVar(w, r, "id")
+	if !ok {
+		return
+	}
+
+	mm, n, err := s.OrganisationService.FindMember(r.Context(), oid)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &foundResponse[[]*dots.Member]{mm, affected{n}})
+}
+
+func (s *Server) handleMemberAdd(w http.ResponseWriter, r *http.Request) {
+	oid, ok := ksuidVar(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var ma dots.MemberAdd
+	if ok := inputJSON(w, r, &ma, "add member"); !ok {
+		return
+	}
+
+	m, err := s.OrganisationService.AddMember(r.Context(), oid, ma)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusCreated, m)
+}
+
+func (s *Server) handleMemberUpdate(w http.ResponseWriter, r *http.Request) {
+	oid, ok := ksuidVar(w, r, "id")
+	if !ok {
+		return
+	}
+	uid, ok := ksuidVar(w, r, "uid")
+	if !ok {
+		return
+	}
+
+	var mu dots.MemberUpdate
+	if ok := inputJSON(w, r, &mu, "edit member"); !ok {
+		return
+	}
+
+	m, err := s.OrganisationService.UpdateMember(r.Context(), oid, uid, mu)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, m)
+}
+
+func (s *Server) handleMemberRemove(w http.ResponseWriter, r *http.Request) {
+	oid, ok := ksuidVar(w, r, "id")
+	if !ok {
+		return
+	}
+	uid, ok := ksuidVar(w, r, "uid")
+	if !ok {
+		return
+	}
+
+	if err := s.OrganisationService.RemoveMember(r.Context(), oid, uid); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func ksuidVar(w http.ResponseWriter, r *http.Request, name string) (ksuid.KSUID, bool) {
+	id, err := ksuid.Parse(mux.Vars(r)[name])
+	if err != nil {
+		Error(w, r, dots.Errorf(dots.EINVALID, "invalid ID format"))
+		return ksuid.Nil, false
+	}
+	return id, true
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/innermond/dots"
+	"github.com/segmentio/ksuid"
+)
+
+// fakeOrganisationService knows the personal organisation of everyone
+// plus the memberships listed in roles
+type fakeOrganisationService struct {
+	dots.OrganisationService
+
+	roles map[ksuid.KSUID]dots.Role
+}
+
+func (s *fakeOrganisationService) Membership(ctx context.Context, oid ksuid.KSUID, uid ksuid.KSUID) (dots.Role, error) {
+	if oid == uid {
+		return dots.RoleOwner, nil
+	}
+	if role, found := s.roles[oid]; found {
+		return role, nil
+	}
+	return "", dots.Errorf(dots.ENOTFOUND, "membership not found")
+}
+
+func (s *fakeOrganisationService) FindOrganisation(ctx context.Context) ([]*dots.Organisation, int, error) {
+	u := dots.UserFromContext(ctx)
+	o := &dots.Organisation{ID: u.OrganisationID, Role: u.Role}
+	return []*dots.Organisation{o}, 1, nil
+}
+
+func (s *fakeTokenService) Switch(ctx context.Context, p *dots.TokenPayload, oid ksuid.KSUID) (*dots.TokenPair, error) {
+	s.revoked[p.ID] = true
+	return &dots.TokenPair{Access: "SWITCHED"}, nil
+}
+
+func TestServer_authenticateOrganisation(t *testing.T) {
+	t.Run("Viewer", func(t *testing.T) {
+		s, ts := newTestServer()
+		oid := ksuid.New()
+		ts.payload.OID = oid
+		s.OrganisationService = &fakeOrganisationService{roles: map[ksuid.KSUID]dots.Role{oid: dots.RoleViewer}}
+
+		var got *dots.User
+		s.router.HandleFunc("/probe", func(w http.ResponseWriter, r *http.Request) {
+			got = dots.UserFromContext(r.Context())
+		})
+
+		r := httptest.NewRequest("GET", "/v1/probe", nil)
+		r.Header.Set("Authorization", "Bearer TOKEN")
+		s.router.ServeHTTP(httptest.NewRecorder(), r)
+
+		if got == nil || got.TenantID() != oid {
+			t.Fatalf("tenant=%v, want %v", got, oid)
+		}
+		if got.Role != dots.RoleViewer {
+			t.Fatalf("role=%v, want %v", got.Role, dots.RoleViewer)
+		}
+		if len(got.Powers) != 1 || got.Powers[0] != dots.ReadOwn {
+			t.Fatalf("powers=%v, want only %v", got.Powers, dots.ReadOwn)
+		}
+	})
+
+	t.Run("ErrNotMember", func(t *testing.T) {
+		s, ts := newTestServer()
+		ts.payload.OID = ksuid.New()
+
+		w := serve(s, "GET", "/v1/organisations", "")
+
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("status=%d, want %d", w.Code, http.StatusUnauthorized)
+		}
+	})
+}
+
+func TestServer_handleOrganisationSwitch(t *testing.T) {
+	t.Run("Bearer", func(t *testing.T) {
+		s, ts := newTestServer()
+		oid := ksuid.New()
+		s.OrganisationService = &fakeOrganisationService{roles: map[ksuid.KSUID]dots.Role{oid: dots.RoleMember}}
+
+		w := serve(s, "POST", "/v1/organisations/"+oid.String()+"/switch", "")
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), `"token_access":"SWITCHED"`) {
+			t.Fatalf("unexpected body %s", w.Body.String())
+		}
+		if !ts.revoked[ts.payload.ID] {
+			t.Fatal("expected old token to be revoked")
+		}
+	})
+}
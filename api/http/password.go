@@ -0,0 +1,75 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/innermond/dots"
+)
+
+func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var ur dots.UserRegister
+	if ok := inputJSON(w, r, &ur, "register"); !ok {
+		return
+	}
+
+	u, err := s.PasswordService.Register(r.Context(), ur)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusCreated, u)
+}
+
+func (s *Server) handlePasswordChange(w http.ResponseWriter, r *http.Request) {
+	var pc dots.PasswordChange
+	if ok := inputJSON(w, r, &pc, "change password"); !ok {
+		return
+	}
+
+	err := s.PasswordService.ChangePassword(r.Context(), pc)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handlePasswordForgot(w http.ResponseWriter, r *http.Request) {
+	var pf dots.PasswordForgot
+	if ok := inputJSON(w, r, &pf, "forgot password"); !ok {
+		return
+	}
+
+	prt, err := s.PasswordService.CreatePasswordReset(r.Context(), pf.Email)
+	// do not tell the caller which emails are known
+	if err != nil && dots.ErrorCode(err) != dots.ENOTFOUND {
+		Error(w, r, err)
+		return
+	}
+
+	if prt != nil && s.PasswordResetSender != nil {
+		if err := s.PasswordResetSender.SendPasswordReset(r.Context(), prt); err != nil {
+			Error(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) handlePasswordReset(w http.ResponseWriter, r *http.Request) {
+	var pr dots.PasswordReset
+	if ok := inputJSON(w, r, &pr, "reset password"); !ok {
+		return
+	}
+
+	err := s.PasswordService.ResetPassword(r.Context(), pr)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
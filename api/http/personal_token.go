@@ -0,0 +1,53 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/innermond/dots"
+)
+
+func (s *Server) handlePersonalTokenCreate(w http.ResponseWriter, r *http.Request) {
+	var pc dots.PersonalTokenCreate
+	if ok := inputJSON(w, r, &pc, "create personal token"); !ok {
+		return
+	}
+
+	pt, err := s.PersonalTokenService.CreatePersonalToken(r.Context(), pc)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusCreated, pt)
+}
+
+func (s *Server) handlePersonalTokenFind(w http.ResponseWriter, r *http.Request) {
+	// can accept missing r.Body
+	filter := dots.PersonalTokenFilter{}
+	input(w, r, &filter, "find personal token")
+
+	tt, n, err := s.PersonalTokenService.FindPersonalToken(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &foundResponse[[]*dots.PersonalToken]{tt, affected{n}})
+}
+
+func (s *Server) handlePersonalTokenDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, dots.Errorf(dots.EINVALID, "invalid ID format"))
+		return
+	}
+
+	if err := s.PersonalTokenService.DeletePersonalToken(r.Context(), id); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
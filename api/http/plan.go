@@ -0,0 +1,22 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/innermond/dots"
+)
+
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if err := dots.CanReadOwn(r.Context()); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	usage, err := s.PlanService.FindUsage(r.Context())
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, usage)
+}
@@ -0,0 +1,79 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/innermond/dots"
+)
+
+type fakePlanService struct{}
+
+func (s *fakePlanService) FindUsage(ctx context.Context) (*dots.Usage, error) {
+	return &dots.Usage{
+		Plan: "one eye",
+		Resources: map[string]dots.UsageItem{
+			dots.ResourceCompany: {Used: 1, Limit: 1},
+		},
+		FieldLen: map[string]int{"company.longname": 100},
+	}, nil
+}
+
+func TestServer_handleUsage(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		s, _ := newTestServer()
+		s.PlanService = &fakePlanService{}
+
+		w := serve(s, "GET", "/v1/me/usage", "")
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+		}
+		var got dots.Usage
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Plan != "one eye" {
+			t.Fatalf("plan=%q", got.Plan)
+		}
+		if c := got.Resources[dots.ResourceCompany]; c.Used != 1 || c.Limit != 1 {
+			t.Fatalf("company=%+v", c)
+		}
+	})
+}
+
+func TestError_quota(t *testing.T) {
+	err := dots.Errorf(dots.EQUOTA, "plan one eye allows 1 company").
+		WithData(map[string]interface{}{"resource": dots.ResourceCompany, "limit": 1, "used": 1})
+
+	r := httptest.NewRequest("POST", "/v1/companies", nil)
+	w := httptest.NewRecorder()
+	Error(w, r, err)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status=%d, want %d", w.Code, http.StatusForbidden)
+	}
+	var resp errorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data["resource"] != dots.ResourceCompany {
+		t.Fatalf("data=%v", resp.Data)
+	}
+}
+
+func TestQuota_CheckFieldLen(t *testing.T) {
+	q := &dots.Quota{Plan: "one eye", FieldLen: map[string]int{"company.longname": 3}}
+
+	short, long := "abc", "abcd"
+	if err := q.CheckFieldLen(dots.ResourceCompany, map[string]*string{"longname": &short, "tin": nil}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	err := q.CheckFieldLen(dots.ResourceCompany, map[string]*string{"longname": &long})
+	if dots.ErrorCode(err) != dots.EQUOTA {
+		t.Fatalf("code=%q, want %q", dots.ErrorCode(err), dots.EQUOTA)
+	}
+}
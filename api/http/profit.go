@@ -0,0 +1,45 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/innermond/dots"
+)
+
+func (s *Server) registerReportRoutes(router *mux.Router) {
+	router.HandleFunc("/profitability", s.handleProfitReport).Methods("GET")
+}
+
+func (s *Server) handleDeedProfit(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, dots.Errorf(dots.EINVALID, "invalid ID format"))
+		return
+	}
+
+	filter := dots.DeedCostFilter{}
+	input(w, r, &filter, "deed profit")
+
+	dp, err := s.ProfitService.FindDeedProfit(r.Context(), id, filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, dp)
+}
+
+func (s *Server) handleProfitReport(w http.ResponseWriter, r *http.Request) {
+	filter := dots.ProfitFilter{}
+	input(w, r, &filter, "profitability report")
+
+	report, err := s.ProfitService.FindProfitReport(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, report)
+}
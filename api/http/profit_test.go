@@ -0,0 +1,53 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/innermond/dots"
+)
+
+type fakeProfitService struct {
+	deedID int
+	deed   dots.DeedCostFilter
+	filter dots.ProfitFilter
+}
+
+func (s *fakeProfitService) FindDeedProfit(ctx context.Context, id int, filter dots.DeedCostFilter) (*dots.DeedProfit, error) {
+	s.deedID, s.deed = id, filter
+	return &dots.DeedProfit{DeedID: id, Method: filter.Method}, nil
+}
+
+func (s *fakeProfitService) FindProfitReport(ctx context.Context, filter dots.ProfitFilter) (*dots.ProfitReport, error) {
+	s.filter = filter
+	return &dots.ProfitReport{Method: filter.Method}, nil
+}
+
+func TestServer_handleDeedProfit(t *testing.T) {
+	s, _ := newTestServer()
+	ps := &fakeProfitService{}
+	s.ProfitService = ps
+
+	w := serve(s, "GET", "/v1/deeds/3/profit?method=average", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if ps.deedID != 3 || ps.deed.Method != dots.ValuationAverage {
+		t.Fatalf("id=%d filter=%+v", ps.deedID, ps.deed)
+	}
+}
+
+func TestServer_handleProfitReport(t *testing.T) {
+	s, _ := newTestServer()
+	ps := &fakeProfitService{}
+	s.ProfitService = ps
+
+	w := serve(s, "GET", "/v1/reports/profitability?from=2023-01&to=2023-04&company_id=2", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if ps.filter.From == nil || ps.filter.To == nil || ps.filter.CompanyID == nil || *ps.filter.CompanyID != 2 {
+		t.Fatalf("filter=%+v", ps.filter)
+	}
+}
@@ -0,0 +1,169 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/innermond/dots"
+	"github.com/segmentio/ksuid"
+)
+
+// route groups having their own rate limit
+const (
+	RateLimitAuth      = "auth"
+	RateLimitAPI       = "api"
+	RateLimitDepletion = "depletion"
+)
+
+func DefaultRateLimits() map[string]dots.RateLimit {
+	return map[string]dots.RateLimit{
+		RateLimitAuth:      {Requests: 10, Per: time.Minute, Burst: 10},
+		RateLimitAPI:       {Requests: 300, Per: time.Minute, Burst: 60},
+		RateLimitDepletion: {Requests: 6, Per: time.Minute, Burst: 3},
+	}
+}
+
+// rateLimit counts requests of the group against the caller,
+// a group without a limit is not limited
+func (s *Server) rateLimit(group string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit, found := s.RateLimits[group]
+			if !found || s.RateLimiter == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := group + ":" + s.rateLimitKey(r)
+			ok, retry, err := s.RateLimiter.Allow(r.Context(), key, limit)
+			if err != nil {
+				// a failing store must not take the api down
+				LogError(r, err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !ok {
+				secs := retryAfter(w, retry)
+				Error(w, r, dots.Errorf(dots.ETOOMANY, "too many requests, retry in %d seconds", secs))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// retryAfter tells the client how many whole seconds to wait
+func retryAfter(w http.ResponseWriter, wait time.Duration) int {
+	secs := int(math.Ceil(wait.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	return secs
+}
+
+// rateLimitKey tells whom the request counts against:
+// the personal token, the authenticated user or else the client IP
+func (s *Server) rateLimitKey(r *http.Request) string {
+	if tok := extractBearer(r.Header.Get("Authorization")); strings.HasPrefix(tok, dots.PersonalTokenPrefix) {
+		sum := sha256.Sum256([]byte(tok))
+		return "key:" + hex.EncodeToString(sum[:16])
+	}
+
+	if u := dots.UserFromContext(r.Context()); u.ID != ksuid.Nil {
+		return "user:" + u.ID.String()
+	}
+
+	return "ip:" + s.clientIP(r)
+}
+
+// clientIP trusts RealIPHeader, set by a proxy in front, over the peer address.
+// Only the last hop is taken: a forwarding chain starts with whatever the client
+// sent and ends with the address the trusted proxy saw
+func (s *Server) clientIP(r *http.Request) string {
+	if s.RealIPHeader != "" {
+		if vv := r.Header.Values(s.RealIPHeader); len(vv) > 0 {
+			v := vv[len(vv)-1]
+			if i := strings.LastIndex(v, ","); i >= 0 {
+				v = v[i+1:]
+			}
+			if ip := net.ParseIP(strings.TrimSpace(v)); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type bucket struct {
+	tokens float64
+	at     time.Time
+	// full is when the bucket gets back to burst, after that it can be forgotten
+	full time.Time
+}
+
+// MemoryRateLimiter keeps buckets of a single server
+type MemoryRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+
+	Now func() time.Time
+}
+
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{buckets: map[string]*bucket{}, Now: time.Now}
+}
+
+func (m *MemoryRateLimiter) Allow(ctx context.Context, key string, limit dots.RateLimit) (bool, time.Duration, error) {
+	if err := limit.Validate(); err != nil {
+		return false, 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.Now()
+	m.sweep(now)
+
+	b, found := m.buckets[key]
+	if !found {
+		b = &bucket{tokens: float64(limit.Burst), at: now}
+		m.buckets[key] = b
+	}
+
+	tokens, ok, retry := limit.Take(b.tokens, now.Sub(b.at))
+	b.tokens, b.at = tokens, now
+	b.full = now.Add(limit.FullIn(tokens))
+
+	return ok, retry, nil
+}
+
+// sweep forgets full buckets once a minute
+func (m *MemoryRateLimiter) sweep(now time.Time) {
+	if now.Sub(m.swept) < time.Minute {
+		return
+	}
+	m.swept = now
+
+	for k, b := range m.buckets {
+		if !now.Before(b.full) {
+			delete(m.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/innermond/dots"
+)
+
+func TestMemoryRateLimiter_Allow(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemoryRateLimiter()
+	m.Now = func() time.Time { return now }
+	limit := dots.RateLimit{Requests: 1, Per: time.Second, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		if ok, _, _ := m.Allow(context.Background(), "k", limit); !ok {
+			t.Fatalf("request %d refused within burst", i)
+		}
+	}
+
+	ok, retry, err := m.Allow(context.Background(), "k", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("expected refusal over burst")
+	}
+	if retry != time.Second {
+		t.Fatalf("retry=%s, want %s", retry, time.Second)
+	}
+
+	// other keys have their own bucket
+	if ok, _, _ := m.Allow(context.Background(), "other", limit); !ok {
+		t.Fatal("other key refused")
+	}
+
+	now = now.Add(time.Second)
+	if ok, _, _ := m.Allow(context.Background(), "k", limit); !ok {
+		t.Fatal("expected a token back after an interval")
+	}
+}
+
+func TestServer_rateLimit(t *testing.T) {
+	t.Run("Auth", func(t *testing.T) {
+		s, _ := newTestServer()
+		s.RateLimits = map[string]dots.RateLimit{RateLimitAuth: {Requests: 1, Per: time.Minute, Burst: 1}}
+
+		login := func(ip string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("POST", "/v1/login", nil)
+			r.RemoteAddr = ip + ":1234"
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, r)
+			return w
+		}
+
+		if w := login("10.0.0.1"); w.Code == http.StatusTooManyRequests {
+			t.Fatal("first login refused")
+		}
+		w := login("10.0.0.1")
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("status=%d, want %d", w.Code, http.StatusTooManyRequests)
+		}
+		if got := w.Header().Get("Retry-After"); got != "60" {
+			t.Fatalf("Retry-After=%q, want 60", got)
+		}
+		if w := login("10.0.0.2"); w.Code == http.StatusTooManyRequests {
+			t.Fatal("another client refused")
+		}
+	})
+
+	t.Run("NoLimit", func(t *testing.T) {
+		s, _ := newTestServer()
+		s.RateLimits = map[string]dots.RateLimit{}
+
+		for i := 0; i < 20; i++ {
+			r := httptest.NewRequest("POST", "/v1/login", nil)
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, r)
+			if w.Code == http.StatusTooManyRequests {
+				t.Fatalf("request %d limited without a limit", i)
+			}
+		}
+	})
+}
+
+func TestServer_rateLimitKey(t *testing.T) {
+	s := &Server{RealIPHeader: "X-Forwarded-For"}
+
+	r := httptest.NewRequest("GET", "/v1/companies", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	if got := s.rateLimitKey(r); got != "ip:10.0.0.1" {
+		t.Fatalf("key=%q", got)
+	}
+
+	// the client made up the first hop, the proxy added the last one
+	r.Header.Set("X-Forwarded-For", "203.0.113.9, 192.0.2.7")
+	if got := s.rateLimitKey(r); got != "ip:192.0.2.7" {
+		t.Fatalf("key=%q", got)
+	}
+
+	r.Header.Set("X-Forwarded-For", "not an ip")
+	if got := s.rateLimitKey(r); got != "ip:10.0.0.1" {
+		t.Fatalf("key=%q, want the peer address", got)
+	}
+
+	r.Header.Set("Authorization", "Bearer "+dots.PersonalTokenPrefix+"SECRET")
+	if got := s.rateLimitKey(r); !strings.HasPrefix(got, "key:") || strings.Contains(got, "SECRET") {
+		t.Fatalf("key=%q", got)
+	}
+}
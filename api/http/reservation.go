@@ -0,0 +1,93 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/innermond/dots"
+)
+
+func (s *Server) registerReservationRoutes(router *mux.Router) {
+	router.HandleFunc("", s.handleReservationCreate).Methods("POST")
+	router.HandleFunc("", s.handleReservationFind).Methods("GET")
+	router.HandleFunc("/{id}", s.handleReservationDelete).Methods("DELETE")
+}
+
+func (s *Server) handleReservationCreate(w http.ResponseWriter, r *http.Request) {
+	var rv dots.Reservation
+	if ok := inputJSON(w, r, &rv, "create reservation"); !ok {
+		return
+	}
+
+	err := s.ReservationService.CreateReservation(r.Context(), &rv)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusCreated, &rv)
+}
+
+func (s *Server) handleReservationFind(w http.ResponseWriter, r *http.Request) {
+	filter := dots.ReservationFilter{}
+	input(w, r, &filter, "find reservation")
+
+	rr, n, err := s.ReservationService.FindReservation(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &foundResponse[[]*dots.Reservation]{rr, affected{n}})
+}
+
+func (s *Server) handleReservationDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, dots.Errorf(dots.EINVALID, "invalid ID format"))
+		return
+	}
+
+	n, err := s.ReservationService.DeleteReservation(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &affected{n})
+}
+
+// handleDeedConfirm turns what the deed holds into drains
+func (s *Server) handleDeedConfirm(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, dots.Errorf(dots.EINVALID, "invalid ID format"))
+		return
+	}
+
+	confirmed, err := s.ReservationService.ConfirmReservation(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, confirmed)
+}
+
+// handleDeedCancel lets go what the deed holds
+func (s *Server) handleDeedCancel(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, dots.Errorf(dots.EINVALID, "invalid ID format"))
+		return
+	}
+
+	n, err := s.ReservationService.CancelReservation(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &affected{n})
+}
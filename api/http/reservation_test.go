@@ -0,0 +1,85 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/innermond/dots"
+)
+
+type fakeReservationService struct {
+	dots.ReservationService
+
+	created   *dots.Reservation
+	confirmed int
+	cancelled int
+}
+
+func (s *fakeReservationService) CreateReservation(ctx context.Context, r *dots.Reservation) error {
+	r.ID, r.CompanyID = 9, 1
+	s.created = r
+	return nil
+}
+
+func (s *fakeReservationService) ConfirmReservation(ctx context.Context, deedID int) (*dots.ReservationConfirmed, error) {
+	s.confirmed = deedID
+	return &dots.ReservationConfirmed{DeedID: deedID, Distribute: map[int]float64{5: 2.5}}, nil
+}
+
+func (s *fakeReservationService) CancelReservation(ctx context.Context, deedID int) (int, error) {
+	s.cancelled = deedID
+	return 2, nil
+}
+
+func TestServer_handleReservationCreate(t *testing.T) {
+	s, _ := newTestServer()
+	rs := &fakeReservationService{}
+	s.ReservationService = rs
+
+	w := serve(s, "POST", "/v1/reservations", `{"deed_id": 3, "entry_type_id": 2, "quantity": 4}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	if c := rs.created; c == nil || *c.DeedID != 3 || *c.EntryTypeID != 2 || *c.Quantity != 4 || c.EntryID != nil {
+		t.Fatalf("created=%+v", rs.created)
+	}
+	var got dots.Reservation
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != 9 || got.CompanyID != 1 {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestServer_handleDeedConfirm(t *testing.T) {
+	s, _ := newTestServer()
+	rs := &fakeReservationService{}
+	s.ReservationService = rs
+
+	w := serve(s, "POST", "/v1/deeds/3/confirm", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var got dots.ReservationConfirmed
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if rs.confirmed != 3 || got.Distribute[5] != 2.5 {
+		t.Fatalf("confirmed=%d, got %+v", rs.confirmed, got)
+	}
+
+	w = serve(s, "POST", "/v1/deeds/3/cancel", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var n affected
+	if err := json.NewDecoder(w.Body).Decode(&n); err != nil {
+		t.Fatal(err)
+	}
+	if rs.cancelled != 3 || n.N != 2 {
+		t.Fatalf("cancelled=%d, affected %d", rs.cancelled, n.N)
+	}
+}
@@ -16,9 +16,10 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/gorilla/securecookie"
 	"github.com/innermond/dots"
+	"github.com/innermond/dots/http/oidc"
 	"github.com/segmentio/ksuid"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/github"
 )
 
 type Server struct {
@@ -26,18 +27,49 @@ type Server struct {
 	router *mux.Router
 	sc     *securecookie.SecureCookie
 
-	ClientID     string
-	ClientSecret string
+	// OAuthProviders holds the openid connect providers served at /oauth/{provider}
+	OAuthProviders *oidc.Registry
+	// OAuthRedirectBase prefixes the callback urls given to providers
+	OAuthRedirectBase string
+
+	GithubClientID     string
+	GithubClientSecret string
+	githubEndpoint     oauth2.Endpoint
+	githubAPIURL       string
 
 	UserService  dots.UserService
 	AuthService  dots.AuthService
 	TokenService dots.TokenService
 
+	PasswordService     dots.PasswordService
+	PasswordResetSender dots.PasswordResetSender
+
+	PersonalTokenService dots.PersonalTokenService
+	UserAdminService     dots.UserAdminService
+	OrganisationService  dots.OrganisationService
+	PlanService          dots.PlanService
+	LoginEventService    dots.LoginEventService
+
+	// RateLimits holds the limit of each route group, RateLimiter keeps the buckets
+	RateLimiter dots.RateLimiter
+	RateLimits  map[string]dots.RateLimit
+	// RealIPHeader names the header where a trusted proxy puts the client IP
+	RealIPHeader string
+
 	EntryTypeService dots.EntryTypeService
 	EntryService     dots.EntryService
 	DrainService     dots.DrainService
 	CompanyService   dots.CompanyService
 	DeedService      dots.DeedService
+	ValuationService dots.ValuationService
+	ProfitService    dots.ProfitService
+	LedgerService    dots.LedgerService
+
+	StockThresholdService dots.StockThresholdService
+	UnitService           dots.UnitService
+	TransferService       dots.TransferService
+	InventoryCountService dots.InventoryCountService
+	ReservationService    dots.ReservationService
 }
 
 // TODO is this handler ever called?
@@ -59,6 +91,14 @@ func NewServer() *Server {
 	s := &Server{
 		server: &httpsrv,
 		router: router,
+
+		OAuthRedirectBase: "http://localhost:8080",
+
+		githubEndpoint: github.Endpoint,
+		githubAPIURL:   "https://api.github.com",
+
+		RateLimiter: NewMemoryRateLimiter(),
+		RateLimits:  DefaultRateLimits(),
 	}
 	//s.server.Handler = s.router //http.HandlerFunc(s.serveHTTP)
 
@@ -75,46 +115,100 @@ func NewServer() *Server {
 
 	{
 		router := s.router.PathPrefix("/").Subrouter()
-		router.Use(s.noAuthenticate)
+		router.Use(s.noAuthenticate, s.rateLimit(RateLimitAuth))
 		s.registerAuthRoutes(router)
 	}
 
 	{
 		router := s.router.PathPrefix("/me").Subrouter()
-		router.Use(s.yesAuthenticate)
+		router.Use(s.yesAuthenticate, s.rateLimit(RateLimitAPI))
 		s.registerUserRoutes(router)
 	}
 
+	{
+		router := s.router.PathPrefix("/organisations").Subrouter()
+		router.Use(s.yesAuthenticate, s.rateLimit(RateLimitAPI))
+		s.registerOrganisationRoutes(router)
+	}
+
+	{
+		router := s.router.PathPrefix("/admin/users").Subrouter()
+		router.Use(s.yesAuthenticate, s.rateLimit(RateLimitAPI))
+		s.registerUserAdminRoutes(router)
+	}
+
 	{
 		router := s.router.PathPrefix("/entry-types").Subrouter()
-		router.Use(s.yesAuthenticate)
+		router.Use(s.yesAuthenticate, s.rateLimit(RateLimitAPI))
 		s.registerEntryTypeRoutes(router)
 	}
 
 	{
 		router := s.router.PathPrefix("/entries").Subrouter()
-		router.Use(s.yesAuthenticate)
+		router.Use(s.yesAuthenticate, s.rateLimit(RateLimitAPI))
 		s.registerEntryRoutes(router)
 	}
 
 	{
 		router := s.router.PathPrefix("/drains").Subrouter()
-		router.Use(s.yesAuthenticate)
+		router.Use(s.yesAuthenticate, s.rateLimit(RateLimitAPI))
 		s.registerDrainRoutes(router)
 	}
 
 	{
 		router := s.router.PathPrefix("/companies").Subrouter()
-		router.Use(s.yesAuthenticate)
+		router.Use(s.yesAuthenticate, s.rateLimit(RateLimitAPI))
 		s.registerCompanyRoutes(router)
 	}
 
 	{
 		router := s.router.PathPrefix("/deeds").Subrouter()
-		router.Use(s.yesAuthenticate)
+		router.Use(s.yesAuthenticate, s.rateLimit(RateLimitAPI))
 		s.registerDeedRoutes(router)
 	}
 
+	{
+		router := s.router.PathPrefix("/reports").Subrouter()
+		router.Use(s.yesAuthenticate, s.rateLimit(RateLimitAPI))
+		s.registerReportRoutes(router)
+	}
+
+	{
+		router := s.router.PathPrefix("/stock-thresholds").Subrouter()
+		router.Use(s.yesAuthenticate, s.rateLimit(RateLimitAPI))
+		s.registerStockThresholdRoutes(router)
+	}
+
+	{
+		router := s.router.PathPrefix("/stock-alerts").Subrouter()
+		router.Use(s.yesAuthenticate, s.rateLimit(RateLimitAPI))
+		s.registerStockAlertRoutes(router)
+	}
+
+	{
+		router := s.router.PathPrefix("/units").Subrouter()
+		router.Use(s.yesAuthenticate, s.rateLimit(RateLimitAPI))
+		s.registerUnitRoutes(router)
+	}
+
+	{
+		router := s.router.PathPrefix("/transfers").Subrouter()
+		router.Use(s.yesAuthenticate, s.rateLimit(RateLimitAPI))
+		s.registerTransferRoutes(router)
+	}
+
+	{
+		router := s.router.PathPrefix("/inventory-counts").Subrouter()
+		router.Use(s.yesAuthenticate, s.rateLimit(RateLimitAPI))
+		s.registerInventoryCountRoutes(router)
+	}
+
+	{
+		router := s.router.PathPrefix("/reservations").Subrouter()
+		router.Use(s.yesAuthenticate, s.rateLimit(RateLimitAPI))
+		s.registerReservationRoutes(router)
+	}
+
 	return s
 }
 
@@ -124,17 +218,13 @@ func (s *Server) Close() error {
 	return s.server.Shutdown(ctx)
 }
 
-func (s *Server) OAuth2Config() *oauth2.Config {
+func (s *Server) GithubOAuth2Config() *oauth2.Config {
 	return &oauth2.Config{
-		ClientID:     s.ClientID,
-		ClientSecret: s.ClientSecret,
-		Scopes: []string{
-			"https://www.googleapis.com/auth/userinfo.email",
-			"https://www.googleapis.com/auth/userinfo.profile",
-			"openid",
-		},
-		RedirectURL: "http://localhost:8080/oauth/google/callback",
-		Endpoint:    google.Endpoint,
+		ClientID:     s.GithubClientID,
+		ClientSecret: s.GithubClientSecret,
+		Scopes:       []string{"read:user", "user:email"},
+		RedirectURL:  strings.TrimRight(s.OAuthRedirectBase, "/") + "/oauth/github/callback",
+		Endpoint:     s.githubEndpoint,
 	}
 }
 
@@ -218,15 +308,48 @@ func (s *Server) authenticate(next http.Handler) http.Handler {
 		// try bearer
 		// TODO check using return in middleware if breaks the chain
 		tokenMaybe := extractBearer(r.Header.Get("Authorization"))
+		// personal tokens act with the powers of their scope only
+		if strings.HasPrefix(tokenMaybe, dots.PersonalTokenPrefix) {
+			u, err := s.PersonalTokenService.AuthenticatePersonalToken(r.Context(), tokenMaybe)
+			if err != nil {
+				Error(w, r, err)
+				return
+			}
+			if err := s.withOrganisation(r.Context(), u, ksuid.Nil); err != nil {
+				Error(w, r, err)
+				return
+			}
+			r = r.WithContext(dots.NewContextWithPersonalToken(dots.NewContextWithUser(r.Context(), u)))
+			next.ServeHTTP(w, r)
+			return
+		}
 		if tokenMaybe != "" {
 			payload, err := s.TokenService.Read(r.Context(), tokenMaybe)
 			if err != nil {
 				Error(w, r, err)
 				return
 			}
+			revoked, err := s.TokenService.IsRevoked(r.Context(), payload.ID)
+			if err != nil {
+				Error(w, r, err)
+				return
+			}
+			if revoked {
+				Error(w, r, dots.Errorf(dots.EUNAUTHORIZED, "token revoked"))
+				return
+			}
+			r = r.WithContext(dots.NewContextWithToken(r.Context(), payload))
 			if payload.UID != ksuid.Nil {
 				u, err := s.UserService.FindUserByID(r.Context(), payload.UID)
+				if err == nil && u.IsDisabled() {
+					Error(w, r, dots.Errorf(dots.EUNAUTHORIZED, "account disabled"))
+					return
+				}
 				if err == nil {
+					if err := s.withOrganisation(r.Context(), u, payload.OID); err != nil {
+						Error(w, r, err)
+						return
+					}
 					r = r.WithContext(dots.NewContextWithUser(r.Context(), u))
 				} else {
 					log.Printf("cannot find payload user %s: %s", payload.UID, err)
@@ -238,7 +361,17 @@ func (s *Server) authenticate(next http.Handler) http.Handler {
 		ses, _ := s.getSession(r)
 		if ses.UserID != ksuid.Nil {
 			u, err := s.UserService.FindUserByID(r.Context(), ses.UserID)
-			if err == nil {
+			if err == nil && u.IsDisabled() {
+				log.Printf("session user %s is disabled", ses.UserID)
+			} else if err == nil {
+				// a lost membership sends the user back home
+				if err := s.withOrganisation(r.Context(), u, ses.OrganisationID); err != nil {
+					log.Printf("session user %s left organisation %s: %s", ses.UserID, ses.OrganisationID, err)
+					err = s.withOrganisation(r.Context(), u, ksuid.Nil)
+				}
+				if err != nil {
+					log.Printf("cannot set organisation of session user %s: %s", ses.UserID, err)
+				}
 				r = r.WithContext(dots.NewContextWithUser(r.Context(), u))
 			} else {
 				log.Printf("cannot find session user %s: %s", ses.UserID, err)
@@ -248,6 +381,28 @@ func (s *Server) authenticate(next http.Handler) http.Handler {
 	})
 }
 
+// withOrganisation puts the user inside its active organisation,
+// the personal one for a nil oid, and narrows its powers to its role there
+func (s *Server) withOrganisation(ctx context.Context, u *dots.User, oid ksuid.KSUID) error {
+	if oid.IsNil() {
+		oid = u.ID
+	}
+
+	role, err := s.OrganisationService.Membership(ctx, oid, u.ID)
+	if dots.ErrorCode(err) == dots.ENOTFOUND {
+		return dots.Errorf(dots.EUNAUTHORIZED, "not a member of organisation %s", oid)
+	}
+	if err != nil {
+		return err
+	}
+
+	u.OrganisationID = oid
+	u.Role = role
+	u.Powers = role.Scope(u.Powers)
+
+	return nil
+}
+
 func (s *Server) yesAuthenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		u := dots.UserFromContext(r.Context())
@@ -274,8 +429,10 @@ func (s *Server) yesAuthenticate(next http.Handler) http.Handler {
 func (s *Server) noAuthenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		u := dots.UserFromContext(r.Context())
-		isLogout := r.URL.Path == "/logout"
-		if u.ID != ksuid.Nil && !isLogout {
+		// logged in users still need to logout or refresh their tokens
+		path := strings.TrimPrefix(r.URL.Path, "/v1")
+		isAllowed := path == "/logout" || path == "/token/refresh"
+		if u.ID != ksuid.Nil && !isAllowed {
 			http.Redirect(w, r, "/", http.StatusFound)
 			return
 		}
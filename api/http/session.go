@@ -13,6 +13,9 @@ type Session struct {
 	UserID      ksuid.KSUID `json:"user_id"`
 	RedirectURL string      `json:"redirect_url"`
 	State       string      `json:"state"`
+	Nonce       string      `json:"nonce"`
+	// OrganisationID is the active organisation, nil means the personal one
+	OrganisationID ksuid.KSUID `json:"organisation_id"`
 }
 
 func (ses *Session) IsZero() bool {
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/innermond/dots"
+)
+
+type fakeEntryService struct {
+	dots.EntryService
+
+	stock    dots.StockFilter
+	expiring dots.ExpiringFilter
+}
+
+func (s *fakeEntryService) FindStock(ctx context.Context, filter dots.StockFilter) ([]*dots.StockLevel, int, error) {
+	s.stock = filter
+	return []*dots.StockLevel{}, 0, nil
+}
+
+func (s *fakeEntryService) FindExpiring(ctx context.Context, filter dots.ExpiringFilter) ([]*dots.ExpiringStock, int, error) {
+	s.expiring = filter
+	return []*dots.ExpiringStock{}, 0, nil
+}
+
+func (s *fakeCompanyService) DepletionCompany(ctx context.Context, filter dots.CompanyFilter) ([]*dots.CompanyDepletion, int, error) {
+	s.filter = filter
+	return []*dots.CompanyDepletion{}, 0, nil
+}
+
+func TestServer_handleStock(t *testing.T) {
+	s, _ := newTestServer()
+	es := &fakeEntryService{}
+	s.EntryService = es
+
+	w := serve(s, "GET", "/v1/entries/stock?company_id=1&as_of=2023-03-31%2023:59", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	want := time.Date(2023, 3, 31, 23, 59, 0, 0, time.Local)
+	if es.stock.CompanyID == nil || *es.stock.CompanyID != 1 || es.stock.AsOf == nil || !time.Time(*es.stock.AsOf).Equal(want) {
+		t.Fatalf("filter=%+v, want company 1 as of %v", es.stock, want)
+	}
+}
+
+func TestServer_handleCompanyDepletion_asOf(t *testing.T) {
+	s, _ := newTestServer()
+	cs := &fakeCompanyService{}
+	s.CompanyService = cs
+
+	w := serve(s, "GET", "/v1/companies/depletion?id=1&as_of=2023-02", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if cs.filter.AsOf == nil || !time.Time(*cs.filter.AsOf).Equal(time.Date(2023, 2, 1, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf("as_of=%v", cs.filter.AsOf)
+	}
+}
+
+func (s *fakeCompanyService) ForecastCompany(ctx context.Context, filter dots.ForecastFilter) ([]*dots.CompanyForecast, int, error) {
+	s.forecast = filter
+	return []*dots.CompanyForecast{}, 0, nil
+}
+
+func TestServer_handleCompanyForecast(t *testing.T) {
+	s, _ := newTestServer()
+	cs := &fakeCompanyService{}
+	s.CompanyService = cs
+
+	w := serve(s, "GET", "/v1/companies/forecast?company_id=1&window=3&method=exponential&alpha=0.5", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	f := cs.forecast
+	if f.CompanyID == nil || *f.CompanyID != 1 || f.Window != 3 || f.Method != dots.ForecastExponential || f.Alpha == nil || *f.Alpha != 0.5 {
+		t.Fatalf("filter=%+v", f)
+	}
+}
+
+func TestServer_handleExpiring(t *testing.T) {
+	s, _ := newTestServer()
+	es := &fakeEntryService{}
+	s.EntryService = es
+
+	w := serve(s, "GET", "/v1/entries/expiring?company_id=1&days=7", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if es.expiring.CompanyID == nil || *es.expiring.CompanyID != 1 || es.expiring.Days == nil || *es.expiring.Days != 7 {
+		t.Fatalf("filter=%+v, want company 1 in 7 days", es.expiring)
+	}
+}
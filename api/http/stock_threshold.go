@@ -0,0 +1,98 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/innermond/dots"
+)
+
+func (s *Server) registerStockThresholdRoutes(router *mux.Router) {
+	router.HandleFunc("", s.handleStockThresholdCreate).Methods("POST")
+	router.HandleFunc("", s.handleStockThresholdFind).Methods("GET")
+	router.HandleFunc("/{id}", s.handleStockThresholdUpdate).Methods("PATCH")
+	router.HandleFunc("/{id}", s.handleStockThresholdDelete).Methods("DELETE")
+}
+
+func (s *Server) registerStockAlertRoutes(router *mux.Router) {
+	router.HandleFunc("", s.handleStockAlertFind).Methods("GET")
+}
+
+func (s *Server) handleStockThresholdCreate(w http.ResponseWriter, r *http.Request) {
+	var st dots.StockThreshold
+	if ok := inputJSON(w, r, &st, "create stock threshold"); !ok {
+		return
+	}
+
+	err := s.StockThresholdService.CreateStockThreshold(r.Context(), &st)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusCreated, &st)
+}
+
+func (s *Server) handleStockThresholdFind(w http.ResponseWriter, r *http.Request) {
+	filter := dots.StockThresholdFilter{}
+	input(w, r, &filter, "find stock threshold")
+
+	tt, n, err := s.StockThresholdService.FindStockThreshold(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &foundResponse[[]*dots.StockThreshold]{tt, affected{n}})
+}
+
+func (s *Server) handleStockThresholdUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, dots.Errorf(dots.EINVALID, "invalid ID format"))
+		return
+	}
+
+	var upd dots.StockThresholdUpdate
+	if ok := inputJSON(w, r, &upd, "update stock threshold"); !ok {
+		return
+	}
+
+	st, err := s.StockThresholdService.UpdateStockThreshold(r.Context(), id, upd)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, st)
+}
+
+func (s *Server) handleStockThresholdDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, dots.Errorf(dots.EINVALID, "invalid ID format"))
+		return
+	}
+
+	n, err := s.StockThresholdService.DeleteStockThreshold(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &affected{n})
+}
+
+func (s *Server) handleStockAlertFind(w http.ResponseWriter, r *http.Request) {
+	filter := dots.StockAlertFilter{}
+	input(w, r, &filter, "find stock alert")
+
+	aa, n, err := s.StockThresholdService.FindStockAlert(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &foundResponse[[]*dots.StockAlert]{aa, affected{n}})
+}
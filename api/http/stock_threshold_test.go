@@ -0,0 +1,75 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/innermond/dots"
+)
+
+type fakeStockThresholdService struct {
+	dots.StockThresholdService
+
+	created *dots.StockThreshold
+	deleted int
+	alerts  dots.StockAlertFilter
+}
+
+func (s *fakeStockThresholdService) CreateStockThreshold(ctx context.Context, st *dots.StockThreshold) error {
+	st.ID = 7
+	s.created = st
+	return nil
+}
+
+func (s *fakeStockThresholdService) DeleteStockThreshold(ctx context.Context, id int) (int, error) {
+	s.deleted = id
+	return 1, nil
+}
+
+func (s *fakeStockThresholdService) FindStockAlert(ctx context.Context, filter dots.StockAlertFilter) ([]*dots.StockAlert, int, error) {
+	s.alerts = filter
+	return []*dots.StockAlert{}, 0, nil
+}
+
+func TestServer_handleStockThresholdCreate(t *testing.T) {
+	s, _ := newTestServer()
+	ss := &fakeStockThresholdService{}
+	s.StockThresholdService = ss
+
+	w := serve(s, "POST", "/v1/stock-thresholds", `{"entry_type_id": 2, "reorder_level": 5, "target_level": 20}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	if c := ss.created; c == nil || c.CompanyID != nil || *c.EntryTypeID != 2 || *c.ReorderLevel != 5 || *c.TargetLevel != 20 {
+		t.Fatalf("created=%+v", ss.created)
+	}
+}
+
+func TestServer_handleStockThresholdDelete(t *testing.T) {
+	s, _ := newTestServer()
+	ss := &fakeStockThresholdService{}
+	s.StockThresholdService = ss
+
+	w := serve(s, "DELETE", "/v1/stock-thresholds/7", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if ss.deleted != 7 {
+		t.Fatalf("deleted=%d, want 7", ss.deleted)
+	}
+}
+
+func TestServer_handleStockAlertFind(t *testing.T) {
+	s, _ := newTestServer()
+	ss := &fakeStockThresholdService{}
+	s.StockThresholdService = ss
+
+	w := serve(s, "GET", "/v1/stock-alerts?company_id=1&from=2023-02", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if ss.alerts.CompanyID == nil || *ss.alerts.CompanyID != 1 || ss.alerts.From == nil {
+		t.Fatalf("filter=%+v", ss.alerts)
+	}
+}
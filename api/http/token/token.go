@@ -10,16 +10,18 @@ import (
 )
 
 type Tokener interface {
-	CreateToken(ksuid.KSUID, time.Duration) (string, error)
+	CreateToken(ksuid.KSUID, ksuid.KSUID, time.Duration) (string, *Payload, error)
 	ReadToken(string) (*Payload, error)
 }
 
 type Payload = dots.TokenPayload
 
-func newPayload(uid ksuid.KSUID) *Payload {
+func newPayload(uid ksuid.KSUID, oid ksuid.KSUID, exp time.Time) *Payload {
 	payload := Payload{
-		ID:  ksuid.New(),
-		UID: uid,
+		ID:        ksuid.New(),
+		UID:       uid,
+		OID:       oid,
+		ExpiresAt: exp,
 	}
 
 	return &payload
@@ -35,22 +37,22 @@ type pasetoMaker struct {
 	key []byte
 }
 
-func (k pasetoMaker) CreateToken(uid ksuid.KSUID, d time.Duration) (string, error) {
+func (k pasetoMaker) CreateToken(uid ksuid.KSUID, oid ksuid.KSUID, d time.Duration) (string, *Payload, error) {
 	token := paseto.NewToken()
-	payload := newPayload(uid)
 	now := time.Now().UTC()
 	token.SetIssuedAt(now)
 	token.SetNotBefore(now)
-	exp := time.Now().Add(d)
+	exp := now.Add(d)
 	token.SetExpiration(exp)
+	payload := newPayload(uid, oid, exp)
 	token.Set("payload", payload)
 
 	sk, err := paseto.V4SymmetricKeyFromBytes(k.key)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	return token.V4Encrypt(sk, nil), nil
+	return token.V4Encrypt(sk, nil), payload, nil
 }
 
 type ErrTokenClient = paseto.RuleError
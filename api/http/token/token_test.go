@@ -22,7 +22,7 @@ func TestToken_Create(t *testing.T) {
 
 		uid := ksuid.New()
 		d := 1 * time.Minute
-		str, err := tokener.CreateToken(uid, d)
+		str, created, err := tokener.CreateToken(uid, uid, d)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -34,6 +34,9 @@ func TestToken_Create(t *testing.T) {
 		if payload.UID != uid {
 			t.Fatalf("mismatch: %v != %v", payload.UID, uid)
 		}
+		if payload.ID != created.ID {
+			t.Fatalf("mismatch: %v != %v", payload.ID, created.ID)
+		}
 
 	})
 }
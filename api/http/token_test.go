@@ -0,0 +1,114 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/innermond/dots"
+	"github.com/segmentio/ksuid"
+)
+
+func TestServer_handleLogout(t *testing.T) {
+	t.Run("Bearer", func(t *testing.T) {
+		s, ts := newTestServer()
+
+		w := serve(s, "POST", "/v1/logout", `{"token_refresh":"REFRESH"}`)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusNoContent, w.Body.String())
+		}
+		if !ts.revoked[ts.payload.ID] {
+			t.Fatal("expected token to be revoked")
+		}
+		if got, want := ts.revokedRefresh, "REFRESH"; got != want {
+			t.Fatalf("refresh=%v, want %v", got, want)
+		}
+
+		// the revoked token is refused from now on
+		w = serve(s, "GET", "/v1/me", "")
+
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("status=%d, want %d", w.Code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("BearerWithoutBody", func(t *testing.T) {
+		s, ts := newTestServer()
+
+		w := serve(s, "POST", "/v1/logout", "")
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusNoContent, w.Body.String())
+		}
+		if !ts.revoked[ts.payload.ID] {
+			t.Fatal("expected token to be revoked")
+		}
+	})
+}
+
+type fakePersonalTokenService struct {
+	dots.PersonalTokenService
+
+	user     *dots.User
+	caller   *dots.User
+	viaToken bool
+}
+
+func (s *fakePersonalTokenService) AuthenticatePersonalToken(ctx context.Context, raw string) (*dots.User, error) {
+	if raw != dots.PersonalTokenPrefix+"SECRET" {
+		return nil, dots.Errorf(dots.EUNAUTHORIZED, "personal token is invalid or expired")
+	}
+	return s.user, nil
+}
+
+func (s *fakePersonalTokenService) FindPersonalToken(ctx context.Context, filter dots.PersonalTokenFilter) ([]*dots.PersonalToken, int, error) {
+	s.caller = dots.UserFromContext(ctx)
+	s.viaToken = dots.FromPersonalToken(ctx)
+	pt := &dots.PersonalToken{ID: 1, Name: "ci", Scopes: s.caller.Powers}
+	return []*dots.PersonalToken{pt}, 1, nil
+}
+
+func TestServer_authenticatePersonalToken(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		s, _ := newTestServer()
+		pts := &fakePersonalTokenService{
+			user: &dots.User{ID: ksuid.New(), Powers: dots.ScopePowers(dots.PowerToManageOwn, []dots.Power{dots.ReadOwn})},
+		}
+		s.PersonalTokenService = pts
+
+		r := httptest.NewRequest("GET", "/v1/me/tokens", nil)
+		r.Header.Set("Authorization", "Bearer "+dots.PersonalTokenPrefix+"SECRET")
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+		}
+		if pts.caller == nil || pts.caller.ID != pts.user.ID {
+			t.Fatal("expected the token owner in context")
+		}
+		if !pts.viaToken {
+			t.Fatal("expected the request marked as made with a personal token")
+		}
+		if !strings.Contains(w.Body.String(), `"scopes":["read_own"]`) {
+			t.Fatalf("unexpected body %s", w.Body.String())
+		}
+	})
+
+	t.Run("ErrInvalid", func(t *testing.T) {
+		s, _ := newTestServer()
+		s.PersonalTokenService = &fakePersonalTokenService{}
+
+		r := httptest.NewRequest("GET", "/v1/me/tokens", nil)
+		r.Header.Set("Authorization", "Bearer "+dots.PersonalTokenPrefix+"WRONG")
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, r)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("status=%d, want %d", w.Code, http.StatusUnauthorized)
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/innermond/dots"
+)
+
+func (s *Server) registerTransferRoutes(router *mux.Router) {
+	router.HandleFunc("", s.handleTransferCreate).Methods("POST")
+	router.HandleFunc("", s.handleTransferFind).Methods("GET")
+}
+
+func (s *Server) handleTransferCreate(w http.ResponseWriter, r *http.Request) {
+	var t dots.Transfer
+	if ok := inputJSON(w, r, &t, "create transfer"); !ok {
+		return
+	}
+
+	err := s.TransferService.CreateTransfer(r.Context(), &t)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusCreated, &t)
+}
+
+func (s *Server) handleTransferFind(w http.ResponseWriter, r *http.Request) {
+	filter := dots.TransferFilter{}
+	input(w, r, &filter, "find transfer")
+
+	tt, n, err := s.TransferService.FindTransfer(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &foundResponse[[]*dots.Transfer]{tt, affected{n}})
+}
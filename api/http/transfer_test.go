@@ -0,0 +1,65 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/innermond/dots"
+)
+
+type fakeTransferService struct {
+	dots.TransferService
+
+	created *dots.Transfer
+	filter  dots.TransferFilter
+}
+
+func (s *fakeTransferService) CreateTransfer(ctx context.Context, t *dots.Transfer) error {
+	t.ID, t.DeedID = 3, 40
+	t.Lines = []*dots.TransferLine{{SourceEntryID: 5, TargetEntryID: 50, Quantity: *t.Quantity}}
+	s.created = t
+	return nil
+}
+
+func (s *fakeTransferService) FindTransfer(ctx context.Context, filter dots.TransferFilter) ([]*dots.Transfer, int, error) {
+	s.filter = filter
+	return []*dots.Transfer{}, 0, nil
+}
+
+func TestServer_handleTransferCreate(t *testing.T) {
+	s, _ := newTestServer()
+	ts := &fakeTransferService{}
+	s.TransferService = ts
+
+	w := serve(s, "POST", "/v1/transfers", `{"from_company_id": 1, "to_company_id": 2, "entry_type_id": 4, "quantity": 7.5}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	if ts.created == nil || ts.created.Strategy != nil {
+		t.Fatalf("created=%+v, want the strategy left to the company", ts.created)
+	}
+
+	var got dots.Transfer
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.DeedID != 40 || len(got.Lines) != 1 || got.Lines[0].TargetEntryID != 50 {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestServer_handleTransferFind(t *testing.T) {
+	s, _ := newTestServer()
+	ts := &fakeTransferService{}
+	s.TransferService = ts
+
+	w := serve(s, "GET", "/v1/transfers?company_id=2&from=2023-01", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if ts.filter.CompanyID == nil || *ts.filter.CompanyID != 2 || ts.filter.From == nil {
+		t.Fatalf("filter=%+v", ts.filter)
+	}
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/innermond/dots"
+)
+
+type fakeCompanyService struct {
+	dots.CompanyService
+
+	filter   dots.CompanyFilter
+	restore  dots.TrashRestore
+	forecast dots.ForecastFilter
+}
+
+func (s *fakeCompanyService) FindCompany(ctx context.Context, filter dots.CompanyFilter) ([]*dots.Company, int, error) {
+	s.filter = filter
+	return []*dots.Company{}, 0, nil
+}
+
+func (s *fakeCompanyService) RestoreCompany(ctx context.Context, restore dots.TrashRestore) (int, error) {
+	s.restore = restore
+	return len(restore.ID), nil
+}
+
+type fakeDeedService struct {
+	dots.DeedService
+
+	created *dots.Deed
+	restore dots.TrashRestore
+}
+
+func (s *fakeDeedService) RestoreDeed(ctx context.Context, restore dots.TrashRestore) (int, error) {
+	s.restore = restore
+	return len(restore.ID), nil
+}
+
+type fakeEntryTypeService struct {
+	dots.EntryTypeService
+
+	filter dots.EntryTypeFilterOrdered
+}
+
+func (s *fakeEntryTypeService) FindEntryType(ctx context.Context, filter dots.EntryTypeFilterOrdered) ([]*dots.EntryType, int, error) {
+	s.filter = filter
+	return []*dots.EntryType{}, 0, nil
+}
+
+func TestServer_handleCompanyFind_trash(t *testing.T) {
+	s, _ := newTestServer()
+	cs := &fakeCompanyService{}
+	s.CompanyService = cs
+
+	w := serve(s, "GET", "/v1/companies?is_deleted=true&deleted_at_from=2023-04&deleted_at_to=2023-05-01", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if cs.filter.IsDeleted == nil || !*cs.filter.IsDeleted {
+		t.Fatalf("is_deleted not passed")
+	}
+	if v := cs.filter.DeletedAtFrom; v == nil || !time.Time(*v).Equal(time.Date(2023, 4, 1, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf("deleted_at_from=%v", v)
+	}
+	if v := cs.filter.DeletedAtTo; v == nil || !time.Time(*v).Equal(time.Date(2023, 5, 1, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf("deleted_at_to=%v", v)
+	}
+}
+
+func TestServer_handleEntryTypeFind_trash(t *testing.T) {
+	s, _ := newTestServer()
+	es := &fakeEntryTypeService{}
+	s.EntryTypeService = es
+
+	w := serve(s, "GET", "/v1/entry-types?deleted_at_from=2023", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if es.filter.IsDeleted != nil || es.filter.DeletedAtFrom == nil || es.filter.DeletedAtTo != nil {
+		t.Fatalf("filter=%+v", es.filter.EntryTypeFilter)
+	}
+
+	w = serve(s, "GET", "/v1/entry-types?deleted_at_to=yesterday", "")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusBadRequest, w.Body)
+	}
+}
+
+func TestServer_handleRestore(t *testing.T) {
+	s, _ := newTestServer()
+	cs, ds := &fakeCompanyService{}, &fakeDeedService{}
+	s.CompanyService, s.DeedService = cs, ds
+
+	t.Run("companies", func(t *testing.T) {
+		w := serve(s, "POST", "/v1/companies/restore", `{"id":[3,4]}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+		}
+		var got affected
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.N != 2 || len(cs.restore.ID) != 2 {
+			t.Fatalf("got %+v, restore %+v", got, cs.restore)
+		}
+	})
+
+	t.Run("deeds undrain", func(t *testing.T) {
+		w := serve(s, "POST", "/v1/deeds/restore", `{"id":[7],"undrain":true}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+		}
+		if !ds.restore.Undrain || ds.restore.ID[0] != 7 {
+			t.Fatalf("restore=%+v", ds.restore)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/innermond/dots"
+)
+
+func (s *Server) registerUnitRoutes(router *mux.Router) {
+	router.HandleFunc("", s.handleUnitFind).Methods("GET")
+	router.HandleFunc("/convert", s.handleUnitConvert).Methods("GET")
+	router.HandleFunc("/conversions", s.handleUnitConversionCreate).Methods("POST")
+	router.HandleFunc("/conversions", s.handleUnitConversionFind).Methods("GET")
+	router.HandleFunc("/conversions/{id}", s.handleUnitConversionDelete).Methods("DELETE")
+}
+
+func (s *Server) handleUnitFind(w http.ResponseWriter, r *http.Request) {
+	uu, n, err := s.UnitService.FindUnit(r.Context())
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &foundResponse[[]*dots.Unit]{uu, affected{n}})
+}
+
+func (s *Server) handleUnitConvert(w http.ResponseWriter, r *http.Request) {
+	convert := dots.UnitConvert{}
+	input(w, r, &convert, "convert unit")
+
+	c, err := s.UnitService.ConvertUnit(r.Context(), convert)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, c)
+}
+
+func (s *Server) handleUnitConversionCreate(w http.ResponseWriter, r *http.Request) {
+	var uc dots.UnitConversion
+	if ok := inputJSON(w, r, &uc, "create unit conversion"); !ok {
+		return
+	}
+
+	err := s.UnitService.CreateUnitConversion(r.Context(), &uc)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusCreated, &uc)
+}
+
+func (s *Server) handleUnitConversionFind(w http.ResponseWriter, r *http.Request) {
+	filter := dots.UnitConversionFilter{}
+	input(w, r, &filter, "find unit conversion")
+
+	cc, n, err := s.UnitService.FindUnitConversion(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &foundResponse[[]*dots.UnitConversion]{cc, affected{n}})
+}
+
+func (s *Server) handleUnitConversionDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, dots.Errorf(dots.EINVALID, "invalid ID format"))
+		return
+	}
+
+	n, err := s.UnitService.DeleteUnitConversion(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &affected{n})
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/innermond/dots"
+)
+
+type fakeUnitService struct {
+	dots.UnitService
+
+	convert dots.UnitConvert
+	created *dots.UnitConversion
+}
+
+func (s *fakeUnitService) ConvertUnit(ctx context.Context, convert dots.UnitConvert) (*dots.UnitConverted, error) {
+	s.convert = convert
+	return &dots.UnitConverted{}, nil
+}
+
+func (s *fakeUnitService) CreateUnitConversion(ctx context.Context, uc *dots.UnitConversion) error {
+	uc.ID = 9
+	s.created = uc
+	return nil
+}
+
+func TestServer_handleUnitConvert(t *testing.T) {
+	s, _ := newTestServer()
+	us := &fakeUnitService{}
+	s.UnitService = us
+
+	w := serve(s, "GET", "/v1/units/convert?quantity=1.5&from=rola&to=m2&entry_type_id=4", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	c := us.convert
+	if c.Quantity == nil || *c.Quantity != 1.5 || *c.From != "rola" || *c.To != "m2" || c.EntryTypeID == nil || *c.EntryTypeID != 4 {
+		t.Fatalf("convert=%+v", c)
+	}
+}
+
+func TestServer_handleUnitConversionCreate(t *testing.T) {
+	s, _ := newTestServer()
+	us := &fakeUnitService{}
+	s.UnitService = us
+
+	w := serve(s, "POST", "/v1/units/conversions", `{"from": "rola", "to": "m2", "factor": 25, "entry_type_id": 4}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	if us.created == nil || *us.created.From != "rola" || *us.created.EntryTypeID != 4 {
+		t.Fatalf("created=%+v", us.created)
+	}
+}
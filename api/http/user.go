@@ -1,24 +1,69 @@
 package http
 
 import (
-	"encoding/json"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/innermond/dots"
 )
 
 func (s *Server) registerUserRoutes(router *mux.Router) {
 	router.HandleFunc("", s.handleUserIndex).Methods("GET")
+	router.HandleFunc("", s.handleUserUpdate).Methods("PATCH")
+	router.HandleFunc("/password", s.handlePasswordChange).Methods("PATCH")
+	router.HandleFunc("/usage", s.handleUsage).Methods("GET")
+	router.HandleFunc("/security/events", s.handleLoginEventFind).Methods("GET")
+	router.HandleFunc("/tokens", s.handlePersonalTokenCreate).Methods("POST")
+	router.HandleFunc("/tokens", s.handlePersonalTokenFind).Methods("GET")
+	router.HandleFunc("/tokens/{id}", s.handlePersonalTokenDelete).Methods("DELETE")
 }
 
 func (s *Server) handleUserIndex(w http.ResponseWriter, r *http.Request) {
-	ses, err := s.getSession(r)
-	if err != nil && err != http.ErrNoCookie {
+	if err := dots.CanReadOwn(r.Context()); err != nil {
 		Error(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(&ses)
+	caller := dots.UserFromContext(r.Context())
+
+	// read it again so linked auths come along
+	u, err := s.UserService.FindUserByID(r.Context(), caller.ID)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, asCaller(caller, u))
+}
+
+func (s *Server) handleUserUpdate(w http.ResponseWriter, r *http.Request) {
+	var upd dots.UserUpdate
+	if ok := inputJSON(w, r, &upd, "edit user"); !ok {
+		return
+	}
+
+	if err := dots.CanWriteOwn(r.Context()); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	caller := dots.UserFromContext(r.Context())
+	u, err := s.UserService.UpdateUser(r.Context(), caller.ID, upd)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, asCaller(caller, u))
+}
+
+// asCaller shows the user as the request sees it, the api key trades
+// for a full powered token so it never leaves with a scoped one
+func asCaller(caller, u *dots.User) *dots.User {
+	u.ApiKey = ""
+	u.Powers = caller.Powers
+	u.Role = caller.Role
+	u.OrganisationID = caller.OrganisationID
+
+	return u
 }
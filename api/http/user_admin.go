@@ -0,0 +1,82 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/innermond/dots"
+)
+
+func (s *Server) registerUserAdminRoutes(router *mux.Router) {
+	router.HandleFunc("", s.handleUserAdminFind).Methods("GET")
+	router.HandleFunc("/{id}", s.handleUserAdminGet).Methods("GET")
+	router.HandleFunc("/{id}", s.handleUserAdminUpdate).Methods("PATCH")
+	router.HandleFunc("/{id}", s.handleUserAdminDelete).Methods("DELETE")
+}
+
+func (s *Server) handleUserAdminFind(w http.ResponseWriter, r *http.Request) {
+	// can accept missing r.Body
+	filter := dots.UserFilter{}
+	input(w, r, &filter, "find user")
+
+	uu, n, err := s.UserAdminService.FindUser(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &foundResponse[[]*dots.User]{uu, affected{n}})
+}
+
+func (s *Server) handleUserAdminGet(w http.ResponseWriter, r *http.Request) {
+	id, ok := ksuidVar(w, r, "id")
+	if !ok {
+		return
+	}
+
+	uu, _, err := s.UserAdminService.FindUser(r.Context(), dots.UserFilter{ID: &id, Limit: 1})
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	if len(uu) == 0 {
+		Error(w, r, dots.Errorf(dots.ENOTFOUND, "user not found"))
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, uu[0])
+}
+
+func (s *Server) handleUserAdminUpdate(w http.ResponseWriter, r *http.Request) {
+	id, ok := ksuidVar(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var upd dots.UserAdminUpdate
+	if ok := inputJSON(w, r, &upd, "admin edit user"); !ok {
+		return
+	}
+
+	u, err := s.UserAdminService.UpdateUser(r.Context(), id, upd)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, u)
+}
+
+func (s *Server) handleUserAdminDelete(w http.ResponseWriter, r *http.Request) {
+	id, ok := ksuidVar(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := s.UserAdminService.DeleteUser(r.Context(), id); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
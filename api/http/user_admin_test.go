@@ -0,0 +1,51 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/innermond/dots"
+	"github.com/segmentio/ksuid"
+)
+
+type fakeUserAdminService struct {
+	dots.UserAdminService
+
+	id  ksuid.KSUID
+	upd dots.UserAdminUpdate
+}
+
+func (s *fakeUserAdminService) UpdateUser(ctx context.Context, id ksuid.KSUID, upd dots.UserAdminUpdate) (*dots.User, error) {
+	s.id, s.upd = id, upd
+	return &dots.User{ID: id, Powers: upd.Apply(dots.PowerToManageOwn)}, nil
+}
+
+func TestServer_handleUserAdminUpdate(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		s, _ := newTestServer()
+		as := &fakeUserAdminService{}
+		s.UserAdminService = as
+
+		id := ksuid.New()
+		body := `{"name":"New Name","grant":["delete_own"],"revoke":["write_own"],"disabled":true}`
+		w := serve(s, "PATCH", "/v1/admin/users/"+id.String(), body)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+		}
+		if as.id != id {
+			t.Fatalf("id=%v, want %v", as.id, id)
+		}
+		if as.upd.Name == nil || *as.upd.Name != "New Name" {
+			t.Fatalf("name=%v, want %v", as.upd.Name, "New Name")
+		}
+		if as.upd.Disabled == nil || !*as.upd.Disabled {
+			t.Fatal("expected disabled")
+		}
+		if !strings.Contains(w.Body.String(), `"powers":["create_own","read_own","delete_own"]`) {
+			t.Fatalf("unexpected body %s", w.Body.String())
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/innermond/dots"
+	"github.com/segmentio/ksuid"
+)
+
+func TestServer_handleUserIndex(t *testing.T) {
+	t.Run("Viewer", func(t *testing.T) {
+		s, ts := newTestServer()
+		oid := ksuid.New()
+		ts.payload.OID = oid
+		s.OrganisationService = &fakeOrganisationService{roles: map[ksuid.KSUID]dots.Role{oid: dots.RoleViewer}}
+
+		w := serve(s, "GET", "/v1/me", "")
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+		}
+
+		var got struct {
+			ApiKey         string       `json:"api_key"`
+			Powers         []dots.Power `json:"powers"`
+			OrganisationID ksuid.KSUID  `json:"organisation_id"`
+			Role           dots.Role    `json:"role"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.ApiKey != "" {
+			t.Fatalf("api key=%q, want none", got.ApiKey)
+		}
+		if got.OrganisationID != oid || got.Role != dots.RoleViewer {
+			t.Fatalf("organisation=%v role=%v, want %v %v", got.OrganisationID, got.Role, oid, dots.RoleViewer)
+		}
+		if len(got.Powers) != 1 || got.Powers[0] != dots.ReadOwn {
+			t.Fatalf("powers=%v, want only %v", got.Powers, dots.ReadOwn)
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/innermond/dots"
+)
+
+func (s *Server) handleStockValue(w http.ResponseWriter, r *http.Request) {
+	filter := dots.StockValueFilter{}
+	input(w, r, &filter, "stock value")
+
+	vv, n, err := s.ValuationService.FindStockValue(r.Context(), filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, &foundResponse[[]*dots.StockValue]{vv, affected{n}})
+}
+
+func (s *Server) handleDeedCost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		Error(w, r, dots.Errorf(dots.EINVALID, "invalid ID format"))
+		return
+	}
+
+	filter := dots.DeedCostFilter{}
+	input(w, r, &filter, "deed cost")
+
+	dc, err := s.ValuationService.FindDeedCost(r.Context(), id, filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	outputJSON(w, r, http.StatusOK, dc)
+}
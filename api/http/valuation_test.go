@@ -0,0 +1,53 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/innermond/dots"
+)
+
+type fakeValuationService struct {
+	stock  dots.StockValueFilter
+	deedID int
+	deed   dots.DeedCostFilter
+}
+
+func (s *fakeValuationService) FindStockValue(ctx context.Context, filter dots.StockValueFilter) ([]*dots.StockValue, int, error) {
+	s.stock = filter
+	return []*dots.StockValue{}, 0, nil
+}
+
+func (s *fakeValuationService) FindDeedCost(ctx context.Context, id int, filter dots.DeedCostFilter) (*dots.DeedCost, error) {
+	s.deedID, s.deed = id, filter
+	return &dots.DeedCost{DeedID: id, Method: filter.Method}, nil
+}
+
+func TestServer_handleStockValue(t *testing.T) {
+	s, _ := newTestServer()
+	vs := &fakeValuationService{}
+	s.ValuationService = vs
+
+	w := serve(s, "GET", "/v1/entries/value?company_id=1&as_of=2023-06&method=lifo", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if vs.stock.CompanyID == nil || *vs.stock.CompanyID != 1 || vs.stock.AsOf == nil || vs.stock.Method != dots.ValuationLIFO {
+		t.Fatalf("filter=%+v", vs.stock)
+	}
+}
+
+func TestServer_handleDeedCost(t *testing.T) {
+	s, _ := newTestServer()
+	vs := &fakeValuationService{}
+	s.ValuationService = vs
+
+	w := serve(s, "GET", "/v1/deeds/3/cost?method=average", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if vs.deedID != 3 || vs.deed.Method != dots.ValuationAverage {
+		t.Fatalf("id=%d filter=%+v", vs.deedID, vs.deed)
+	}
+}
@@ -0,0 +1,107 @@
+package dots
+
+import (
+	"context"
+	"time"
+)
+
+// states of an inventory count
+const (
+	CountOpen      = "open"
+	CountApproved  = "approved"
+	CountCancelled = "cancelled"
+)
+
+// why counted stock differs from the computed one
+const (
+	ReasonDamage   = "damage"
+	ReasonTheft    = "theft"
+	ReasonExpired  = "expired"
+	ReasonMiscount = "miscount"
+	ReasonFound    = "found"
+	ReasonOther    = "other"
+)
+
+func validReason(reason string) error {
+	switch reason {
+	case ReasonDamage, ReasonTheft, ReasonExpired, ReasonMiscount, ReasonFound, ReasonOther:
+		return nil
+	}
+	return Errorf(EINVALID, "unknown reason %q, want damage, theft, expired, miscount, found or other", reason)
+}
+
+// InventoryCount is a session of counting what is on the shelf.
+// Approving it writes off the losses and brings in the gains
+type InventoryCount struct {
+	ID        int        `json:"id"`
+	Note      *string    `json:"note,omitempty"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty"`
+
+	Lines []*InventoryCountLine `json:"lines"`
+}
+
+type InventoryCountLine struct {
+	CompanyID   *int     `json:"company_id"`
+	EntryTypeID *int     `json:"entry_type_id"`
+	Counted     *float64 `json:"counted"`
+	Reason      *string  `json:"reason,omitempty"`
+
+	// Expected is the computed stock, live while the count is open
+	Expected *float64 `json:"expected,omitempty"`
+	// Variance is counted less expected, a loss when negative
+	Variance *float64 `json:"variance,omitempty"`
+	// DeedID drained a loss, EntryID brought in a gain
+	DeedID  *int `json:"deed_id,omitempty"`
+	EntryID *int `json:"entry_id,omitempty"`
+}
+
+func (l *InventoryCountLine) Validate() error {
+	if l.CompanyID == nil || l.EntryTypeID == nil || l.Counted == nil {
+		return Errorf(EINVALID, "company, entry type and counted are required")
+	}
+	if *l.Counted < 0 {
+		return Errorf(EINVALID, "counted cannot be negative")
+	}
+	if l.Reason != nil {
+		return validReason(*l.Reason)
+	}
+	return nil
+}
+
+// InventoryCountUpdate records counted lines, a line counted again replaces the former
+type InventoryCountUpdate struct {
+	Lines []*InventoryCountLine `json:"lines"`
+}
+
+func (u *InventoryCountUpdate) Validate() error {
+	if len(u.Lines) == 0 {
+		return Errorf(EINVALID, "nothing counted")
+	}
+	for _, l := range u.Lines {
+		if err := l.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type InventoryCountFilter struct {
+	ID     *int         `json:"id"`
+	Status *string      `json:"status"`
+	From   *PartialTime `json:"from,omitempty"`
+	To     *PartialTime `json:"to,omitempty"`
+
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+type InventoryCountService interface {
+	CreateInventoryCount(context.Context, *InventoryCount) error
+	FindInventoryCount(context.Context, InventoryCountFilter) ([]*InventoryCount, int, error)
+	UpdateInventoryCount(context.Context, int, InventoryCountUpdate) (*InventoryCount, error)
+	// ApproveInventoryCount posts the adjustments of the variances all at once
+	ApproveInventoryCount(context.Context, int) (*InventoryCount, error)
+	CancelInventoryCount(context.Context, int) (*InventoryCount, error)
+}
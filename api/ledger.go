@@ -0,0 +1,43 @@
+package dots
+
+import (
+	"context"
+	"time"
+)
+
+// kinds of ledger lines
+const (
+	LedgerIn       = "in"
+	LedgerOut      = "out"
+	LedgerReversal = "reversal"
+)
+
+// LedgerLine is a stock move of an entry type of a company, entries come in,
+// drains go out and what deleted or lowered drains give back comes in as reversals
+type LedgerLine struct {
+	CompanyID   int       `json:"company_id"`
+	EntryTypeID int       `json:"entry_type_id"`
+	At          time.Time `json:"at"`
+	Kind        string    `json:"kind"`
+	EntryID     int       `json:"entry_id"`
+	DeedID      *int      `json:"deed_id,omitempty"`
+	// Quantity is negative for drains
+	Quantity float64 `json:"quantity"`
+	// Balance is the stock after the move
+	Balance float64 `json:"balance"`
+}
+
+type LedgerFilter struct {
+	CompanyID   *int `json:"company_id"`
+	EntryTypeID *int `json:"entry_type_id"`
+	// From is inclusive, To is not; balances run from the very first move anyway
+	From *PartialTime `json:"from,omitempty"`
+	To   *PartialTime `json:"to,omitempty"`
+
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+type LedgerService interface {
+	FindLedger(context.Context, LedgerFilter) ([]*LedgerLine, int, error)
+}
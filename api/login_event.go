@@ -0,0 +1,83 @@
+package dots
+
+import (
+	"context"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+// ways of getting a token or a session
+const (
+	LoginPassword = "password"
+	LoginRefresh  = "refresh"
+	LoginOAuth    = "oauth"
+)
+
+// LoginReasonLocked marks attempts refused by lockout, those do not count as failures
+const LoginReasonLocked = "locked"
+
+// LoginEvent records an attempt to get in, failed or not
+type LoginEvent struct {
+	ID int `json:"id"`
+	// UserID is nil when the attempt points to no known user
+	UserID *ksuid.KSUID `json:"user_id"`
+	Email  string       `json:"email,omitempty"`
+	Kind   string       `json:"kind"`
+	// Source is the oauth provider
+	Source    string    `json:"source,omitempty"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason,omitempty"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type LoginEventFilter struct {
+	Success *bool   `json:"success"`
+	Kind    *string `json:"kind"`
+
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// LockoutPolicy locks an email out after Threshold failed password logins in a row,
+// every failure after that doubles the wait starting from Base up to Max
+type LockoutPolicy struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+}
+
+var DefaultLockoutPolicy = LockoutPolicy{Threshold: 5, Base: time.Minute, Max: time.Hour}
+
+// Wait tells how long is left to wait at now after failures, the last one at last
+func (p LockoutPolicy) Wait(failures int, last time.Time, now time.Time) time.Duration {
+	if failures < p.Threshold {
+		return 0
+	}
+
+	wait := p.Base
+	for i := p.Threshold; i < failures && wait < p.Max; i++ {
+		wait *= 2
+	}
+	if wait > p.Max {
+		wait = p.Max
+	}
+
+	left := last.Add(wait).Sub(now)
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+type LoginEventService interface {
+	CreateLoginEvent(context.Context, *LoginEvent) error
+	// FindLoginEvent lists the events of the caller
+	FindLoginEvent(context.Context, LoginEventFilter) ([]*LoginEvent, int, error)
+	// PasswordLogin runs login for the email of the event one attempt at a time
+	// and records the event with its outcome, a locked out email gets the wait
+	// left instead and login is not run
+	PasswordLogin(ctx context.Context, e *LoginEvent, login func() error) (time.Duration, error)
+}
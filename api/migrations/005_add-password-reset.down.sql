@@ -0,0 +1 @@
+drop table if exists core.password_reset;
@@ -0,0 +1,12 @@
+create table core.password_reset (
+    id integer generated always as identity primary key,
+    user_id core.ksuid not null references core."user"(id) on delete cascade,
+    token_hash text not null unique,
+    expires_at timestamp with time zone not null,
+    used_at timestamp with time zone,
+    created_at timestamp with time zone default now() not null
+);
+
+alter table core.password_reset owner to dots_owner;
+
+create index password_reset_user_id on core.password_reset using btree (user_id);
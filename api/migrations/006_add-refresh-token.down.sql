@@ -0,0 +1,2 @@
+drop table if exists core.token_revocation;
+drop table if exists core.refresh_token;
@@ -0,0 +1,29 @@
+create table core.refresh_token (
+    id integer generated always as identity primary key,
+    user_id core.ksuid not null references core."user"(id) on delete cascade,
+    family_id core.ksuid not null,
+    token_hash text not null unique,
+    access_id core.ksuid not null,
+    access_expires_at timestamp with time zone not null,
+    expires_at timestamp with time zone not null,
+    used_at timestamp with time zone,
+    revoked_at timestamp with time zone,
+    created_at timestamp with time zone default now() not null
+);
+
+alter table core.refresh_token owner to dots_owner;
+
+create index refresh_token_user_id on core.refresh_token using btree (user_id);
+create index refresh_token_family_id on core.refresh_token using btree (family_id);
+create index refresh_token_access_id on core.refresh_token using btree (access_id);
+
+create table core.token_revocation (
+    token_id core.ksuid primary key,
+    user_id core.ksuid not null references core."user"(id) on delete cascade,
+    expires_at timestamp with time zone not null,
+    created_at timestamp with time zone default now() not null
+);
+
+alter table core.token_revocation owner to dots_owner;
+
+create index token_revocation_expires_at on core.token_revocation using btree (expires_at);
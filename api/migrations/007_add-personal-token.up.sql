@@ -0,0 +1,15 @@
+create table core.personal_token (
+    id integer generated always as identity primary key,
+    user_id core.ksuid not null references core."user"(id) on delete cascade,
+    name text not null,
+    token_hash text not null unique,
+    scopes text[] not null,
+    expires_at timestamp with time zone not null,
+    last_used_at timestamp with time zone,
+    created_at timestamp with time zone default now() not null,
+    unique (user_id, name)
+);
+
+alter table core.personal_token owner to dots_owner;
+
+create index personal_token_user_id on core.personal_token using btree (user_id);
@@ -0,0 +1 @@
+alter table core."user" drop column if exists disabled_at;
@@ -0,0 +1 @@
+alter table core."user" add column if not exists disabled_at timestamp with time zone;
@@ -0,0 +1,28 @@
+-- data of shared organisations cannot go back to a user tenant
+-- so this only succeeds while every tid is still a personal organisation
+alter table only core.entry_type drop constraint if exists entry_type_tid_fk_organisation_id;
+alter table only core.entry_type
+    add constraint entry_type_tid_fk_user_id foreign key (tid) references core."user"(id);
+
+alter table only core.entry drop constraint if exists entry_tid_fk_organisation_id;
+alter table only core.entry
+    add constraint entry_tid_fk_user_id foreign key (tid) references core."user"(id);
+
+alter table only core.drain drop constraint if exists drain_tid_fk_organisation_id;
+alter table only core.drain
+    add constraint drain_tid_fk_user_id foreign key (tid) references core."user"(id);
+
+alter table only core.deed drop constraint if exists deed_tid_fk_organisation_id;
+alter table only core.deed
+    add constraint deed_tid_fk_user_id foreign key (tid) references core."user"(id);
+
+alter table only core.company drop constraint if exists company_tid_fk_organisation_id;
+alter table only core.company
+    add constraint company_tid_fk_user_tid foreign key (tid) references core."user"(id);
+
+comment on function core.get_tenent() is null;
+
+alter table core.refresh_token drop column if exists organisation_id;
+
+drop table if exists core.membership;
+drop table if exists core.organisation;
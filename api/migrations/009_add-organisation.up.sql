@@ -0,0 +1,55 @@
+
+create table core.organisation (
+    id core.ksuid default core.ksuid() not null primary key,
+    name text not null,
+    created_at timestamp with time zone default now() not null,
+    updated_at timestamp with time zone
+);
+
+alter table core.organisation owner to dots_owner;
+
+create table core.membership (
+    organisation_id core.ksuid not null references core.organisation(id) on delete cascade,
+    user_id core.ksuid not null references core."user"(id) on delete cascade,
+    role text not null,
+    created_at timestamp with time zone default now() not null,
+    primary key (organisation_id, user_id),
+    constraint membership_role_check check (role = any (array['owner'::text, 'admin'::text, 'member'::text, 'viewer'::text]))
+);
+
+alter table core.membership owner to dots_owner;
+
+create index membership_user_id on core.membership using btree (user_id);
+
+-- every user gets a personal organisation sharing its id so existing tids stay valid
+insert into core.organisation (id, name, created_at)
+select id, name, created_at from core."user";
+
+insert into core.membership (organisation_id, user_id, role, created_at)
+select id, id, 'owner', created_at from core."user";
+
+-- tenants are organisations from now on
+alter table only core.company drop constraint if exists company_tid_fk_user_tid;
+alter table only core.company
+    add constraint company_tid_fk_organisation_id foreign key (tid) references core.organisation(id);
+
+alter table only core.deed drop constraint if exists deed_tid_fk_user_id;
+alter table only core.deed
+    add constraint deed_tid_fk_organisation_id foreign key (tid) references core.organisation(id);
+
+alter table only core.drain drop constraint if exists drain_tid_fk_user_id;
+alter table only core.drain
+    add constraint drain_tid_fk_organisation_id foreign key (tid) references core.organisation(id);
+
+alter table only core.entry drop constraint if exists entry_tid_fk_user_id;
+alter table only core.entry
+    add constraint entry_tid_fk_organisation_id foreign key (tid) references core.organisation(id);
+
+alter table only core.entry_type drop constraint if exists entry_type_tid_fk_user_id;
+alter table only core.entry_type
+    add constraint entry_type_tid_fk_organisation_id foreign key (tid) references core.organisation(id);
+
+-- refreshed tokens keep the organisation they were issued for
+alter table core.refresh_token add column if not exists organisation_id core.ksuid;
+
+comment on function core.get_tenent() is 'active organisation of the connection, set as app.uid';
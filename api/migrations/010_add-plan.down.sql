@@ -0,0 +1,3 @@
+-- packages still in use stay
+delete from core.package p
+where not exists (select 1 from core."user" u where u.package_kind = p.name);
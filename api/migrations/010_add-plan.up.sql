@@ -0,0 +1,10 @@
+-- plans every user may be on, field_len caps text fields keyed as resource.field
+insert into core.package (name, company, deed, drain, entry_type, entry, field_len) values
+('one eye', 1, 500, 5000, 50, 1000,
+    '{"company.longname": 100, "company.tin": 20, "company.rn": 30, "deed.title": 200, "deed.unit": 10, "entry_type.code": 50, "entry_type.unit": 10, "entry_type.description": 500}'),
+('two eyes', 10, 50000, 500000, 500, 100000,
+    '{"company.longname": 200, "company.tin": 20, "company.rn": 30, "deed.title": 500, "deed.unit": 20, "entry_type.code": 100, "entry_type.unit": 20, "entry_type.description": 2000}'),
+('three eyes', 100, 5000000, 50000000, 5000, 10000000,
+    '{"company.longname": 500, "company.tin": 30, "company.rn": 50, "deed.title": 1000, "deed.unit": 30, "entry_type.code": 200, "entry_type.unit": 30, "entry_type.description": 10000}')
+on conflict (name) do nothing;
+
@@ -0,0 +1,8 @@
+-- token buckets shared by every api server
+create table core.rate_limit (
+    key text not null primary key,
+    tokens double precision not null,
+    updated_at timestamp with time zone not null
+);
+
+alter table core.rate_limit owner to dots_owner;
@@ -0,0 +1,19 @@
+create table core.login_event (
+    id bigint generated always as identity primary key,
+    user_id core.ksuid references core."user"(id) on delete cascade,
+    email text,
+    kind text not null,
+    source text,
+    success boolean not null,
+    reason text,
+    ip text,
+    user_agent text,
+    created_at timestamp with time zone default now() not null,
+    constraint login_event_kind_check check (kind = any (array['password'::text, 'refresh'::text, 'oauth'::text]))
+);
+
+alter table core.login_event owner to dots_owner;
+
+create index login_event_user_id on core.login_event using btree (user_id, created_at);
+-- lockout counts failures per email
+create index login_event_email on core.login_event using btree (lower(email), created_at);
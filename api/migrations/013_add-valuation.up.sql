@@ -0,0 +1,14 @@
+alter table core.entry add column if not exists unitcost numeric(15,2);
+alter table core.entry add constraint check_entry_unitcost check (unitcost >= 0);
+
+-- valuation orders drains in time
+alter table core.drain add column if not exists drained_at timestamp with time zone default now() not null;
+-- older drains are taken as made when their entry came in
+update core.drain d set drained_at = e.date_added
+from core.entry e
+where e.id = d.entry_id and e.date_added is not null;
+
+create or replace view api.entry with (security_invoker=true) as 
+select id, entry_type_id, date_added, quantity, company_id, unitcost
+from core.entry
+where deleted_at is null;
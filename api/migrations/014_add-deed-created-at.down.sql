@@ -0,0 +1,9 @@
+drop view if exists api.deed;
+
+create view api.deed with (security_invoker=true) as 
+select id, company_id, title, quantity, unit, unitprice
+from core.deed
+where deleted_at is null;
+
+drop index if exists core.deed_created_at;
+alter table core.deed drop column if exists created_at;
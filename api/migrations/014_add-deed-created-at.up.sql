@@ -0,0 +1,13 @@
+-- profitability groups deeds by month
+alter table core.deed add column if not exists created_at timestamp with time zone default now() not null;
+-- older deeds are dated by their first drain
+update core.deed d set created_at = x.drained_at
+from (select deed_id, min(drained_at) drained_at from core.drain group by deed_id) x
+where x.deed_id = d.id;
+
+create index if not exists deed_created_at on core.deed using btree (created_at);
+
+create or replace view api.deed with (security_invoker=true) as 
+select id, company_id, title, quantity, unit, unitprice, created_at
+from core.deed
+where deleted_at is null;
@@ -0,0 +1,2 @@
+drop index if exists core.drain_entry_id;
+alter table core.drain drop column if exists deleted_at;
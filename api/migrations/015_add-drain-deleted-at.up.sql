@@ -0,0 +1,6 @@
+-- the ledger shows deleted drains as reversals made at deleted_at
+alter table core.drain add column if not exists deleted_at timestamp with time zone;
+-- when older drains got deleted is unknown, they are taken back as soon as made
+update core.drain set deleted_at = drained_at where is_deleted = true;
+
+create index if not exists drain_entry_id on core.drain using btree (entry_id);
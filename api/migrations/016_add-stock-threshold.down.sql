@@ -0,0 +1,2 @@
+drop table if exists core.stock_alert;
+drop table if exists core.stock_threshold;
@@ -0,0 +1,39 @@
+create table core.stock_threshold (
+    id integer generated always as identity primary key,
+    entry_type_id integer not null references core.entry_type(id) on delete cascade,
+    -- a threshold without company is for every company
+    company_id integer references core.company(id) on delete cascade,
+    reorder_level double precision not null,
+    target_level double precision not null,
+    tid core.ksuid default core.get_tenent() not null references core.organisation(id),
+    constraint stock_threshold_levels_check check (reorder_level >= 0 and target_level >= reorder_level)
+);
+
+alter table core.stock_threshold owner to dots_owner;
+
+create unique index stock_threshold_entry_type on core.stock_threshold using btree (entry_type_id) where company_id is null;
+create unique index stock_threshold_entry_type_company on core.stock_threshold using btree (entry_type_id, company_id) where company_id is not null;
+
+alter table core.stock_threshold enable row level security;
+create policy stock_threshold_tent on core.stock_threshold to dots_api_user using (((tid)::text = (core.get_tenent())::text));
+
+create table core.stock_alert (
+    id bigint generated always as identity primary key,
+    company_id integer not null references core.company(id) on delete cascade,
+    entry_type_id integer not null references core.entry_type(id) on delete cascade,
+    deed_id bigint references core.deed(id) on delete set null,
+    entry_id bigint references core.entry(id) on delete set null,
+    -- stock left after the drain
+    quantity double precision not null,
+    reorder_level double precision not null,
+    target_level double precision not null,
+    created_at timestamp with time zone default now() not null,
+    tid core.ksuid default core.get_tenent() not null references core.organisation(id)
+);
+
+alter table core.stock_alert owner to dots_owner;
+
+create index stock_alert_created_at on core.stock_alert using btree (tid, created_at);
+
+alter table core.stock_alert enable row level security;
+create policy stock_alert_tent on core.stock_alert to dots_api_user using (((tid)::text = (core.get_tenent())::text));
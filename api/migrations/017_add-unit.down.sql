@@ -0,0 +1,3 @@
+drop table if exists core.unit_conversion;
+drop table if exists core.unit_alias;
+drop table if exists core.unit;
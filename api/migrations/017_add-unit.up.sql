@@ -0,0 +1,79 @@
+-- units of measure by their UN/ECE Recommendation 20 code, shared by every tenant
+create table core.unit (
+    code character varying(3) primary key,
+    name character varying not null
+);
+
+alter table core.unit owner to dots_owner;
+
+insert into core.unit (code, name) values
+('H87', 'piece'),
+('C62', 'one'),
+('SET', 'set'),
+('PR', 'pair'),
+('BX', 'box'),
+('PK', 'pack'),
+('RO', 'roll'),
+('ST', 'sheet'),
+('MMT', 'millimetre'),
+('CMT', 'centimetre'),
+('MTR', 'metre'),
+('MTK', 'square metre'),
+('MTQ', 'cubic metre'),
+('MLT', 'millilitre'),
+('LTR', 'litre'),
+('GRM', 'gram'),
+('KGM', 'kilogram'),
+('TNE', 'tonne');
+
+-- free text units as they are found in entry types and deeds
+create table core.unit_alias (
+    alias character varying primary key,
+    code character varying(3) not null references core.unit(code) on delete cascade,
+    constraint unit_alias_lower_check check (alias = lower(alias))
+);
+
+alter table core.unit_alias owner to dots_owner;
+
+insert into core.unit_alias (alias, code) values
+('pcs', 'H87'), ('pc', 'H87'), ('buc', 'H87'), ('bucata', 'H87'), ('piese', 'H87'), ('piesa', 'H87'),
+('set', 'SET'), ('pair', 'PR'), ('box', 'BX'), ('cutie', 'BX'), ('pack', 'PK'), ('pachet', 'PK'),
+('roll', 'RO'), ('rola', 'RO'), ('sheet', 'ST'), ('coala', 'ST'),
+('mm', 'MMT'), ('cm', 'CMT'), ('m', 'MTR'), ('ml', 'MLT'),
+('m2', 'MTK'), ('mp', 'MTK'), ('sqm', 'MTK'), ('m3', 'MTQ'), ('mc', 'MTQ'),
+('l', 'LTR'), ('g', 'GRM'), ('kg', 'KGM'), ('t', 'TNE');
+
+-- factor turns a quantity in from_unit into to_unit, it is read backwards too;
+-- rows without tid are shared, rows with an entry type hold for it alone, as rolls to square metres
+create table core.unit_conversion (
+    id integer generated always as identity primary key,
+    from_unit character varying(3) not null references core.unit(code),
+    to_unit character varying(3) not null references core.unit(code),
+    factor double precision not null,
+    entry_type_id integer references core.entry_type(id) on delete cascade,
+    tid core.ksuid default core.get_tenent() references core.organisation(id),
+    constraint unit_conversion_factor_check check (factor > 0),
+    constraint unit_conversion_units_check check (from_unit <> to_unit),
+    constraint unit_conversion_tid_check check (entry_type_id is null or tid is not null)
+);
+
+alter table core.unit_conversion owner to dots_owner;
+
+create unique index unit_conversion_shared on core.unit_conversion using btree (from_unit, to_unit) where tid is null;
+create unique index unit_conversion_tenant on core.unit_conversion using btree (tid, from_unit, to_unit) where tid is not null and entry_type_id is null;
+create unique index unit_conversion_entry_type on core.unit_conversion using btree (entry_type_id, from_unit, to_unit) where entry_type_id is not null;
+
+alter table core.unit_conversion enable row level security;
+create policy unit_conversion_tent on core.unit_conversion to dots_api_user
+    using (tid is null or (tid)::text = (core.get_tenent())::text)
+    with check ((tid)::text = (core.get_tenent())::text);
+
+insert into core.unit_conversion (from_unit, to_unit, factor, tid) values
+('CMT', 'MMT', 10, null),
+('MTR', 'CMT', 100, null),
+('MTR', 'MMT', 1000, null),
+('LTR', 'MLT', 1000, null),
+('MTQ', 'LTR', 1000, null),
+('KGM', 'GRM', 1000, null),
+('TNE', 'KGM', 1000, null),
+('PR', 'H87', 2, null);
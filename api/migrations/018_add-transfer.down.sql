@@ -0,0 +1,2 @@
+drop table if exists core.transfer_line;
+drop table if exists core.transfer;
@@ -0,0 +1,37 @@
+-- a transfer drains entries of a company through its own deed
+-- and brings the quantities into new entries of another company
+create table core.transfer (
+    id integer generated always as identity primary key,
+    from_company_id integer not null references core.company(id),
+    to_company_id integer not null references core.company(id),
+    entry_type_id integer not null references core.entry_type(id),
+    quantity double precision not null,
+    deed_id bigint not null unique references core.deed(id),
+    note character varying,
+    created_at timestamp with time zone default now() not null,
+    tid core.ksuid default core.get_tenent() not null references core.organisation(id),
+    constraint transfer_quantity_check check (quantity > 0),
+    constraint transfer_companies_check check (from_company_id <> to_company_id)
+);
+
+alter table core.transfer owner to dots_owner;
+
+create index transfer_created_at on core.transfer using btree (tid, created_at);
+
+alter table core.transfer enable row level security;
+create policy transfer_tent on core.transfer to dots_api_user using (((tid)::text = (core.get_tenent())::text));
+
+-- every piece taken from a source entry and the target entry it became
+create table core.transfer_line (
+    transfer_id integer not null references core.transfer(id) on delete cascade,
+    source_entry_id bigint not null references core.entry(id),
+    target_entry_id bigint not null unique references core.entry(id),
+    quantity double precision not null,
+    tid core.ksuid default core.get_tenent() not null references core.organisation(id),
+    primary key (transfer_id, source_entry_id)
+);
+
+alter table core.transfer_line owner to dots_owner;
+
+alter table core.transfer_line enable row level security;
+create policy transfer_line_tent on core.transfer_line to dots_api_user using (((tid)::text = (core.get_tenent())::text));
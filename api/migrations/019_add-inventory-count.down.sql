@@ -0,0 +1,2 @@
+drop table if exists core.inventory_count_line;
+drop table if exists core.inventory_count;
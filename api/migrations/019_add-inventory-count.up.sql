@@ -0,0 +1,40 @@
+-- a count session sets what is on the shelf against the computed stock
+create table core.inventory_count (
+    id integer generated always as identity primary key,
+    note character varying,
+    status character varying default 'open' not null,
+    created_at timestamp with time zone default now() not null,
+    closed_at timestamp with time zone,
+    tid core.ksuid default core.get_tenent() not null references core.organisation(id),
+    constraint inventory_count_status_check check (status in ('open', 'approved', 'cancelled'))
+);
+
+alter table core.inventory_count owner to dots_owner;
+
+create index inventory_count_created_at on core.inventory_count using btree (tid, created_at);
+
+alter table core.inventory_count enable row level security;
+create policy inventory_count_tent on core.inventory_count to dots_api_user using (((tid)::text = (core.get_tenent())::text));
+
+-- expected is kept on approval, a loss is drained by deed_id, a gain comes in as entry_id
+create table core.inventory_count_line (
+    count_id integer not null references core.inventory_count(id) on delete cascade,
+    company_id integer not null references core.company(id),
+    entry_type_id integer not null references core.entry_type(id),
+    counted double precision not null,
+    reason character varying,
+    expected double precision,
+    deed_id bigint references core.deed(id),
+    entry_id bigint references core.entry(id),
+    tid core.ksuid default core.get_tenent() not null references core.organisation(id),
+    primary key (count_id, company_id, entry_type_id),
+    constraint inventory_count_line_counted_check check (counted >= 0),
+    constraint inventory_count_line_reason_check check (reason in ('damage', 'theft', 'expired', 'miscount', 'found', 'other'))
+);
+
+alter table core.inventory_count_line owner to dots_owner;
+
+create index inventory_count_line_deed_id on core.inventory_count_line using btree (deed_id) where deed_id is not null;
+
+alter table core.inventory_count_line enable row level security;
+create policy inventory_count_line_tent on core.inventory_count_line to dots_api_user using (((tid)::text = (core.get_tenent())::text));
@@ -0,0 +1,23 @@
+drop view if exists api.entry_with_quantity_drained;
+drop view if exists api.entry;
+
+create view api.entry with (security_invoker=true) as 
+select id, entry_type_id, date_added, quantity, company_id, unitcost
+from core.entry
+where deleted_at is null;
+
+create view api.entry_with_quantity_drained as 
+select 
+  e.id, e.entry_type_id, e.date_added, e.company_id,
+  e.quantity quantity_initial,
+  (
+    select
+      coalesce(sum(case when d.is_deleted = true then 0 else d.quantity end), 0)
+    from core.drain d
+    where d.entry_id = e.id
+  ) quantity_drained
+from api.entry e;
+
+drop index if exists core.entry_expires_at_idx;
+alter table core.entry drop column if exists expires_at;
+alter table core.entry drop column if exists lot;
@@ -0,0 +1,22 @@
+alter table core.entry add column if not exists lot text;
+alter table core.entry add column if not exists expires_at date;
+
+create index if not exists entry_expires_at_idx on core.entry (expires_at) where expires_at is not null and deleted_at is null;
+
+create or replace view api.entry with (security_invoker=true) as 
+select id, entry_type_id, date_added, quantity, company_id, unitcost, lot, expires_at
+from core.entry
+where deleted_at is null;
+
+create or replace view api.entry_with_quantity_drained as 
+select 
+  e.id, e.entry_type_id, e.date_added, e.company_id,
+  e.quantity quantity_initial,
+  (
+    select
+      coalesce(sum(case when d.is_deleted = true then 0 else d.quantity end), 0)
+    from core.drain d
+    where d.entry_id = e.id
+  ) quantity_drained,
+  e.lot, e.expires_at
+from api.entry e;
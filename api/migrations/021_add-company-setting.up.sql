@@ -0,0 +1,12 @@
+-- the choices a company makes once for all its documents,
+-- strategies are checked by the api as they grow in number
+create table core.company_setting (
+    company_id integer not null primary key references core.company(id) on delete cascade,
+    distribute_strategy character varying,
+    tid core.ksuid default core.get_tenent() not null references core.organisation(id)
+);
+
+alter table core.company_setting owner to dots_owner;
+
+alter table core.company_setting enable row level security;
+create policy company_setting_tent on core.company_setting to dots_api_user using (((tid)::text = (core.get_tenent())::text));
@@ -0,0 +1,43 @@
+-- a reservation holds stock for a deed without draining it, till it expires;
+-- it holds an entry or any entries of an entry type of the company of the deed
+create table core.reservation (
+    id integer generated always as identity primary key,
+    deed_id bigint not null references core.deed(id) on delete cascade,
+    company_id integer not null references core.company(id) on delete cascade,
+    entry_id bigint references core.entry(id) on delete cascade,
+    entry_type_id integer references core.entry_type(id) on delete cascade,
+    quantity double precision not null,
+    expires_at timestamp with time zone not null,
+    created_at timestamp with time zone default now() not null,
+    tid core.ksuid default core.get_tenent() not null references core.organisation(id),
+    constraint reservation_quantity_check check (quantity > 0),
+    constraint reservation_target_check check ((entry_id is null) <> (entry_type_id is null))
+);
+
+alter table core.reservation owner to dots_owner;
+
+create index reservation_entry on core.reservation using btree (entry_id, expires_at) where entry_id is not null;
+create index reservation_entry_type on core.reservation using btree (company_id, entry_type_id, expires_at) where entry_type_id is not null;
+create index reservation_deed on core.reservation using btree (deed_id);
+
+alter table core.reservation enable row level security;
+create policy reservation_tent on core.reservation to dots_api_user using (((tid)::text = (core.get_tenent())::text));
+
+create or replace view api.entry_with_quantity_drained as 
+select 
+  e.id, e.entry_type_id, e.date_added, e.company_id,
+  e.quantity quantity_initial,
+  (
+    select
+      coalesce(sum(case when d.is_deleted = true then 0 else d.quantity end), 0)
+    from core.drain d
+    where d.entry_id = e.id
+  ) quantity_drained,
+  e.lot, e.expires_at,
+  (
+    select
+      coalesce(sum(r.quantity), 0)
+    from core.reservation r
+    where r.entry_id = e.id and r.expires_at > now()
+  ) quantity_reserved
+from api.entry e;
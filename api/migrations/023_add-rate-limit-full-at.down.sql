@@ -0,0 +1,2 @@
+drop index if exists core.rate_limit_full_at;
+alter table core.rate_limit drop column if exists full_at;
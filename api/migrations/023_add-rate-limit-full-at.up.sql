@@ -0,0 +1,4 @@
+-- a bucket full again is as good as a new one and can be forgotten
+alter table core.rate_limit add column if not exists full_at timestamp with time zone default now() not null;
+
+create index if not exists rate_limit_full_at on core.rate_limit using btree (full_at);
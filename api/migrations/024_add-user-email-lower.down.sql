@@ -0,0 +1 @@
+drop index if exists core.user_email_lower;
@@ -0,0 +1,2 @@
+-- logins are linked by email whatever its case
+create unique index if not exists user_email_lower on core."user" using btree (lower(email));
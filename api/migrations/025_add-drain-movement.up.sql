@@ -0,0 +1,27 @@
+-- every change of what a deed drains from an entry, rows are only ever added
+-- so stock as of a past time stays what it was whatever gets edited later
+create table core.drain_movement (
+    id bigint generated always as identity primary key,
+    deed_id bigint not null references core.deed(id) on delete cascade,
+    entry_id bigint not null references core.entry(id) on delete cascade,
+    quantity double precision not null,
+    moved_at timestamp with time zone default now() not null,
+    tid core.ksuid default core.get_tenent() not null references core.organisation(id)
+);
+
+alter table core.drain_movement owner to dots_owner;
+
+create index drain_movement_entry_id on core.drain_movement using btree (entry_id, moved_at);
+create index drain_movement_deed_id on core.drain_movement using btree (deed_id, entry_id);
+
+alter table core.drain_movement enable row level security;
+create policy drain_movement_tent on core.drain_movement to dots_api_user using (((tid)::text = (core.get_tenent())::text));
+
+-- edits made before are lost, older history is rebuilt from drains as they stand:
+-- a drain counts from drained_at with its current quantity, a deleted one goes back at deleted_at
+insert into core.drain_movement (deed_id, entry_id, quantity, moved_at, tid)
+select deed_id, entry_id, quantity, drained_at, tid from core.drain;
+
+insert into core.drain_movement (deed_id, entry_id, quantity, moved_at, tid)
+select deed_id, entry_id, -quantity, deleted_at, tid from core.drain
+where is_deleted = true and deleted_at is not null;
@@ -0,0 +1,101 @@
+package dots
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleAdmin  Role = "admin"
+	RoleMember Role = "member"
+	RoleViewer Role = "viewer"
+)
+
+func (r Role) Validate() error {
+	switch r {
+	case RoleOwner, RoleAdmin, RoleMember, RoleViewer:
+		return nil
+	}
+	return Errorf(EINVALID, "unknown role %q", r)
+}
+
+// CanManage tells if the role may handle the members of its organisation
+func (r Role) CanManage() bool {
+	return r == RoleOwner || r == RoleAdmin
+}
+
+// Scope narrows the user powers to what the role allows inside the organisation
+func (r Role) Scope(powers []Power) []Power {
+	if r == RoleViewer {
+		return ScopePowers(powers, []Power{ReadOwn})
+	}
+	return powers
+}
+
+// Organisation is a tenant, its ID is what row level security keys off.
+// Every user owns a personal organisation sharing the user ID
+type Organisation struct {
+	ID        ksuid.KSUID `json:"id"`
+	Name      string      `json:"name"`
+	CreatedAt time.Time   `json:"created_at"`
+
+	// Role is the one of the caller
+	Role Role `json:"role"`
+}
+
+func (o *Organisation) Validate() error {
+	o.Name = strings.TrimSpace(o.Name)
+	if o.Name == "" {
+		return Errorf(EINVALID, "organisation name required")
+	}
+	return printable(map[string]*string{"name": &o.Name})
+}
+
+type Member struct {
+	OrganisationID ksuid.KSUID `json:"organisation_id"`
+	UserID         ksuid.KSUID `json:"user_id"`
+	Name           string      `json:"name"`
+	Email          string      `json:"email"`
+	Role           Role        `json:"role"`
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+type MemberAdd struct {
+	Email string `json:"email"`
+	Role  Role   `json:"role"`
+}
+
+func (ma *MemberAdd) Validate() error {
+	ma.Email = strings.ToLower(strings.TrimSpace(ma.Email))
+	if ma.Email == "" {
+		return Errorf(EINVALID, "member email required")
+	}
+	return ma.Role.Validate()
+}
+
+type MemberUpdate struct {
+	Role Role `json:"role"`
+}
+
+func (mu *MemberUpdate) Validate() error {
+	return mu.Role.Validate()
+}
+
+type OrganisationService interface {
+	// CreateOrganisation makes the caller its owner
+	CreateOrganisation(context.Context, *Organisation) error
+	// FindOrganisation lists the organisations the caller belongs to
+	FindOrganisation(context.Context) ([]*Organisation, int, error)
+	FindMember(context.Context, ksuid.KSUID) ([]*Member, int, error)
+	AddMember(context.Context, ksuid.KSUID, MemberAdd) (*Member, error)
+	UpdateMember(context.Context, ksuid.KSUID, ksuid.KSUID, MemberUpdate) (*Member, error)
+	RemoveMember(context.Context, ksuid.KSUID, ksuid.KSUID) error
+	// Membership returns the role of a user inside an organisation
+	Membership(context.Context, ksuid.KSUID, ksuid.KSUID) (Role, error)
+}
@@ -3,6 +3,7 @@ package dots
 import (
 	"database/sql/driver"
 	"errors"
+	"fmt"
 	"regexp"
 	"strconv"
 	"time"
@@ -20,7 +21,17 @@ func (pt *PartialTime) Scan(v interface{}) error {
 		return nil
 	}
 
-	t, err := time.Parse(time.RFC3339, v.(string))
+	// drivers give dates and timestamps as they are
+	if t, ok := v.(time.Time); ok {
+		*pt = PartialTime(t)
+		return nil
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into a partial time", v)
+	}
+	t, err := time.Parse(time.RFC3339, s)
 	if err != nil {
 		return err
 	}
@@ -29,6 +40,10 @@ func (pt *PartialTime) Scan(v interface{}) error {
 	return nil
 }
 
+func (pt PartialTime) MarshalJSON() ([]byte, error) {
+	return time.Time(pt).MarshalJSON()
+}
+
 func (pt *PartialTime) UnmarshalJSON(b []byte) error {
 	s := string(b)
 	if s == "null" || s == `""` {
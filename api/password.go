@@ -0,0 +1,100 @@
+package dots
+
+import (
+	"context"
+	"net/mail"
+	"time"
+	"unicode/utf8"
+
+	"github.com/segmentio/ksuid"
+)
+
+const PasswordMinLength = 8
+
+// PasswordMaxBytes is as much as bcrypt hashes, it refuses longer passwords
+const PasswordMaxBytes = 72
+
+type UserRegister struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Pass  string `json:"pwd"`
+}
+
+func (ur *UserRegister) Validate() error {
+	suspects := map[string]*string{
+		"name":  &ur.Name,
+		"email": &ur.Email,
+	}
+	err := printable(suspects)
+	if err != nil {
+		return err
+	}
+
+	if _, err := mail.ParseAddress(ur.Email); err != nil {
+		return Errorf(EINVALID, "email is not valid")
+	}
+
+	return validatePassword(ur.Pass)
+}
+
+type PasswordChange struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+func (pc *PasswordChange) Validate() error {
+	if pc.Old == pc.New {
+		return Errorf(EINVALID, "new password must differ from the old one")
+	}
+	return validatePassword(pc.New)
+}
+
+type PasswordForgot struct {
+	Email string `json:"email"`
+}
+
+type PasswordReset struct {
+	Token string `json:"token"`
+	Pass  string `json:"pwd"`
+}
+
+func (pr *PasswordReset) Validate() error {
+	if pr.Token == "" {
+		return Errorf(EINVALID, "reset token required")
+	}
+	return validatePassword(pr.Pass)
+}
+
+// PasswordResetToken carries the raw single-use token,
+// it is never stored, only its hash is persisted
+type PasswordResetToken struct {
+	UserID    ksuid.KSUID
+	Email     string
+	Token     string
+	ExpiresAt time.Time
+}
+
+func validatePassword(pass string) error {
+	if utf8.RuneCountInString(pass) < PasswordMinLength {
+		return Errorf(EINVALID, "password must have at least %d characters", PasswordMinLength)
+	}
+	if len(pass) > PasswordMaxBytes {
+		return Errorf(EINVALID, "password must have at most %d bytes", PasswordMaxBytes)
+	}
+	if hasNonPrintable(pass) {
+		return Errorf(EINVALID, "password is not a text line")
+	}
+	return nil
+}
+
+type PasswordService interface {
+	Register(context.Context, UserRegister) (*User, error)
+	ChangePassword(context.Context, PasswordChange) error
+	CreatePasswordReset(context.Context, string) (*PasswordResetToken, error)
+	ResetPassword(context.Context, PasswordReset) error
+}
+
+// PasswordResetSender delivers the reset token to the user (email, chat...)
+type PasswordResetSender interface {
+	SendPasswordReset(context.Context, *PasswordResetToken) error
+}
@@ -0,0 +1,77 @@
+package dots
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+// PersonalTokenPrefix tells personal access tokens apart from paseto ones
+const PersonalTokenPrefix = "dots_pat_"
+
+// PersonalToken is a named machine credential acting
+// with a subset of its owner powers
+type PersonalToken struct {
+	ID         int         `json:"id"`
+	UserID     ksuid.KSUID `json:"user_id"`
+	Name       string      `json:"name"`
+	Scopes     []Power     `json:"scopes"`
+	ExpiresAt  time.Time   `json:"expires_at"`
+	LastUsedAt *time.Time  `json:"last_used_at"`
+	CreatedAt  time.Time   `json:"created_at"`
+
+	// Token is shown only once, when created
+	Token string `json:"token,omitempty"`
+}
+
+type PersonalTokenCreate struct {
+	Name      string     `json:"name"`
+	Scopes    []Power    `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (pc *PersonalTokenCreate) Validate() error {
+	pc.Name = strings.TrimSpace(pc.Name)
+	if pc.Name == "" {
+		return Errorf(EINVALID, "token name required")
+	}
+	if err := printable(map[string]*string{"name": &pc.Name}); err != nil {
+		return err
+	}
+	if len(pc.Scopes) == 0 {
+		return Errorf(EINVALID, "at least one scope required")
+	}
+	if pc.ExpiresAt != nil && !pc.ExpiresAt.After(time.Now()) {
+		return Errorf(EINVALID, "expiry must be in the future")
+	}
+	return nil
+}
+
+// ScopePowers keeps only the powers both the user and the token have
+func ScopePowers(powers []Power, scopes []Power) []Power {
+	pp := []Power{}
+	for _, p := range powers {
+		if PowersContains(scopes, p) {
+			pp = append(pp, p)
+		}
+	}
+	return pp
+}
+
+type PersonalTokenFilter struct {
+	ID *int `json:"id"`
+
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+type PersonalTokenService interface {
+	CreatePersonalToken(context.Context, PersonalTokenCreate) (*PersonalToken, error)
+	FindPersonalToken(context.Context, PersonalTokenFilter) ([]*PersonalToken, int, error)
+	DeletePersonalToken(context.Context, int) error
+	// AuthenticatePersonalToken returns the token owner
+	// with its powers narrowed to the token scopes
+	AuthenticatePersonalToken(context.Context, string) (*User, error)
+}
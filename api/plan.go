@@ -0,0 +1,60 @@
+package dots
+
+import (
+	"context"
+	"unicode/utf8"
+)
+
+// resources a plan puts a cap on, named as the core.package columns
+const (
+	ResourceCompany   = "company"
+	ResourceDeed      = "deed"
+	ResourceDrain     = "drain"
+	ResourceEntryType = "entry_type"
+	ResourceEntry     = "entry"
+)
+
+var Resources = []string{ResourceCompany, ResourceDeed, ResourceDrain, ResourceEntryType, ResourceEntry}
+
+// Quota is the package of the organisation owner
+// with the owner restrictions laid over it
+type Quota struct {
+	Plan   string           `json:"plan"`
+	Limits map[string]int64 `json:"limits"`
+	// FieldLen caps text lengths keyed as "resource.field"
+	FieldLen map[string]int `json:"field_len"`
+}
+
+// CheckFieldLen tells which of the text fields is longer than the plan allows
+func (q *Quota) CheckFieldLen(resource string, fields map[string]*string) error {
+	for name, v := range fields {
+		if v == nil {
+			continue
+		}
+		max, ok := q.FieldLen[resource+"."+name]
+		if !ok {
+			continue
+		}
+		if n := utf8.RuneCountInString(*v); n > max {
+			return Errorf(EQUOTA, "%s %s is longer than %d characters allowed by plan %s", resource, name, max, q.Plan).
+				WithData(map[string]interface{}{"resource": resource, "field": name, "limit": max, "used": n})
+		}
+	}
+	return nil
+}
+
+type UsageItem struct {
+	Used  int64 `json:"used"`
+	Limit int64 `json:"limit"`
+}
+
+// Usage is the consumption of the active organisation against its plan
+type Usage struct {
+	Plan      string               `json:"plan"`
+	Resources map[string]UsageItem `json:"resources"`
+	FieldLen  map[string]int       `json:"field_len"`
+}
+
+type PlanService interface {
+	FindUsage(context.Context) (*Usage, error)
+}
@@ -40,9 +40,13 @@ func (s *AuthService) CreateAuth(ctx context.Context, auth *dots.Auth) error {
 	}
 	if len(others) == 0 {
 		if auth.UserID == ksuid.Nil && auth.User != nil {
-			uu, _, err := findUser(ctx, tx, dots.UserFilter{Email: &auth.User.Email, Limit: 1})
-			if err != nil {
-				return fmt.Errorf("postgres.auth: cannot find user by email %w", err)
+			// link a new source to the user already owning the email
+			uu := []*dots.User{}
+			if auth.User.Email != "" {
+				uu, _, err = findUser(ctx, tx, dots.UserFilter{Email: &auth.User.Email, Limit: 1})
+				if err != nil {
+					return fmt.Errorf("postgres.auth: cannot find user by email %w", err)
+				}
 			}
 			if len(uu) == 0 {
 				// add default powers
@@ -53,6 +57,9 @@ func (s *AuthService) CreateAuth(ctx context.Context, auth *dots.Auth) error {
 				}
 				auth.UserID = auth.User.ID
 			} else {
+				if err := linkableByEmail(ctx, tx, uu[0].ID); err != nil {
+					return err
+				}
 				auth.User = uu[0]
 				auth.UserID = auth.User.ID
 			}
@@ -277,7 +284,8 @@ func updateAuth(
 	_, err = tx.ExecContext(ctx, `
 		update core."auth"
 		set access_token = $1, refresh_token = $2, expiry = $3, updated_at = $4
-	`, auth.AccessToken, auth.RefreshToken, auth.Expiry, auth.UpdatedAt)
+		where id = $5
+	`, auth.AccessToken, auth.RefreshToken, auth.Expiry, auth.UpdatedAt, auth.ID)
 	if err != nil {
 		return auth, err
 	}
@@ -301,6 +309,28 @@ func deleteAuth(ctx context.Context, tx *Tx, id int) error {
 	return nil
 }
 
+// linkableByEmail refuses a user known only by a registered password,
+// its email was never verified so a login for it may not take over
+func linkableByEmail(ctx context.Context, tx *Tx, uid ksuid.KSUID) error {
+	cred, err := findUserCredentials(ctx, tx, dots.UserFilter{ID: &uid})
+	if err != nil {
+		return err
+	}
+	if cred.passHash == nil {
+		return nil
+	}
+
+	_, n, err := findAuth(ctx, tx, dots.AuthFilter{UserID: &uid, Limit: 1})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return dots.Errorf(dots.ECONFLICT, "email belongs to an account that never verified it")
+	}
+
+	return nil
+}
+
 func attachAuthUser(ctx context.Context, tx *Tx, a *dots.Auth) (err error) {
 	a.User, err = findUserByID(ctx, tx, a.UserID)
 	if err != nil {
@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/innermond/dots"
 )
@@ -36,6 +38,11 @@ func (s *CompanyService) CreateCompany(ctx context.Context, c *dots.Company) err
 		return err
 	}
 
+	fields := map[string]*string{"longname": &c.Longname, "tin": &c.TIN, "rn": &c.RN}
+	if err := checkQuota(ctx, tx, dots.ResourceCompany, fields); err != nil {
+		return err
+	}
+
 	if err := createCompany(ctx, tx, c); err != nil {
 		return perr(err)
 	}
@@ -82,6 +89,11 @@ func (s *CompanyService) UpdateCompany(ctx context.Context, id int, upd dots.Com
 		return nil, err
 	}
 
+	fields := map[string]*string{"longname": upd.Longname, "tin": upd.TIN, "rn": upd.RN}
+	if err := checkFieldLen(ctx, tx, dots.ResourceCompany, fields); err != nil {
+		return nil, err
+	}
+
 	c, err := updateCompany(ctx, tx, id, upd)
 	if err != nil {
 		return nil, err
@@ -132,6 +144,37 @@ func (s *CompanyService) DeleteCompany(ctx context.Context, id int, filter dots.
 	return n, err
 }
 
+func (s *CompanyService) RestoreCompany(ctx context.Context, restore dots.TrashRestore) (int, error) {
+	if err := restore.Validate(); err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanDeleteOwn(ctx); canerr != nil {
+		return 0, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return 0, err
+	}
+
+	n, err := restoreEach("company", restore.ID, func(id int) (int, error) {
+		return deleteCompany(ctx, tx, id, true)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	tx.Commit()
+
+	return n, nil
+}
+
 func (s *CompanyService) StatsCompany(ctx context.Context, filter dots.CompanyFilter) (*dots.CompanyStats, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -168,6 +211,28 @@ func (s *CompanyService) DepletionCompany(ctx context.Context, filter dots.Compa
 	return depletionCompany(ctx, tx, filter)
 }
 
+func (s *CompanyService) ForecastCompany(ctx context.Context, filter dots.ForecastFilter) ([]*dots.CompanyForecast, int, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, 0, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	return forecastCompany(ctx, tx, filter)
+}
+
 func findCompany(ctx context.Context, tx *Tx, filter dots.CompanyFilter) (_ []*dots.Company, n int, err error) {
 	where, args := []string{}, []interface{}{}
 	if v := filter.ID; v != nil {
@@ -183,23 +248,14 @@ func findCompany(ctx context.Context, tx *Tx, filter dots.CompanyFilter) (_ []*d
 		where, args = append(where, "rn = ?"), append(args, *v)
 	}
 
-	// TODO deal with isDeleted
-	/*	v := filter.IsDeleted
-		if v != nil && *v {
-			where = append(where, "deleted_at is not null")
-		} else if v != nil && !*v {
-			where = append(where, "deleted_at is null")
-		} else if v == nil {
-			where = append(where, "deleted_at is null")
-		}
-	*/
-	wherestr := ""
-	if len(where) > 0 {
-		replaceQuestionMark(where, args)
-		wherestr = "where " + strings.Join(where, " and ")
-	}
+	trash, trashArgs, state := trashWhere("deleted_at", filter.IsDeleted, filter.DeletedAtFrom, filter.DeletedAtTo)
+	where, args = append(where, trash...), append(args, trashArgs...)
+
+	replaceQuestionMark(where, args)
+	where = append(where, state)
+	wherestr := "where " + strings.Join(where, " and ")
 	sqlstr := `
-		select id, longname, tin, rn, count(*) over() from company
+		select id, longname, tin, rn, deleted_at, count(*) over() from core.company
 		` + wherestr + ` ` + formatLimitOffset(filter.Limit, filter.Offset)
 	rows, err := tx.QueryContext(
 		ctx,
@@ -217,7 +273,7 @@ func findCompany(ctx context.Context, tx *Tx, filter dots.CompanyFilter) (_ []*d
 	companies := []*dots.Company{}
 	for rows.Next() {
 		var e dots.Company
-		err := rows.Scan(&e.ID, &e.Longname, &e.TIN, &e.RN, &n)
+		err := rows.Scan(&e.ID, &e.Longname, &e.TIN, &e.RN, &e.DeletedAt, &n)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -436,8 +492,12 @@ FROM (
 	return stats, nil
 }
 
+// depletionCompany reports entry types of the companies meeting filter left below their reorder level;
+// limit and offset page the report, not the companies
 func depletionCompany(ctx context.Context, tx *Tx, filter dots.CompanyFilter) (_ []*dots.CompanyDepletion, n int, err error) {
-	cc, n, err := findCompany(ctx, tx, filter)
+	companyFilter := filter
+	companyFilter.Limit, companyFilter.Offset = 0, 0
+	cc, _, err := findCompany(ctx, tx, companyFilter)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -447,61 +507,206 @@ func depletionCompany(ctx context.Context, tx *Tx, filter dots.CompanyFilter) (_
 		cids = append(cids, c.ID)
 	}
 
-	wherestr := "where ed.company_id = any($1)"
+	asOf := time.Now()
+	if v := filter.AsOf; v != nil {
+		asOf = time.Time(*v)
+	}
 
-	sqlstr := `with er as (
+	// every company falls back to the threshold without company
+	sqlstr := `with stock as (
 	select
-		ed.id,
+		ed.company_id,
 		ed.entry_type_id,
-		et.code,
-		et.description,
-		ed.quantity_initial,
-		ed.quantity_drained,
-		(ed.quantity_initial - ed.quantity_drained) as remained
+		sum(ed.quantity_initial) quantity_initial,
+		sum(ed.quantity_drained) quantity_drained
 	from
-		api.entry_with_quantity_drained ed
-	join api.entry_type et on
-		ed.entry_type_id = et.id
-		` + wherestr + `
-  order by remained
+		(` + entriesAsOf("$2") + `) ed
+	where ed.company_id = any($1)
+	group by ed.company_id, ed.entry_type_id
+), level as (
+	select
+		c.id company_id,
+		st.entry_type_id,
+		st.reorder_level,
+		st.target_level
+	from unnest($1::integer[]) c(id)
+	join lateral (
+		select distinct on (t.entry_type_id) t.entry_type_id, t.reorder_level, t.target_level
+		from core.stock_threshold t
+		where t.company_id = c.id or t.company_id is null
+		order by t.entry_type_id, t.company_id nulls last
+	) st on true
 )
 select
-	er.entry_type_id,
-	er.code, er.description,
-	Sum(quantity_initial) quantity_initial,
-	sum(quantity_drained) quantity_drained
-from er
-where
-	er.remained > 0
-	group by er.entry_type_id, er.code, er.description
-	limit 3;`
-	fmt.Println(sqlstr, cids)
+	l.company_id,
+	l.entry_type_id,
+	et.code, et.description,
+	coalesce(s.quantity_initial, 0) quantity_initial,
+	coalesce(s.quantity_drained, 0) quantity_drained,
+	l.reorder_level,
+	l.target_level,
+	count(*) over()
+from level l
+join api.entry_type et on et.id = l.entry_type_id
+left join stock s on s.company_id = l.company_id and s.entry_type_id = l.entry_type_id
+where coalesce(s.quantity_initial - s.quantity_drained, 0) < l.reorder_level
+order by
+	coalesce(s.quantity_initial - s.quantity_drained, 0) / nullif(l.reorder_level, 0) nulls first,
+	l.company_id, l.entry_type_id
+` + formatLimitOffset(filter.Limit, filter.Offset)
+
 	rows, err := tx.QueryContext(
 		ctx,
 		sqlstr,
-		cids,
+		cids, asOf,
 	)
-	if err == sql.ErrNoRows {
-		return nil, 0, dots.Errorf(dots.ENOTFOUND, "depletion for company not found")
-	}
 	if err != nil {
 		return nil, 0, err
 	}
 	defer rows.Close()
 
 	cd := []*dots.CompanyDepletion{}
+	n = 0
 	for rows.Next() {
 		var e dots.CompanyDepletion
-		err := rows.Scan(&e.EntryTypeID, &e.Code, &e.Description, &e.QuantityInitial, &e.QuantityDrained)
+		err := rows.Scan(&e.CompanyID, &e.EntryTypeID, &e.Code, &e.Description, &e.QuantityInitial, &e.QuantityDrained, &e.ReorderLevel, &e.TargetLevel, &n)
 		if err != nil {
 			return nil, 0, err
 		}
+		e.Quantity = aprox(*e.QuantityInitial-*e.QuantityDrained, 5)
+		e.ReorderQuantity = aprox(e.TargetLevel-e.Quantity, 5)
 		cd = append(cd, &e)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, 0, err
 	}
 
-	n = len(cd)
 	return cd, n, nil
 }
+
+// forecastCompany projects the stock left of every company and entry type
+// by how much got drained daily within the window ending at as_of.
+// The ones running out sooner come first, the ones not consumed last
+func forecastCompany(ctx context.Context, tx *Tx, filter dots.ForecastFilter) ([]*dots.CompanyForecast, int, error) {
+	asOf := time.Now()
+	if v := filter.AsOf; v != nil {
+		asOf = time.Time(*v)
+	}
+	from := asOf.AddDate(0, 0, -filter.Window)
+
+	where, args := []string{}, []interface{}{}
+	if v := filter.CompanyID; v != nil {
+		where, args = append(where, "s.company_id = ?"), append(args, *v)
+	}
+	if v := filter.EntryTypeID; v != nil {
+		where, args = append(where, "s.entry_type_id = ?"), append(args, *v)
+	}
+	replaceQuestionMark(where, args)
+	where = append(where, "s.company_id = any(select id from api.company)")
+	args = append(args, asOf, from)
+	at, since := fmt.Sprintf("$%d", len(args)-1), fmt.Sprintf("$%d", len(args))
+
+	// drains within the window (since, as_of] get bucketed into days by dots.ForecastDay;
+	// transfers and count write-offs move stock without consuming it, as for profit
+	sqlstr := `with stock as (
+	select ed.company_id, ed.entry_type_id, sum(ed.quantity_initial - ed.quantity_drained) quantity
+	from (` + entriesAsOf(at) + `) ed
+	group by ed.company_id, ed.entry_type_id
+), consumed as (
+	select e.company_id, e.entry_type_id, m.moved_at drained_at, sum(m.quantity) quantity
+	from core.drain_movement m
+	join core.entry e on e.id = m.entry_id
+	where m.moved_at > ` + since + ` and m.moved_at <= ` + at + `
+	and (e.deleted_at is null or e.deleted_at > ` + at + `)
+	and not exists(select 1 from core.transfer t where t.deed_id = m.deed_id)
+	and not exists(select 1 from core.inventory_count_line l where l.deed_id = m.deed_id)
+	group by e.company_id, e.entry_type_id, m.moved_at
+), moves as (
+	select company_id, entry_type_id, null::timestamptz as drained_at, 0::double precision as quantity from stock
+	union all
+	select company_id, entry_type_id, drained_at, quantity from consumed
+)
+select s.company_id, s.entry_type_id, et.code, coalesce(st.quantity, 0), s.drained_at, s.quantity
+from moves s
+left join stock st on st.company_id = s.company_id and st.entry_type_id = s.entry_type_id
+join api.entry_type et on et.id = s.entry_type_id
+where ` + strings.Join(where, " and ") + `
+order by s.company_id, s.entry_type_id`
+
+	rows, err := tx.QueryContext(ctx, sqlstr, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	type pair struct {
+		companyID, entryTypeID int
+	}
+	forecasts := []*dots.CompanyForecast{}
+	daily := map[pair][]float64{}
+	found := map[pair]*dots.CompanyForecast{}
+	for rows.Next() {
+		var (
+			p         pair
+			code      string
+			quantity  float64
+			drainedAt *time.Time
+			drained   float64
+		)
+		if err := rows.Scan(&p.companyID, &p.entryTypeID, &code, &quantity, &drainedAt, &drained); err != nil {
+			return nil, 0, err
+		}
+
+		cf, ok := found[p]
+		if !ok {
+			cf = &dots.CompanyForecast{
+				CompanyID:   p.companyID,
+				EntryTypeID: p.entryTypeID,
+				Code:        code,
+				Method:      filter.Method,
+				AsOf:        asOf,
+				Quantity:    aprox(quantity, 5),
+			}
+			found[p] = cf
+			daily[p] = make([]float64, filter.Window)
+			forecasts = append(forecasts, cf)
+		}
+		if drainedAt == nil {
+			continue
+		}
+		if day, ok := dots.ForecastDay(asOf, *drainedAt, filter.Window); ok {
+			daily[p][day] += drained
+			cf.Consumed += drained
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	for p, cf := range found {
+		cf.Consumed = aprox(cf.Consumed, 5)
+		cf.DailyRate = aprox(dots.DailyRate(filter.Method, daily[p], *filter.Alpha), 5)
+		cf.Project()
+	}
+
+	sort.SliceStable(forecasts, func(i, j int) bool {
+		a, b := forecasts[i].DaysLeft, forecasts[j].DaysLeft
+		if a == nil || b == nil {
+			return a != nil && b == nil
+		}
+		return *a < *b
+	})
+
+	n := len(forecasts)
+	if filter.Offset > 0 {
+		if filter.Offset > len(forecasts) {
+			filter.Offset = len(forecasts)
+		}
+		forecasts = forecasts[filter.Offset:]
+	}
+	if filter.Limit > 0 && filter.Limit < len(forecasts) {
+		forecasts = forecasts[:filter.Limit]
+	}
+
+	return forecasts, n, nil
+}
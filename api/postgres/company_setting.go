@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/innermond/dots"
+)
+
+func (s *CompanyService) FindCompanySetting(ctx context.Context, id int) (*dots.CompanySetting, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := companyBelongsToUser(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	return findCompanySetting(ctx, tx, id)
+}
+
+func (s *CompanyService) UpdateCompanySetting(ctx context.Context, id int, upd dots.CompanySettingUpdate) (*dots.CompanySetting, error) {
+	if err := upd.Validate(); err != nil {
+		return nil, err
+	}
+
+	if canerr := dots.CanWriteOwn(ctx); canerr != nil {
+		return nil, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := companyBelongsToUser(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	var strategy *dots.DistributeDrain
+	if *upd.DistributeStrategy != "" {
+		strategy = upd.DistributeStrategy
+	}
+	_, err = tx.ExecContext(
+		ctx, `
+insert into core.company_setting (company_id, distribute_strategy) values ($1, $2)
+on conflict (company_id) do update set distribute_strategy = EXCLUDED.distribute_strategy`,
+		id, strategy,
+	)
+	if err != nil {
+		return nil, perr(err)
+	}
+
+	cs, err := findCompanySetting(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return cs, tx.Commit()
+}
+
+// findCompanySetting reads the settings of a company, the defaults when it made none
+func findCompanySetting(ctx context.Context, tx *Tx, id int) (*dots.CompanySetting, error) {
+	cs := &dots.CompanySetting{CompanyID: id}
+	err := tx.QueryRowContext(
+		ctx,
+		`select distribute_strategy from core.company_setting where company_id = $1`,
+		id,
+	).Scan(&cs.DistributeStrategy)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return cs, nil
+}
+
+// distributeStrategy picks the strategy asked for, or else the one of the company, or else fallback
+func distributeStrategy(ctx context.Context, tx *Tx, companyID int, asked *dots.DistributeDrain, fallback dots.DistributeDrain) (dots.DistributeDrain, error) {
+	if asked != nil {
+		return *asked, asked.Validate()
+	}
+
+	cs, err := findCompanySetting(ctx, tx, companyID)
+	if err != nil {
+		return "", err
+	}
+	if cs.DistributeStrategy == nil {
+		return fallback, nil
+	}
+
+	return *cs.DistributeStrategy, nil
+}
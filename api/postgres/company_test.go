@@ -0,0 +1,45 @@
+package postgres_test
+
+import (
+	"testing"
+
+	"github.com/innermond/dots"
+	"github.com/innermond/dots/postgres"
+)
+
+func TestCompanyService_ForecastCompany(t *testing.T) {
+	db := MustOpenDB(t, DSN)
+	defer MustCloseDB(t, db)
+
+	ctx, deleteTenant := MustCreateTenant(t, db)
+	defer deleteTenant()
+	cid, etid, eid := MustCreateStock(t, ctx, db, 10)
+
+	d := newDeed(cid, "DEED", 1, map[int]float64{eid: 3})
+	if err := postgres.NewDeedService(db).CreateDeed(ctx, &d); err != nil {
+		t.Fatal(err)
+	}
+	// a write-off lowers the stock without being consumed
+	mustApproveCount(t, ctx, db, cid, etid, 6, dots.ReasonDamage)
+
+	// as of the last move, timed by the database and not by this clock
+	ll, _, err := postgres.NewLedgerService(db).FindLedger(ctx, dots.LedgerFilter{CompanyID: &cid, EntryTypeID: &etid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	asOf := partialTime(ll[len(ll)-1].At)
+
+	ff, _, err := postgres.NewCompanyService(db).ForecastCompany(ctx, dots.ForecastFilter{CompanyID: &cid, AsOf: asOf, Window: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ff) != 1 {
+		t.Fatalf("forecasts=%+v, want 1", ff)
+	}
+	if f := ff[0]; f.EntryTypeID != etid || f.Quantity != 6 || f.Consumed != 3 || f.DailyRate != 0.3 {
+		t.Fatalf("forecast=%+v, want 6 left, 3 consumed at 0.3 a day", f)
+	}
+	if f := ff[0]; f.DaysLeft == nil || *f.DaysLeft != 20 {
+		t.Fatalf("days left=%v, want 20", f.DaysLeft)
+	}
+}
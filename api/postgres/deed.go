@@ -54,6 +54,15 @@ func (s *DeedService) CreateDeed(ctx context.Context, d *dots.Deed) error {
 		return dots.Errorf(dots.ENOTFOUND, "company not found %v", *d.CompanyID)
 	}
 
+	fields := map[string]*string{"title": d.Title, "unit": d.Unit}
+	if err := checkQuota(ctx, tx, dots.ResourceDeed, fields); err != nil {
+		return err
+	}
+
+	if err := distributeInEntryUnits(ctx, tx, &d.DeedUpdate); err != nil {
+		return err
+	}
+
 	if err := doDistribute(ctx, tx, &d.DeedUpdate); err != nil {
 		return err
 	}
@@ -97,6 +106,11 @@ func (s *DeedService) UpdateDeed(ctx context.Context, id int, upd dots.DeedUpdat
 	if upd.CompanyID == nil {
 		return nil, dots.Errorf(dots.ENOTFOUND, "company is required")
 	}
+	if v := upd.DistributeStrategy; v != nil {
+		if err := v.Validate(); err != nil {
+			return nil, err
+		}
+	}
 
 	if len(upd.Distribute) > 0 {
 		for eid, qty := range upd.Distribute {
@@ -129,6 +143,11 @@ func (s *DeedService) UpdateDeed(ctx context.Context, id int, upd dots.DeedUpdat
 		return nil, dots.Errorf(dots.ENOTFOUND, "company not found %v", *upd.CompanyID)
 	}
 
+	fields := map[string]*string{"title": upd.Title, "unit": upd.Unit}
+	if err := checkFieldLen(ctx, tx, dots.ResourceDeed, fields); err != nil {
+		return nil, err
+	}
+
 	d, err := updateDeed(ctx, tx, id, upd)
 	if err != nil {
 		return nil, err
@@ -139,6 +158,33 @@ func (s *DeedService) UpdateDeed(ctx context.Context, id int, upd dots.DeedUpdat
 	return d, nil
 }
 
+func (s *DeedService) PreviewDistribute(ctx context.Context, preview dots.DistributePreview) (*dots.DistributePreviewed, error) {
+	if err := preview.Validate(); err != nil {
+		return nil, err
+	}
+
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, canerr
+	}
+
+	// a preview never writes, it is rolled back whatever happens
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := companyBelongsToUser(ctx, tx, *preview.CompanyID); err != nil {
+		return nil, err
+	}
+
+	return previewDistribute(ctx, tx, preview)
+}
+
 func (s *DeedService) DeleteDeed(ctx context.Context, id int, filter dots.DeedDelete) (int, error) {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -163,6 +209,37 @@ func (s *DeedService) DeleteDeed(ctx context.Context, id int, filter dots.DeedDe
 	return n, err
 }
 
+func (s *DeedService) RestoreDeed(ctx context.Context, restore dots.TrashRestore) (int, error) {
+	if err := restore.Validate(); err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanDeleteOwn(ctx); canerr != nil {
+		return 0, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return 0, err
+	}
+
+	n, err := restoreEach("deed", restore.ID, func(id int) (int, error) {
+		return deleteDeed(ctx, tx, id, dots.DeedDelete{Undrain: restore.Undrain, Resurect: true})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	tx.Commit()
+
+	return n, nil
+}
+
 func createDeed(ctx context.Context, tx *Tx, d *dots.Deed) error {
 	err := tx.QueryRowContext(
 		ctx,
@@ -170,10 +247,10 @@ func createDeed(ctx context.Context, tx *Tx, d *dots.Deed) error {
 insert into deed
 (title, quantity, unit, unitprice, company_id)
 values
-($1, $2, $3, $4, $5) returning id
+($1, $2, $3, $4, $5) returning id, unit, created_at
 		`,
 		d.Title, d.Quantity, d.Unit, d.UnitPrice, d.CompanyID,
-	).Scan(&d.ID)
+	).Scan(&d.ID, &d.Unit, &d.CreatedAt)
 	if err != nil {
 		return err
 	}
@@ -207,6 +284,10 @@ values
 }
 
 func updateDeed(ctx context.Context, tx *Tx, id int, upd dots.DeedUpdate) (*dots.Deed, error) {
+	if err := deedNotOfDocument(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
 	dd, _, err := findDeed(ctx, tx, dots.DeedFilter{ID: &id, Limit: 1})
 	if err != nil {
 		return nil, err
@@ -285,6 +366,10 @@ func updateDeed(ctx context.Context, tx *Tx, id int, upd dots.DeedUpdate) (*dots
 			}
 		}
 
+		if err := distributeInEntryUnits(ctx, tx, &upd); err != nil {
+			return nil, err
+		}
+
 		if err := doDistribute(ctx, tx, &upd); err != nil {
 			return nil, err
 		}
@@ -344,20 +429,13 @@ func findDeed(ctx context.Context, tx *Tx, filter dots.DeedFilter) (_ []*dots.De
 	if v := filter.UnitPrice; v != nil {
 		where, args = append(where, "unitprice = ?"), append(args, *v)
 	}
-	/*	if v := filter.DeletedAtFrom; v != nil {
-			// >= ? is intentional
-			where, args = append(where, "deleted_at >= ?"), append(args, *v)
-		}
-		if v := filter.DeletedAtTo; v != nil {
-			// < ? is intentional
-			// avoid double counting exact midnight values
-			where, args = append(where, "deleted_at < ?"), append(args, *v)
-		}
-	*/
 	if v := filter.CompanyID; v != nil {
 		where, args = append(where, "company_id = ?"), append(args, *v)
 	}
+	trash, trashArgs, state := trashWhere("deleted_at", filter.IsDeleted, filter.DeletedAtFrom, filter.DeletedAtTo)
+	where, args = append(where, trash...), append(args, trashArgs...)
 	replaceQuestionMark(where, args)
+	where = append(where, state)
 
 	// WARN: placeholder ? is connected with position in "where"
 	// so any unrelated with position (read replacement $n)
@@ -367,7 +445,7 @@ func findDeed(ctx context.Context, tx *Tx, filter dots.DeedFilter) (_ []*dots.De
 		where = append(where, "company_id = any(select id from company)")
 	}
 
-	sqlstr := `select id, title, unit, unitprice, quantity, company_id, count(*) over() from deed
+	sqlstr := `select id, title, unit, unitprice, quantity, company_id, created_at, deleted_at, count(*) over() from core.deed
 		where `
 	sqlstr = sqlstr + strings.Join(where, " and ") + ` ` + formatLimitOffset(filter.Limit, filter.Offset)
 	rows, err := tx.QueryContext(
@@ -386,7 +464,7 @@ func findDeed(ctx context.Context, tx *Tx, filter dots.DeedFilter) (_ []*dots.De
 	deeds := []*dots.Deed{}
 	for rows.Next() {
 		var d dots.Deed
-		err := rows.Scan(&d.ID, &d.Title, &d.Unit, &d.UnitPrice, &d.Quantity, &d.CompanyID, &n)
+		err := rows.Scan(&d.ID, &d.Title, &d.Unit, &d.UnitPrice, &d.Quantity, &d.CompanyID, &d.CreatedAt, &d.DeletedAt, &n)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -400,6 +478,10 @@ func findDeed(ctx context.Context, tx *Tx, filter dots.DeedFilter) (_ []*dots.De
 }
 
 func deleteDeed(ctx context.Context, tx *Tx, id int, filter dots.DeedDelete) (n int, err error) {
+	if err := deedNotOfDocument(ctx, tx, id); err != nil {
+		return 0, err
+	}
+
 	where, args := []string{}, []interface{}{}
 	where, args = append(where, "id = ?"), append(args, id)
 
@@ -424,6 +506,13 @@ func deleteDeed(ctx context.Context, tx *Tx, id int, filter dots.DeedDelete) (n
 		return 0, fmt.Errorf("postgres.deed: cannot soft delete %w", err)
 	}
 
+	// a deleted deed holds nothing
+	if !filter.Resurect {
+		if _, err := releaseReservations(ctx, tx, id); err != nil {
+			return 0, err
+		}
+	}
+
 	if filter.Undrain {
 		err := changeDrainsOfDeed(ctx, tx, id, !filter.Resurect)
 		if err != nil {
@@ -511,6 +600,11 @@ func entriesOfCompanyAreEnough(ctx context.Context, tx *Tx, eq map[int]float64,
 		return nil, err
 	}
 
+	// free entries can still be held by reservations of their entry type
+	if err := entryTypesNotHeld(ctx, tx, eq, cid); err != nil {
+		return nil, err
+	}
+
 	return eidqty, nil
 }
 
@@ -537,9 +631,9 @@ func doDistribute(ctx context.Context, tx *Tx, upd *dots.DeedUpdate) error {
 	// try first automatic distribute
 	enoughChecked := false
 	if len(upd.EntryTypeDistribute) > 0 {
-		strategy := ""
-		if upd.DistributeStrategy != nil {
-			strategy = string(*upd.DistributeStrategy)
+		strategy, err := distributeStrategy(ctx, tx, *upd.CompanyID, upd.DistributeStrategy, dots.DistributeNewMany)
+		if err != nil {
+			return err
 		}
 
 		distribute, err := tryDistributeOverEntryType(ctx, tx, upd.EntryTypeDistribute, *upd.CompanyID, strategy)
@@ -583,3 +677,69 @@ func doDistribute(ctx context.Context, tx *Tx, upd *dots.DeedUpdate) error {
 	}
 	return nil
 }
+
+// deedNotOfDocument keeps the deeds made by transfers and inventory counts, and their drains, as they were made
+func deedNotOfDocument(ctx context.Context, tx *Tx, deedID int) error {
+	var (
+		document string
+		id       int
+	)
+	err := tx.QueryRowContext(ctx, `
+select 'transfer', id from core.transfer where deed_id = $1
+union all
+select 'inventory count', count_id from core.inventory_count_line where deed_id = $1
+limit 1`, deedID).Scan(&document, &id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return dots.Errorf(dots.ECONFLICT, "deed %d records %s %d and cannot be changed", deedID, document, id)
+}
+
+func previewDistribute(ctx context.Context, tx *Tx, preview dots.DistributePreview) (*dots.DistributePreviewed, error) {
+	cid := *preview.CompanyID
+	strategy, err := distributeStrategy(ctx, tx, cid, preview.DistributeStrategy, dots.DistributeNewMany)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &dots.DistributePreviewed{
+		Strategy:   strategy,
+		Distribute: map[int]float64{},
+		Remaining:  map[int]float64{},
+	}
+
+	needmore, err := shortfallOverEntryType(ctx, tx, preview.EntryTypeDistribute, cid, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(needmore) > 0 {
+		for etid, qty := range needmore {
+			needmore[etid] = aprox(qty, 5)
+		}
+		p.NeedMore = needmore
+		return p, nil
+	}
+
+	distribute, err := distributeOverEntryType(ctx, tx, preview.EntryTypeDistribute, cid, strategy, false)
+	if err != nil {
+		return nil, err
+	}
+
+	left, err := quantityByEntries(ctx, tx, keysOf(distribute), cid)
+	if err != nil {
+		return nil, err
+	}
+	for eid, qty := range distribute {
+		if qty <= 0 {
+			continue
+		}
+		p.Distribute[eid] = qty
+		p.Remaining[eid] = aprox(left[eid]-qty, 5)
+	}
+
+	return p, nil
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/innermond/dots"
@@ -73,10 +74,12 @@ func DistributeFrom(dd map[int]map[int]float64, etd map[int]float64) (map[int]fl
 	return calculated, nil
 }
 
-func quantityByEntryTypes(ctx context.Context, tx *Tx, etids []int, cid int) (map[int]float64, error) {
-	sqlstr := `select entry_type_id, sum(quantity_initial - quantity_drained) quantity
+// quantityByEntryTypes tells what is free to take per entry type, what reservations hold left out
+func quantityByEntryTypes(ctx context.Context, tx *Tx, etids []int, cid int, withExpired bool) (map[int]float64, error) {
+	sqlstr := `select entry_type_id, sum(quantity_initial - quantity_drained - quantity_reserved) - ` + heldByEntryType("e.entry_type_id", "$2", "") + ` quantity
 from entry_with_quantity_drained e
 where e.entry_type_id = any($1) and e.company_id = $2
+and ` + usable(withExpired) + `
 group by e.entry_type_id`
 
 	rows, err := tx.QueryContext(ctx, sqlstr, etids, cid)
@@ -107,8 +110,9 @@ group by e.entry_type_id`
 	return m, nil
 }
 
+// quantityByEntries tells what is free to take per entry, what reservations hold left out
 func quantityByEntries(ctx context.Context, tx *Tx, eids []int, cid int) (map[int]float64, error) {
-	sqlstr := `select e.id, (quantity_initial - quantity_drained) quantity
+	sqlstr := `select e.id, (quantity_initial - quantity_drained - quantity_reserved) quantity
 from entry_with_quantity_drained e
 where e.id = any($1) and e.company_id = $2
 `
@@ -141,26 +145,50 @@ where e.id = any($1) and e.company_id = $2
 	return m, nil
 }
 
-func distributeOverEntryType(ctx context.Context, tx *Tx, etqty map[int]float64, cid int, strategy string) (map[int]float64, error) {
-	switch strategy {
-	case "new_many":
-		strategy = "date_added desc, quantity desc"
-	case "new_few":
-		strategy = "date_added desc, quantity asc"
-	case "old_many":
-		strategy = "date_added asc, quantity desc"
-	case "old_few":
-		strategy = "date_added asc, quantity asc"
-	case "many_new":
-		strategy = "quantity desc, date_added desc"
-	case "few_new":
-		strategy = "quantity asc, date_added desc"
-	case "many_old":
-		strategy = "quantity desc, date_added asc"
-	case "few_old":
-		strategy = "quantity asc, date_added asc"
-	default:
-		strategy = "date_added desc, quantity desc"
+// heldByEntryType sums what live reservations hold of an entry type of a company, not tied to an entry;
+// a deed given leaves out what that deed holds itself
+func heldByEntryType(etid, cid, deedID string) string {
+	others := ""
+	if deedID != "" {
+		others = ` and r.deed_id <> ` + deedID
+	}
+	return `coalesce((
+	select sum(r.quantity) from core.reservation r
+	where r.entry_type_id = ` + etid + ` and r.company_id = ` + cid + ` and r.expires_at > now()` + others + `
+), 0)`
+}
+
+// usable keeps the entries still usable today, expired ones are left to be written off
+func usable(withExpired bool) string {
+	if withExpired {
+		return "true"
+	}
+	return "(e.expires_at is null or e.expires_at >= current_date)"
+}
+
+// distributeOrder tells how strategies order entries, quantity being what is left of them
+var distributeOrder = map[dots.DistributeDrain]string{
+	dots.DistributeNewMany:      "date_added desc, quantity desc",
+	dots.DistributeNewFew:       "date_added desc, quantity asc",
+	dots.DistributeOldMany:      "date_added asc, quantity desc",
+	dots.DistributeOldFew:       "date_added asc, quantity asc",
+	dots.DistributeManyNew:      "quantity desc, date_added desc",
+	dots.DistributeFewNew:       "quantity asc, date_added desc",
+	dots.DistributeManyOld:      "quantity desc, date_added asc",
+	dots.DistributeFewOld:       "quantity asc, date_added asc",
+	dots.DistributeFefo:         "expires_at asc nulls last, date_added asc",
+	dots.DistributeLargestFirst: "quantity desc, expires_at asc nulls last",
+	// exact fit starts from the largest entries, see exactFit
+	dots.DistributeExactFit: "quantity desc",
+}
+
+func distributeOverEntryType(ctx context.Context, tx *Tx, etqty map[int]float64, cid int, strategy dots.DistributeDrain, withExpired bool) (map[int]float64, error) {
+	if strategy == dots.DistributeExactFit {
+		return distributeExactFit(ctx, tx, etqty, cid, withExpired)
+	}
+	order, found := distributeOrder[strategy]
+	if !found {
+		return nil, strategy.Validate()
 	}
 	// check if have enough quantities?
 	var sqlb strings.Builder
@@ -180,16 +208,17 @@ wanted (etid, qty) as (
 	sqlb.WriteString(`entrysync as (
 select
 	e.*,
-	(e.quantity_initial - coalesce(quantity_drained, 0)) quantity
+	(e.quantity_initial - coalesce(quantity_drained, 0) - e.quantity_reserved) quantity
 from entry_with_quantity_drained e
 where e.entry_type_id = any($1)
-and e.company_id = $2),
+and e.company_id = $2
+and ` + usable(withExpired) + `),
 `)
 	sqlb.WriteString(`cumulative_sum as (
    select
    (select sum(qty) from wanted where etid = es.entry_type_id group by etid) wqty,
-   id, quantity, date_added, entry_type_id,
-   SUM(quantity) over (partition by entry_type_id order by ` + strategy + `, id) as running_sum
+   id, quantity, date_added, expires_at, entry_type_id,
+   SUM(quantity) over (partition by entry_type_id order by ` + order + `, id) as running_sum
 from entrysync es
 where quantity > 0
 )
@@ -237,9 +266,108 @@ where dist.subtracted_quantity >= 0;`)
 	return m, nil
 }
 
-func tryDistributeOverEntryType(ctx context.Context, tx *Tx, etqty map[int]float64, cid int, strategy string) (map[int]float64, error) {
+func tryDistributeOverEntryType(ctx context.Context, tx *Tx, etqty map[int]float64, cid int, strategy dots.DistributeDrain) (map[int]float64, error) {
+	return tryDistribute(ctx, tx, etqty, cid, strategy, false)
+}
+
+// physicalStock is what is left of an entry on the shelf, expired or held alike
+const physicalStock = "e.quantity_initial - e.quantity_drained"
+
+// writeOffOverEntryType distributes losses over the physical stock, as that is what gets counted;
+// the entries that expire first go first
+func writeOffOverEntryType(ctx context.Context, tx *Tx, etqty map[int]float64, cid int) (map[int]float64, error) {
+	rows, err := tx.QueryContext(ctx, `
+select e.id, e.entry_type_id, `+physicalStock+` quantity
+from entry_with_quantity_drained e
+where e.entry_type_id = any($1) and e.company_id = $2
+and `+physicalStock+` > 0
+order by `+distributeOrder[dots.DistributeFefo]+`, e.id`,
+		keysOf(etqty), cid,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byType := map[int][]entryRow{}
+	for rows.Next() {
+		var r entryRow
+		if err := rows.Scan(&r.eid, &r.etid, &r.qty); err != nil {
+			return nil, err
+		}
+		byType[r.etid] = append(byType[r.etid], r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(byType) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	m, needmore := map[int]float64{}, map[int]float64{}
+	for etid, wanted := range etqty {
+		fill, left := fillInOrder(byType[etid], wanted)
+		if left > 0 {
+			needmore[etid] = left
+			continue
+		}
+		for eid, qty := range fill {
+			m[eid] = qty
+		}
+	}
+	if len(needmore) > 0 {
+		return nil, dots.Errorf(dots.EINVALID, "not enough quantity").WithData(map[string]interface{}{"needmore": needmore})
+	}
+
+	return m, nil
+}
+
+// fillInOrder takes wanted out of the entries in the order they come, and tells what is left wanting
+func fillInOrder(entries []entryRow, wanted float64) (map[int]float64, float64) {
+	m := map[int]float64{}
+	for _, e := range entries {
+		if aprox(wanted, 5) <= 0 {
+			break
+		}
+		took := e.qty
+		if wanted < took {
+			took = wanted
+		}
+		m[e.eid] = aprox(took, 5)
+		wanted -= took
+	}
+
+	left := aprox(wanted, 5)
+	if left < 0 {
+		left = 0
+	}
+	return m, left
+}
+
+func tryDistribute(ctx context.Context, tx *Tx, etqty map[int]float64, cid int, strategy dots.DistributeDrain, withExpired bool) (map[int]float64, error) {
+	needmore, err := shortfallOverEntryType(ctx, tx, etqty, cid, withExpired)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(needmore) > 0 {
+		err := dots.Errorf(dots.EINVALID, "not enough quantity")
+		err.Data = map[string]interface{}{"needmore": needmore}
+		return nil, err
+	}
+
+	distribute, err := distributeOverEntryType(ctx, tx, etqty, cid, strategy, withExpired)
+	if err != nil {
+		return nil, err
+	}
+
+	return distribute, nil
+}
+
+// shortfallOverEntryType tells how much each entry type lacks to give the wanted quantities
+func shortfallOverEntryType(ctx context.Context, tx *Tx, etqty map[int]float64, cid int, withExpired bool) (map[int]float64, error) {
 	etids := keysOf(etqty)
-	etqtyExistent, err := quantityByEntryTypes(ctx, tx, etids, cid)
+	etqtyExistent, err := quantityByEntryTypes(ctx, tx, etids, cid, withExpired)
 	if err != nil {
 		return nil, err
 	}
@@ -260,16 +388,114 @@ func tryDistributeOverEntryType(ctx context.Context, tx *Tx, etqty map[int]float
 		return nil, dots.Errorf(dots.ENOTFOUND, "not found entry type %d", numNotfound).WithData(d)
 	}
 
-	if len(needmore) > 0 {
-		err := dots.Errorf(dots.EINVALID, "not enough quantity")
-		err.Data = map[string]interface{}{"needmore": needmore}
+	return needmore, nil
+}
+
+// distributeExactFit reads what is left of the entries, largest first, and fits the wanted quantities on them
+func distributeExactFit(ctx context.Context, tx *Tx, etqty map[int]float64, cid int, withExpired bool) (map[int]float64, error) {
+	rows, err := tx.QueryContext(ctx, `
+select e.id, e.entry_type_id, e.quantity_initial - e.quantity_drained - e.quantity_reserved quantity
+from entry_with_quantity_drained e
+where e.entry_type_id = any($1) and e.company_id = $2
+and `+usable(withExpired)+`
+and e.quantity_initial - e.quantity_drained - e.quantity_reserved > 0
+order by `+distributeOrder[dots.DistributeExactFit]+`, e.id`,
+		keysOf(etqty), cid,
+	)
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	distribute, err := distributeOverEntryType(ctx, tx, etqty, cid, strategy)
-	if err != nil {
+	byType := map[int][]entryRow{}
+	for rows.Next() {
+		var r entryRow
+		if err := rows.Scan(&r.eid, &r.etid, &r.qty); err != nil {
+			return nil, err
+		}
+		byType[r.etid] = append(byType[r.etid], r)
+	}
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return distribute, nil
+	m := map[int]float64{}
+	for etid, wanted := range etqty {
+		fit, err := exactFit(byType[etid], wanted)
+		if err != nil {
+			return nil, err
+		}
+		for eid, qty := range fit {
+			m[eid] = qty
+		}
+	}
+	if len(m) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return m, nil
+}
+
+// exactFit drains the fewest entries able to hold wanted. The largest ones reach it soonest;
+// the last of them, drained in part, is swapped for the smallest entry left that still covers
+// what remains so the larger ones are kept whole. Entries come largest first
+func exactFit(entries []entryRow, wanted float64) (map[int]float64, error) {
+	k, sum := 0, 0.0
+	for k < len(entries) && aprox(sum, 5) < aprox(wanted, 5) {
+		sum += entries[k].qty
+		k++
+	}
+	if aprox(sum, 5) < aprox(wanted, 5) {
+		return nil, ErrDistributeNotEnough
+	}
+	if k == 0 {
+		return map[int]float64{}, nil
+	}
+
+	last := k - 1
+	need := aprox(wanted-(sum-entries[last].qty), 5)
+	for i := len(entries) - 1; i >= k; i-- {
+		if entries[i].qty >= need {
+			last = i
+			break
+		}
+	}
+
+	m := make(map[int]float64, k)
+	for _, e := range entries[:k-1] {
+		m[e.eid] = aprox(e.qty, 5)
+	}
+	m[entries[last].eid] = need
+
+	return m, nil
+}
+
+// drainInOrder drains the distributed quantities by the deed, entry by entry in the order of their ids,
+// as that is the order they get locked in; each, when given, follows every drain
+func drainInOrder(ctx context.Context, tx *Tx, deedID int, distribute map[int]float64, each func(eid int, qty float64) error) error {
+	eids := make([]int, 0, len(distribute))
+	for eid, qty := range distribute {
+		if qty > 0 {
+			eids = append(eids, eid)
+		}
+	}
+	sort.Ints(eids)
+
+	for _, eid := range eids {
+		qty := distribute[eid]
+		if err := drainFits(ctx, tx, deedID, eid, qty); err != nil {
+			return err
+		}
+		if err := createOrUpdateDrain(ctx, tx, dots.Drain{DeedID: deedID, EntryID: eid, Quantity: qty}); err != nil {
+			return err
+		}
+		if each == nil {
+			continue
+		}
+		if err := each(eid, qty); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
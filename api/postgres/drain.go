@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -32,9 +33,13 @@ func (s *DrainService) CreateOrUpdateDrain(ctx context.Context, d dots.Drain) er
 		return canerr
 	}
 
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return err
+	}
+
 	// lock create to own
 	// need deed ID and entry ID that belong to companies of user
-	uid := dots.UserFromContext(ctx).ID
+	uid := dots.UserFromContext(ctx).TenantID()
 	err = entryBelongsToUser(ctx, tx, uid, d.EntryID)
 	if err != nil {
 		return err
@@ -43,6 +48,9 @@ func (s *DrainService) CreateOrUpdateDrain(ctx context.Context, d dots.Drain) er
 	if err != nil {
 		return err
 	}
+	if err := deedNotOfDocument(ctx, tx, d.DeedID); err != nil {
+		return err
+	}
 
 	if err := createOrUpdateDrain(ctx, tx, d); err != nil {
 		return err
@@ -68,7 +76,11 @@ func (s *DrainService) FindDrain(ctx context.Context, filter dots.DrainFilter) (
 		return nil, 0, canerr
 	}
 
-	uid := dots.UserFromContext(ctx).ID
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	uid := dots.UserFromContext(ctx).TenantID()
 	// trying to get companies for a different TID
 	if filter.TID != nil && *filter.TID != uid {
 		// will get empty results and not error
@@ -80,19 +92,279 @@ func (s *DrainService) FindDrain(ctx context.Context, filter dots.DrainFilter) (
 	return findDrain(ctx, tx, filter)
 }
 
+func (s *DrainService) UpdateDrain(ctx context.Context, deedID, entryID int, upd dots.DrainUpdate) (*dots.Drain, error) {
+	if err := upd.Validate(); err != nil {
+		return nil, err
+	}
+
+	if canerr := dots.CanWriteOwn(ctx); canerr != nil {
+		return nil, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, err
+	}
+
+	d, err := findDrainForUpdate(ctx, tx, deedID, entryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := deedNotOfDocument(ctx, tx, deedID); err != nil {
+		return nil, err
+	}
+
+	// a deleted drain takes nothing until restored
+	took := 0.0
+	if !d.IsDeleted {
+		if err := drainFits(ctx, tx, deedID, entryID, *upd.Quantity); err != nil {
+			return nil, err
+		}
+		took = *upd.Quantity - d.Quantity
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`update core.drain set quantity = $3 where deed_id = $1 and entry_id = $2`,
+		deedID, entryID, *upd.Quantity,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("postgres.drain: cannot update %w", err)
+	}
+	d.Quantity = *upd.Quantity
+
+	if err := recordDrainMovements(ctx, tx, deedID); err != nil {
+		return nil, err
+	}
+
+	if err := alertOnLowStock(ctx, tx, deedID, entryID, took); err != nil {
+		return nil, err
+	}
+
+	return d, tx.Commit()
+}
+
+func (s *DrainService) DeleteDrain(ctx context.Context, deedID, entryID int, filter dots.DrainDelete) (int, error) {
+	if canerr := dots.CanDeleteOwn(ctx); canerr != nil {
+		return 0, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return 0, err
+	}
+
+	d, err := findDrainForUpdate(ctx, tx, deedID, entryID)
+	if err != nil {
+		return 0, err
+	}
+	if err := deedNotOfDocument(ctx, tx, deedID); err != nil {
+		return 0, err
+	}
+	// already there
+	if d.IsDeleted != filter.Resurect {
+		return 0, nil
+	}
+
+	if filter.Resurect {
+		if err := drainCanBeRestored(ctx, tx, deedID, entryID); err != nil {
+			return 0, err
+		}
+		if err := drainFits(ctx, tx, deedID, entryID, d.Quantity); err != nil {
+			return 0, err
+		}
+	}
+
+	result, err := tx.ExecContext(
+		ctx,
+		`update core.drain set is_deleted = $3, deleted_at = `+drainDeletedAt("$3::boolean")+` where deed_id = $1 and entry_id = $2`,
+		deedID, entryID, !filter.Resurect,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("postgres.drain: cannot soft delete %w", err)
+	}
+
+	n64, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := recordDrainMovements(ctx, tx, deedID); err != nil {
+		return 0, err
+	}
+
+	if filter.Resurect {
+		if err := alertOnLowStock(ctx, tx, deedID, entryID, d.Quantity); err != nil {
+			return 0, err
+		}
+	}
+
+	return int(n64), tx.Commit()
+}
+
+// findDrainForUpdate locks the drain of the caller tenant
+func findDrainForUpdate(ctx context.Context, tx *Tx, deedID, entryID int) (*dots.Drain, error) {
+	d := dots.Drain{DeedID: deedID, EntryID: entryID}
+	err := tx.QueryRowContext(
+		ctx,
+		`select quantity, is_deleted from core.drain where deed_id = $1 and entry_id = $2 and tid = $3 for update`,
+		deedID, entryID, dots.UserFromContext(ctx).TenantID(),
+	).Scan(&d.Quantity, &d.IsDeleted)
+	if err == sql.ErrNoRows {
+		return nil, dots.Errorf(dots.ENOTFOUND, "drain not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// drainFits checks the entry still has qty for the deed, besides what other deeds took from it
+// or hold of it and of its entry type. The entry row stays locked so concurrent drains wait for this one
+func drainFits(ctx context.Context, tx *Tx, deedID, entryID int, qty float64) error {
+	var (
+		available float64
+		etid, cid int
+		isUsable  bool
+	)
+	err := tx.QueryRowContext(
+		ctx, `
+select e.quantity - coalesce((
+	select sum(d.quantity) from core.drain d
+	where d.entry_id = e.id and d.deed_id <> $2 and d.is_deleted = false
+), 0) - coalesce((
+	select sum(r.quantity) from core.reservation r
+	where r.entry_id = e.id and r.deed_id <> $2 and r.expires_at > now()
+), 0),
+e.entry_type_id, e.company_id, `+usable(false)+`
+from core.entry e
+where e.id = $1
+for update of e`,
+		entryID, deedID,
+	).Scan(&available, &etid, &cid, &isUsable)
+	if err == sql.ErrNoRows {
+		return dots.Errorf(dots.ENOTFOUND, "entry not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	if diff := aprox(available-qty, 5); diff < 0 {
+		return &dots.Error{
+			Code:    dots.ECONFLICT,
+			Message: "not enough entries",
+			Data:    map[string]interface{}{"needmore": map[int]float64{entryID: diff}},
+		}
+	}
+
+	// holds of an entry type are taken out of its usable entries only
+	if !isUsable {
+		return nil
+	}
+
+	var free float64
+	err = tx.QueryRowContext(
+		ctx, `
+select coalesce(sum(e.quantity_initial - coalesce((
+	select sum(d.quantity) from core.drain d
+	where d.entry_id = e.id and d.is_deleted = false and not (d.deed_id = $3 and d.entry_id = $4)
+), 0) - coalesce((
+	select sum(r.quantity) from core.reservation r
+	where r.entry_id = e.id and r.deed_id <> $3 and r.expires_at > now()
+), 0)), 0) - `+heldByEntryType("$1", "$2", "$3")+`
+from entry_with_quantity_drained e
+where e.entry_type_id = $1 and e.company_id = $2
+and `+usable(false),
+		etid, cid, deedID, entryID,
+	).Scan(&free)
+	if err != nil {
+		return err
+	}
+
+	if diff := aprox(free-qty, 5); diff < 0 {
+		return &dots.Error{
+			Code:    dots.ECONFLICT,
+			Message: "not enough quantity, reservations hold it",
+			Data:    map[string]interface{}{"needmore_entry_type": map[int]float64{etid: -diff}},
+		}
+	}
+
+	return nil
+}
+
+// drainCanBeRestored refuses to bring back drains of deleted deeds or entries
+func drainCanBeRestored(ctx context.Context, tx *Tx, deedID, entryID int) error {
+	var alive bool
+	err := tx.QueryRowContext(
+		ctx, `
+select exists(select 1 from core.deed where id = $1 and deleted_at is null)
+and exists(select 1 from core.entry where id = $2 and deleted_at is null)`,
+		deedID, entryID,
+	).Scan(&alive)
+	if err != nil {
+		return err
+	}
+	if !alive {
+		return dots.Errorf(dots.ECONFLICT, "deed or entry of the drain is deleted")
+	}
+
+	return nil
+}
+
 func createOrUpdateDrain(ctx context.Context, tx *Tx, d dots.Drain) error {
 	if err := d.Validate(); err != nil {
 		return err
 	}
 
+	if d.Unit != nil {
+		qty, err := toEntryUnit(ctx, tx, d.Unit, d.EntryID, d.Quantity)
+		if err != nil {
+			return err
+		}
+		d.Quantity, d.Unit = qty, nil
+	}
+
+	// updating an existing drain takes no room
+	var (
+		prev      float64
+		isDeleted bool
+	)
+	err := tx.QueryRowContext(
+		ctx,
+		`select quantity, is_deleted from core.drain where deed_id = $1 and entry_id = $2`,
+		d.DeedID, d.EntryID,
+	).Scan(&prev, &isDeleted)
+	if err == sql.ErrNoRows {
+		if err := checkQuota(ctx, tx, dots.ResourceDrain, nil); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	if isDeleted {
+		prev = 0
+	}
+
 	sqlstr := `
-insert into core.drain
-(deed_id, entry_id, quantity, is_deleted)
+insert into core.drain as drain
+(deed_id, entry_id, quantity, is_deleted, deleted_at)
 values
-($1, $2, $3, $4)
-on conflict (deed_id, entry_id) do update set deed_id = EXCLUDED.deed_id, entry_id = EXCLUDED.entry_id, quantity = EXCLUDED.quantity, is_deleted = EXCLUDED.is_deleted
+($1, $2, $3, $4, case when $4::boolean then now() end)
+on conflict (deed_id, entry_id) do update set deed_id = EXCLUDED.deed_id, entry_id = EXCLUDED.entry_id, quantity = EXCLUDED.quantity, is_deleted = EXCLUDED.is_deleted,
+deleted_at = ` + drainDeletedAt("EXCLUDED.is_deleted") + `
 		`
-	_, err := tx.ExecContext(
+	_, err = tx.ExecContext(
 		ctx,
 		sqlstr,
 		d.DeedID, d.EntryID, d.Quantity, d.IsDeleted,
@@ -102,6 +374,51 @@ on conflict (deed_id, entry_id) do update set deed_id = EXCLUDED.deed_id, entry_
 		return err
 	}
 
+	if err := recordDrainMovements(ctx, tx, d.DeedID); err != nil {
+		return err
+	}
+
+	took := d.Quantity
+	if d.IsDeleted {
+		took = 0
+	}
+
+	return alertOnLowStock(ctx, tx, d.DeedID, d.EntryID, took-prev)
+}
+
+// drainDeletedAt keeps when a drain got deleted, the trash lists it by that;
+// it is not truncated as drained_at is not either
+func drainDeletedAt(deleted string) string {
+	return "case when " + deleted + " then coalesce(drain.deleted_at, now()) end"
+}
+
+// recordDrainMovements appends what changed in the drains of a deed since last recorded.
+// Drains get overwritten in place, their movements are what stock history is read from
+func recordDrainMovements(ctx context.Context, tx *Tx, deedID int) error {
+	_, err := tx.ExecContext(
+		ctx, `
+insert into core.drain_movement (deed_id, entry_id, quantity, tid)
+select x.deed_id, x.entry_id, x.quantity, x.tid
+from (
+	select
+		coalesce(d.deed_id, m.deed_id) deed_id, coalesce(d.entry_id, m.entry_id) entry_id,
+		coalesce(case when d.is_deleted then 0 else d.quantity end, 0) - coalesce(m.quantity, 0) quantity,
+		coalesce(d.tid, m.tid) tid
+	from (select * from core.drain where deed_id = $1) d
+	full join (
+		select deed_id, entry_id, tid, sum(quantity) quantity
+		from core.drain_movement
+		where deed_id = $1
+		group by deed_id, entry_id, tid
+	) m on m.deed_id = d.deed_id and m.entry_id = d.entry_id
+) x
+where abs(x.quantity) > 1e-9`,
+		deedID,
+	)
+	if err != nil {
+		return fmt.Errorf("postgres.drain: cannot record movements %w", err)
+	}
+
 	return nil
 }
 
@@ -112,28 +429,27 @@ func deleteDrainsOfDeed(ctx context.Context, tx *Tx, id int) error {
 func changeDrainsOfDeed(ctx context.Context, tx *Tx, id int, del bool) error {
 	_, err := tx.ExecContext(
 		ctx,
-		"update core.drain set is_deleted = $2 where deed_id = $1",
+		"update core.drain set is_deleted = $2, deleted_at = "+drainDeletedAt("$2::boolean")+" where deed_id = $1",
 		id, del,
 	)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return recordDrainMovements(ctx, tx, id)
 }
 
 func undrainDrainsOfDeed(ctx context.Context, tx *Tx, id int) error {
 	_, err := tx.ExecContext(
 		ctx,
-		"update core.drain set is_deleted = not is_deleted where deed_id = $1",
+		"update core.drain set is_deleted = not is_deleted, deleted_at = "+drainDeletedAt("not is_deleted")+" where deed_id = $1",
 		id,
 	)
 	if err != nil {
 		return err
 	}
 
-	return nil
-
+	return recordDrainMovements(ctx, tx, id)
 }
 
 func hardDeleteDrainsOfDeed(ctx context.Context, tx *Tx, did int) error {
@@ -144,7 +460,7 @@ func hardDeleteDrainsOfDeed(ctx context.Context, tx *Tx, did int) error {
 		return err
 	}
 
-	return nil
+	return recordDrainMovements(ctx, tx, did)
 }
 
 func hardDeleteDrainsOfDeedAlreadyDeleted(ctx context.Context, tx *Tx, did int) error {
@@ -155,7 +471,7 @@ func hardDeleteDrainsOfDeedAlreadyDeleted(ctx context.Context, tx *Tx, did int)
 		return err
 	}
 
-	return nil
+	return recordDrainMovements(ctx, tx, did)
 }
 
 func hardDeleteDrainsOfDeedPrevCompany(ctx context.Context, tx *Tx, did, cid int) error {
@@ -166,30 +482,44 @@ func hardDeleteDrainsOfDeedPrevCompany(ctx context.Context, tx *Tx, did, cid int
 		return err
 	}
 
-	return nil
+	return recordDrainMovements(ctx, tx, did)
 }
 
 func findDrain(ctx context.Context, tx *Tx, filter dots.DrainFilter) (_ []*dots.Drain, n int, err error) {
 	where, args := []string{}, []interface{}{}
 	if v := filter.DeedID; v != nil {
-		where, args = append(where, "deed_id = ?"), append(args, *v)
+		where, args = append(where, "d.deed_id = ?"), append(args, *v)
 	}
 	if v := filter.EntryID; v != nil {
-		where, args = append(where, "entry_id = ?"), append(args, *v)
+		where, args = append(where, "d.entry_id = ?"), append(args, *v)
+	}
+	if v := filter.EntryTypeID; v != nil {
+		where, args = append(where, "e.entry_type_id = ?"), append(args, *v)
+	}
+	if v := filter.CompanyID; v != nil {
+		where, args = append(where, "e.company_id = ?"), append(args, *v)
+	}
+	if v := filter.Quantity; v != nil {
+		where, args = append(where, "d.quantity = ?"), append(args, *v)
+	}
+	if v := filter.TID; v != nil {
+		where, args = append(where, "d.tid = ?"), append(args, *v)
 	}
 
 	replaceQuestionMark(where, args)
 
 	v := filter.IsDeleted
 	if v != nil {
-		where = append(where, "is_deleted = "+strconv.FormatBool(*filter.IsDeleted))
+		where = append(where, "d.is_deleted = "+strconv.FormatBool(*filter.IsDeleted))
 	} else {
-		where = append(where, "is_deleted = false")
+		where = append(where, "d.is_deleted = false")
 	}
 
 	sqlstr := `
-		select d.deed_id, d.entry_id, d.quantity, d.is_deleted, count(*) over() from core.drain d
-		where ` + strings.Join(where, " and ") + ` ` + formatLimitOffset(filter.Limit, filter.Offset)
+		select d.deed_id, d.entry_id, d.quantity, d.is_deleted, d.drained_at, d.deleted_at, count(*) over() from core.drain d
+		join core.entry e on e.id = d.entry_id
+		where ` + strings.Join(where, " and ") + `
+		order by d.deed_id, d.entry_id ` + formatLimitOffset(filter.Limit, filter.Offset)
 
 	rows, err := tx.QueryContext(
 		ctx,
@@ -208,7 +538,7 @@ func findDrain(ctx context.Context, tx *Tx, filter dots.DrainFilter) (_ []*dots.
 	drains := []*dots.Drain{}
 	for rows.Next() {
 		var e dots.Drain
-		err := rows.Scan(&e.DeedID, &e.EntryID, &e.Quantity, &e.IsDeleted, &n)
+		err := rows.Scan(&e.DeedID, &e.EntryID, &e.Quantity, &e.IsDeleted, &e.DrainedAt, &e.DeletedAt, &n)
 		if err != nil {
 			return nil, 0, err
 		}
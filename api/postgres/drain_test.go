@@ -0,0 +1,50 @@
+package postgres_test
+
+import (
+	"testing"
+
+	"github.com/innermond/dots"
+	"github.com/innermond/dots/postgres"
+)
+
+func TestDrainService_UpdateDrain(t *testing.T) {
+	t.Run("ErrEntryTypeHeld", func(t *testing.T) {
+		db := MustOpenDB(t, DSN)
+		defer MustCloseDB(t, db)
+
+		ctx, deleteTenant := MustCreateTenant(t, db)
+		defer deleteTenant()
+		cid, etid, eid := MustCreateStock(t, ctx, db, 10)
+
+		d := newDeed(cid, "DEED", 1, map[int]float64{eid: 2})
+		if err := postgres.NewDeedService(db).CreateDeed(ctx, &d); err != nil {
+			t.Fatal(err)
+		}
+		// another deed holds the rest of the entry type, not the entry itself
+		MustCreateReservation(t, ctx, postgres.NewReservationService(db), MustCreateDeed(t, ctx, db, cid), etid, 8, nil)
+
+		s := postgres.NewDrainService(db)
+		qty := 4.0
+		if _, err := s.UpdateDrain(ctx, *d.ID, eid, dots.DrainUpdate{Quantity: &qty}); dots.ErrorCode(err) != dots.ECONFLICT {
+			t.Fatalf("err=%v, want %s", err, dots.ECONFLICT)
+		}
+
+		qty = 1
+		drain, err := s.UpdateDrain(ctx, *d.ID, eid, dots.DrainUpdate{Quantity: &qty})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if drain.Quantity != 1 {
+			t.Fatalf("drain=%+v, want 1", drain)
+		}
+
+		// what was given back is free to take again, but no more
+		qty = 2
+		if _, err := s.UpdateDrain(ctx, *d.ID, eid, dots.DrainUpdate{Quantity: &qty}); err != nil {
+			t.Fatal(err)
+		}
+		if got := MustFindStock(t, ctx, db, cid, etid); got != 8 {
+			t.Fatalf("stock=%v, want 8", got)
+		}
+	})
+}
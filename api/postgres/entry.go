@@ -40,6 +40,10 @@ func (s *EntryService) CreateEntry(ctx context.Context, e *dots.Entry) error {
 		return err
 	}
 
+	if err := checkQuota(ctx, tx, dots.ResourceEntry, nil); err != nil {
+		return err
+	}
+
 	if err := createEntry(ctx, tx, e); err != nil {
 		err = fmt.Errorf("create entry: %w", err)
 		return perr(err)
@@ -130,6 +134,77 @@ func (s *EntryService) DeleteEntry(ctx context.Context, id int, filter dots.Entr
 	return n, err
 }
 
+func (s *EntryService) RestoreEntry(ctx context.Context, restore dots.TrashRestore) (int, error) {
+	if err := restore.Validate(); err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanDeleteOwn(ctx); canerr != nil {
+		return 0, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return 0, err
+	}
+
+	n, err := restoreEach("entry", restore.ID, func(id int) (int, error) {
+		return deleteEntry(ctx, tx, id, true)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	tx.Commit()
+
+	return n, nil
+}
+
+func (s *EntryService) FindStock(ctx context.Context, filter dots.StockFilter) ([]*dots.StockLevel, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, 0, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	return findStock(ctx, tx, filter)
+}
+
+func (s *EntryService) FindExpiring(ctx context.Context, filter dots.ExpiringFilter) ([]*dots.ExpiringStock, int, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, 0, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	return findExpiring(ctx, tx, filter)
+}
+
 func createEntry(ctx context.Context, tx *Tx, e *dots.Entry) error {
 	// fk checks only the remove row's existence in table
 	// check if remote rows has not been deleted (enforced by the view)
@@ -142,8 +217,8 @@ with data_entry as (
      (select id is not null from entry_type where id = $1)) as ok
 )`
 	sqlstr := check + `
-insert into entry (entry_type_id, quantity, company_id, date_added)
-select $1, $2, $3, date_trunc('minute', now())::timestamptz from data_entry
+insert into entry (entry_type_id, quantity, company_id, unitcost, lot, expires_at, date_added)
+select $1, $2, $3, $4, $5, $6, date_trunc('minute', now())::timestamptz from data_entry
 where data_entry.ok = true -- apply check here
 returning id, date_added;
 		`
@@ -155,7 +230,7 @@ returning id, date_added;
 	err := tx.QueryRowContext(
 		ctx,
 		sqlstr,
-		e.EntryTypeID, e.Quantity, e.CompanyID,
+		e.EntryTypeID, e.Quantity, e.CompanyID, e.UnitCost, e.Lot, e.ExpiresAt,
 	).Scan(&id, &date_added)
 	if err != nil {
 		// no rows are returned when insertion fail due to check
@@ -197,6 +272,27 @@ func updateEntry(ctx context.Context, tx *Tx, id int, updata dots.EntryUpdate) (
 		e.Quantity = v
 		set, args = append(set, "quantity = ?"), append(args, *v)
 	}
+	if v := updata.UnitCost; v != nil {
+		if v.IsNegative() {
+			return nil, dots.Errorf(dots.EINVALID, "unit cost cannot be negative")
+		}
+		e.UnitCost = v
+		set, args = append(set, "unitcost = ?"), append(args, *v)
+	}
+	if v := updata.Lot; v != nil {
+		// a blank lot removes it
+		lot := strings.TrimSpace(*v)
+		if lot == "" {
+			e.Lot = nil
+		} else {
+			e.Lot = &lot
+		}
+		set, args = append(set, "lot = ?"), append(args, e.Lot)
+	}
+	if v := updata.ExpiresAt; v != nil {
+		e.ExpiresAt = v
+		set, args = append(set, "expires_at = ?"), append(args, *v)
+	}
 	if v := updata.CompanyID; v != nil {
 		e.CompanyID = v
 		set, args = append(set, "company_id = ?"), append(args, *v)
@@ -251,33 +347,18 @@ func findEntry(ctx context.Context, tx *Tx, filter dots.EntryFilter) (_ []*dots.
 	if v := filter.CompanyID; v != nil {
 		where, args = append(where, "company_id = ?"), append(args, *v)
 	}
-
-	// TODO deal with isDeleted
-	/*if filter.IsDeleted {
-		if v := filter.DeletedAtFrom; v != nil {
-			// >= ? is intentional
-			where, args = append(where, "deleted_at >= ?"), append(args, *v)
-		}
-		if v := filter.DeletedAtTo; v != nil {
-			// < ? is intentional
-			// avoid double counting exact midnight values
-			where, args = append(where, "deleted_at < ?"), append(args, *v)
-		}
-	}*/
-
-	wherestr := ""
-	if len(where) > 0 {
-		replaceQuestionMark(where, args)
-		wherestr = "where " + strings.Join(where, " and ")
+	if v := filter.Lot; v != nil {
+		where, args = append(where, "lot = ?"), append(args, *v)
 	}
 
-	/*if !filter.IsDeleted {
-		where = append(where, "deleted_at is null")
-	} else if filter.DeletedAtTo == nil && filter.DeletedAtFrom == nil {
-		where = append(where, "deleted_at is not null")
-	}*/
+	trash, trashArgs, state := trashWhere("deleted_at", filter.IsDeleted, filter.DeletedAtFrom, filter.DeletedAtTo)
+	where, args = append(where, trash...), append(args, trashArgs...)
 
-	sqlstr := "select id, entry_type_id, date_added, quantity, company_id, count(*) over() from entry " + wherestr + ` ` + formatLimitOffset(filter.Limit, filter.Offset)
+	replaceQuestionMark(where, args)
+	where = append(where, state)
+	wherestr := "where " + strings.Join(where, " and ")
+
+	sqlstr := "select id, entry_type_id, date_added, quantity, company_id, unitcost, lot, expires_at, deleted_at, count(*) over() from core.entry " + wherestr + ` ` + formatLimitOffset(filter.Limit, filter.Offset)
 	rows, err := tx.QueryContext(
 		ctx,
 		sqlstr,
@@ -295,7 +376,7 @@ func findEntry(ctx context.Context, tx *Tx, filter dots.EntryFilter) (_ []*dots.
 	ee := []*dots.Entry{}
 	for rows.Next() {
 		var e dots.Entry
-		err := rows.Scan(&e.ID, &e.EntryTypeID, &e.DateAdded, &e.Quantity, &e.CompanyID, &n)
+		err := rows.Scan(&e.ID, &e.EntryTypeID, &e.DateAdded, &e.Quantity, &e.CompanyID, &e.UnitCost, &e.Lot, &e.ExpiresAt, &e.DeletedAt, &n)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -481,3 +562,123 @@ where e.company_id = any(select id from company c)
 
 	return nil
 }
+
+// entriesAsOf gives entries as they stood at the time bound to placeholder p,
+// with what deeds had drained from them by then as drain movements tell.
+// Rows deleted after that time still count
+func entriesAsOf(p string) string {
+	return `select e.id, e.entry_type_id, e.company_id, e.quantity quantity_initial,
+	coalesce((
+		select sum(m.quantity) from core.drain_movement m
+		where m.entry_id = e.id and m.moved_at <= ` + p + `
+	), 0) quantity_drained
+from core.entry e
+where coalesce(e.date_added, now()) <= ` + p + `
+and (e.deleted_at is null or e.deleted_at > ` + p + `)`
+}
+
+func findStock(ctx context.Context, tx *Tx, filter dots.StockFilter) (_ []*dots.StockLevel, n int, err error) {
+	asOf := time.Now()
+	if v := filter.AsOf; v != nil {
+		asOf = time.Time(*v)
+	}
+
+	where, args := []string{}, []interface{}{}
+	if v := filter.CompanyID; v != nil {
+		where, args = append(where, "s.company_id = ?"), append(args, *v)
+	}
+	if v := filter.EntryTypeID; v != nil {
+		where, args = append(where, "s.entry_type_id = ?"), append(args, *v)
+	}
+
+	wherestr := ""
+	if len(where) > 0 {
+		replaceQuestionMark(where, args)
+		wherestr = "where " + strings.Join(where, " and ")
+	}
+	// as of goes last, after the filters
+	args = append(args, asOf)
+
+	sqlstr := `
+select s.company_id, s.entry_type_id, sum(s.quantity_initial), sum(s.quantity_drained), count(*) over()
+from (` + entriesAsOf(fmt.Sprintf("$%d", len(args))) + `) s
+` + wherestr + `
+group by s.company_id, s.entry_type_id
+order by s.company_id, s.entry_type_id
+` + formatLimitOffset(filter.Limit, filter.Offset)
+
+	rows, err := tx.QueryContext(ctx, sqlstr, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	levels := []*dots.StockLevel{}
+	for rows.Next() {
+		l := dots.StockLevel{AsOf: asOf}
+		err := rows.Scan(&l.CompanyID, &l.EntryTypeID, &l.QuantityInitial, &l.QuantityDrained, &n)
+		if err != nil {
+			return nil, 0, err
+		}
+		l.Quantity = aprox(l.QuantityInitial-l.QuantityDrained, 5)
+		levels = append(levels, &l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return levels, n, nil
+}
+
+func findExpiring(ctx context.Context, tx *Tx, filter dots.ExpiringFilter) (_ []*dots.ExpiringStock, n int, err error) {
+	where, args := []string{}, []interface{}{}
+	if v := filter.CompanyID; v != nil {
+		where, args = append(where, "e.company_id = ?"), append(args, *v)
+	}
+	if v := filter.EntryTypeID; v != nil {
+		where, args = append(where, "e.entry_type_id = ?"), append(args, *v)
+	}
+
+	wherestr := ""
+	if len(where) > 0 {
+		replaceQuestionMark(where, args)
+		wherestr = "and " + strings.Join(where, " and ")
+	}
+	// days go last, after the filters
+	args = append(args, *filter.Days)
+
+	sqlstr := `
+select
+	e.company_id, e.entry_type_id, e.id, e.lot, e.expires_at,
+	e.quantity_initial - e.quantity_drained, e.expires_at - current_date,
+	count(*) over()
+from api.entry_with_quantity_drained e
+where e.expires_at is not null
+and e.expires_at <= current_date + ` + fmt.Sprintf("$%d::int", len(args)) + `
+and e.quantity_initial - e.quantity_drained > 0
+` + wherestr + `
+order by e.company_id, e.expires_at, e.entry_type_id, e.id
+` + formatLimitOffset(filter.Limit, filter.Offset)
+
+	rows, err := tx.QueryContext(ctx, sqlstr, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	ee := []*dots.ExpiringStock{}
+	for rows.Next() {
+		var e dots.ExpiringStock
+		err := rows.Scan(&e.CompanyID, &e.EntryTypeID, &e.EntryID, &e.Lot, &e.ExpiresAt, &e.Quantity, &e.DaysLeft, &n)
+		if err != nil {
+			return nil, 0, err
+		}
+		e.Quantity = aprox(e.Quantity, 5)
+		ee = append(ee, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return ee, n, nil
+}
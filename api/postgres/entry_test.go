@@ -58,9 +58,9 @@ func TestDeed_Create(t *testing.T) {
 
 	distribute := map[int]float64{}
 	for _, e := range entries {
-		distribute[e.ID] = e.Quantity * 0.02
+		distribute[*e.ID] = *e.Quantity * 0.02
 	}
-	deed := dots.Deed{0, cid, "Test deed title", 111, "buc", decimal.NewFromFloat(10.5), distribute, nil, nil}
+	deed := newDeed(cid, "Test deed title", 111, distribute)
 	err = deedService.CreateDeed(ctx, &deed)
 	if err != nil {
 		t.Fatalf("unexpected: %v\n", err)
@@ -68,7 +68,7 @@ func TestDeed_Create(t *testing.T) {
 
 	drainService := postgres.NewDrainService(db)
 
-	drains, _, err := drainService.FindDrain(ctx, dots.DrainFilter{DeedID: &deed.ID})
+	drains, _, err := drainService.FindDrain(ctx, dots.DrainFilter{DeedID: deed.ID})
 	if err != nil {
 		t.Fatalf("unexpected: %v\n", err)
 	}
@@ -143,7 +143,7 @@ func TestDeed_Manage(t *testing.T) {
 		if err != nil {
 			t.Fatalf("parsing entry type company id: %v\n", err)
 		}
-		e := dots.Entry{0, etid, time.Time{}, qty, cid}
+		e := dots.Entry{EntryTypeID: &etid, Quantity: &qty, CompanyID: &cid}
 
 		t.Run(fmt.Sprintf("%d:", i), func(t *testing.T) {
 			err := entryService.CreateEntry(ctx, &e)
@@ -161,7 +161,7 @@ func TestDeed_Manage(t *testing.T) {
 
 	for i, e := range entries {
 		t.Run(fmt.Sprintf("%d:", i), func(t *testing.T) {
-			_, err := entryService.DeleteEntry(ctx, e.ID, dots.EntryDelete{})
+			_, err := entryService.DeleteEntry(ctx, *e.ID, dots.EntryDelete{})
 			if err != nil {
 				t.Fatalf("unexpected: %v\n", err)
 			}
@@ -174,7 +174,7 @@ func TestDeed_Manage(t *testing.T) {
 
 	for i, e := range entries {
 		t.Run(fmt.Sprintf("%d:", i), func(t *testing.T) {
-			_, err := entryService.DeleteEntry(ctx, e.ID, dots.EntryDelete{Resurect: true})
+			_, err := entryService.DeleteEntry(ctx, *e.ID, dots.EntryDelete{Resurect: true})
 			if err != nil {
 				t.Fatalf("unexpected: %v\n", err)
 			}
@@ -190,7 +190,7 @@ func TestDeed_Manage(t *testing.T) {
 	upd := dots.EntryUpdate{Quantity: &qty, CompanyID: &cid}
 	for i, e := range entries {
 		t.Run(fmt.Sprintf("%d:", i), func(t *testing.T) {
-			entry, err := entryService.UpdateEntry(ctx, e.ID, upd)
+			entry, err := entryService.UpdateEntry(ctx, *e.ID, upd)
 			if err != nil {
 				t.Fatalf("unexpected: %v\n", err)
 			}
@@ -208,20 +208,20 @@ func TestDeed_Manage(t *testing.T) {
 
 	distribute := map[int]float64{}
 	for _, e := range entries {
-		distribute[e.ID] = e.Quantity * 0.01
+		distribute[*e.ID] = *e.Quantity * 0.01
 	}
-	deed := dots.Deed{0, cid, "Test title", 100, "buc", decimal.NewFromFloat(10.5), distribute, nil, nil}
+	deed := newDeed(cid, "Test title", 100, distribute)
 	err = deedService.CreateDeed(ctx, &deed)
 	if err != nil {
 		t.Fatalf("unexpected: %v\n", err)
 	}
 
-	_, err = deedService.DeleteDeed(ctx, deed.ID, dots.DeedDelete{})
+	_, err = deedService.DeleteDeed(ctx, *deed.ID, dots.DeedDelete{})
 	if err != nil {
 		t.Fatalf("unexpected: %v\n", err)
 	}
 
-	_, n, err := deedService.FindDeed(ctx, dots.DeedFilter{ID: &deed.ID})
+	_, n, err := deedService.FindDeed(ctx, dots.DeedFilter{ID: deed.ID})
 	if err != nil {
 		t.Fatalf("unexpected: %v\n", err)
 	}
@@ -229,12 +229,12 @@ func TestDeed_Manage(t *testing.T) {
 		t.Fatalf("unexpected length %v\n", n)
 	}
 
-	_, err = deedService.DeleteDeed(ctx, deed.ID, dots.DeedDelete{Resurect: true})
+	_, err = deedService.DeleteDeed(ctx, *deed.ID, dots.DeedDelete{Resurect: true})
 	if err != nil {
 		t.Fatalf("unexpected: %v\n", err)
 	}
 
-	_, n, err = deedService.FindDeed(ctx, dots.DeedFilter{ID: &deed.ID})
+	_, n, err = deedService.FindDeed(ctx, dots.DeedFilter{ID: deed.ID})
 	if err != nil {
 		t.Fatalf("unexpected: %v\n", err)
 	}
@@ -242,23 +242,35 @@ func TestDeed_Manage(t *testing.T) {
 		t.Fatalf("unexpected length %v\n", n)
 	}
 
-	_, err = deedService.DeleteDeed(ctx, deed.ID, dots.DeedDelete{Undrain: true})
+	_, err = deedService.DeleteDeed(ctx, *deed.ID, dots.DeedDelete{Undrain: true})
 	if err != nil {
 		t.Fatalf("unexpected: %v\n", err)
 	}
 
-	_, err = deedService.DeleteDeed(ctx, deed.ID, dots.DeedDelete{Resurect: true, Undrain: true})
+	_, err = deedService.DeleteDeed(ctx, *deed.ID, dots.DeedDelete{Resurect: true, Undrain: true})
 	if err != nil {
 		t.Fatalf("unexpected: %v\n", err)
 	}
 
-	_, err = deedService.DeleteDeed(ctx, deed.ID, dots.DeedDelete{Undrain: false})
+	_, err = deedService.DeleteDeed(ctx, *deed.ID, dots.DeedDelete{Undrain: false})
 	if err != nil {
 		t.Fatalf("unexpected: %v\n", err)
 	}
 
 }
 
+func newDeed(cid int, title string, qty float64, distribute map[int]float64) dots.Deed {
+	unit, price := "buc", decimal.NewFromFloat(10.5)
+	return dots.Deed{DeedUpdate: dots.DeedUpdate{
+		CompanyID:  &cid,
+		Title:      &title,
+		Quantity:   &qty,
+		Unit:       &unit,
+		UnitPrice:  &price,
+		Distribute: distribute,
+	}}
+}
+
 func chooseRandomInt(ii []int) int {
 	rand.Seed(time.Now().UnixNano())
 	l := len(ii)
@@ -296,3 +308,93 @@ func newContext(t *testing.T) context.Context {
 
 	return ctx
 }
+
+func TestEntryService_FindStock(t *testing.T) {
+	t.Run("AsOf", func(t *testing.T) {
+		db := MustOpenDB(t, DSN)
+		defer MustCloseDB(t, db)
+
+		ctx, deleteTenant := MustCreateTenant(t, db)
+		defer deleteTenant()
+		cid, etid, eid := MustCreateStock(t, ctx, db, 10)
+
+		d := newDeed(cid, "DEED", 1, map[int]float64{eid: 3})
+		if err := postgres.NewDeedService(db).CreateDeed(ctx, &d); err != nil {
+			t.Fatal(err)
+		}
+		// the drain is timed by the database, not by this clock
+		ll, _, err := postgres.NewLedgerService(db).FindLedger(ctx, dots.LedgerFilter{CompanyID: &cid, EntryTypeID: &etid})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ll) != 2 {
+			t.Fatalf("ledger=%+v, want an entry and a drain", ll)
+		}
+		drainedAt := ll[1].At
+
+		qty := 5.0
+		if _, err := postgres.NewDrainService(db).UpdateDrain(ctx, *d.ID, eid, dots.DrainUpdate{Quantity: &qty}); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name string
+			asOf *dots.PartialTime
+			want float64
+		}{
+			{"before the drain", partialTime(drainedAt.Add(-time.Microsecond)), 10},
+			{"before the edit", partialTime(drainedAt), 7},
+			{"now", nil, 5},
+		}
+		for _, tt := range tests {
+			ss, _, err := postgres.NewEntryService(db).FindStock(ctx, dots.StockFilter{CompanyID: &cid, EntryTypeID: &etid, AsOf: tt.asOf})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ss) != 1 || ss[0].Quantity != tt.want {
+				t.Fatalf("%s: stock=%+v, want %v", tt.name, ss, tt.want)
+			}
+		}
+	})
+}
+
+func partialTime(t time.Time) *dots.PartialTime {
+	pt := dots.PartialTime(t)
+	return &pt
+}
+
+func TestEntryService_FindExpiring(t *testing.T) {
+	db := MustOpenDB(t, DSN)
+	defer MustCloseDB(t, db)
+
+	ctx, deleteTenant := MustCreateTenant(t, db)
+	defer deleteTenant()
+	cid, etid, _ := MustCreateStock(t, ctx, db, 10)
+
+	s := postgres.NewEntryService(db)
+	eids := []int{}
+	for _, days := range []int{10, 60} {
+		qty := 5.0
+		e := &dots.Entry{EntryTypeID: &etid, Quantity: &qty, CompanyID: &cid, ExpiresAt: partialTime(time.Now().AddDate(0, 0, days))}
+		if err := s.CreateEntry(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+		eids = append(eids, *e.ID)
+	}
+	d := newDeed(cid, "DEED", 1, map[int]float64{eids[0]: 2})
+	if err := postgres.NewDeedService(db).CreateDeed(ctx, &d); err != nil {
+		t.Fatal(err)
+	}
+
+	// entries without expiry and those beyond the days asked for are left out
+	ee, n, err := s.FindExpiring(ctx, dots.ExpiringFilter{CompanyID: &cid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || len(ee) != 1 {
+		t.Fatalf("n=%d expiring=%+v, want 1", n, ee)
+	}
+	if e := ee[0]; e.EntryID != eids[0] || e.Quantity != 3 {
+		t.Fatalf("expiring=%+v, want 3 left of entry %d", e, eids[0])
+	}
+}
@@ -38,6 +38,11 @@ func (s *EntryTypeService) CreateEntryType(ctx context.Context, et *dots.EntryTy
 		return err
 	}
 
+	fields := map[string]*string{"code": et.Code, "unit": et.Unit, "description": et.Description}
+	if err := checkQuota(ctx, tx, dots.ResourceEntryType, fields); err != nil {
+		return err
+	}
+
 	if err := createEntryType(ctx, tx, et); err != nil {
 		return perr(err)
 	}
@@ -130,6 +135,11 @@ func (s *EntryTypeService) UpdateEntryType(ctx context.Context, id int, upd dots
 		return nil, canerr
 	}
 
+	fields := map[string]*string{"code": upd.Code, "unit": upd.Unit, "description": upd.Description}
+	if err := checkFieldLen(ctx, tx, dots.ResourceEntryType, fields); err != nil {
+		return nil, err
+	}
+
 	et, err := updateEntryType(ctx, tx, id, upd)
 	if err != nil {
 		return nil, err
@@ -180,6 +190,37 @@ func (s *EntryTypeService) DeleteEntryType(ctx context.Context, id int, filter d
 
 }
 
+func (s *EntryTypeService) RestoreEntryType(ctx context.Context, restore dots.TrashRestore) (int, error) {
+	if err := restore.Validate(); err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanDeleteOwn(ctx); canerr != nil {
+		return 0, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return 0, err
+	}
+
+	n, err := restoreEach("entry type", restore.ID, func(id int) (int, error) {
+		return deleteEntryType(ctx, tx, id, true)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	tx.Commit()
+
+	return n, nil
+}
+
 func createEntryType(ctx context.Context, tx *Tx, et *dots.EntryType) error {
 	sqlstr, args := `
 insert into entry_type
@@ -320,17 +361,18 @@ func findEntryType(ctx context.Context, tx *Tx, filter dots.EntryTypeFilterOrder
 		}
 	}
 
-	wherestr := ""
-	if len(where) > 0 {
-		replaceQuestionMark(where, args)
-		wherestr = "where " + strings.Join(where, " and ")
-	}
+	trash, trashArgs, state := trashWhere("deleted_at", filter.IsDeleted, filter.DeletedAtFrom, filter.DeletedAtTo)
+	where, args = append(where, trash...), append(args, trashArgs...)
+
+	replaceQuestionMark(where, args)
+	where = append(where, state)
+	wherestr := "where " + strings.Join(where, " and ")
 	limitoffset := formatLimitOffset(filter.Limit, filter.Offset)
 	orderstr := ""
 	if len(order) > 0 {
 		orderstr = "order by " + strings.Join(order, ", ")
 	}
-	sqlstr := `select id, code, description, unit, count(*) over() from entry_type
+	sqlstr := `select id, code, description, unit, deleted_at, count(*) over() from core.entry_type
 	` + wherestr + " " + orderstr + " " + limitoffset
 
 	fmt.Println(sqlstr, args)
@@ -351,7 +393,7 @@ func findEntryType(ctx context.Context, tx *Tx, filter dots.EntryTypeFilterOrder
 	empty := ""
 	for rows.Next() {
 		var et dots.EntryType
-		err := rows.Scan(&et.ID, &et.Code, &et.Description, &et.Unit, &n)
+		err := rows.Scan(&et.ID, &et.Code, &et.Description, &et.Unit, &et.DeletedAt, &n)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -0,0 +1,442 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/innermond/dots"
+	"github.com/shopspring/decimal"
+)
+
+type InventoryCountService struct {
+	db *DB
+}
+
+func NewInventoryCountService(db *DB) *InventoryCountService {
+	return &InventoryCountService{db: db}
+}
+
+func (s *InventoryCountService) CreateInventoryCount(ctx context.Context, ic *dots.InventoryCount) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanCreateOwn(ctx); canerr != nil {
+		return canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(
+		ctx,
+		`insert into core.inventory_count (note) values ($1) returning id, status, created_at`,
+		ic.Note,
+	).Scan(&ic.ID, &ic.Status, &ic.CreatedAt)
+	if err != nil {
+		return perr(err)
+	}
+	ic.Lines = []*dots.InventoryCountLine{}
+
+	return tx.Commit()
+}
+
+func (s *InventoryCountService) FindInventoryCount(ctx context.Context, filter dots.InventoryCountFilter) ([]*dots.InventoryCount, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, 0, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	return findInventoryCount(ctx, tx, filter)
+}
+
+func (s *InventoryCountService) UpdateInventoryCount(ctx context.Context, id int, upd dots.InventoryCountUpdate) (*dots.InventoryCount, error) {
+	if err := upd.Validate(); err != nil {
+		return nil, err
+	}
+
+	if canerr := dots.CanWriteOwn(ctx); canerr != nil {
+		return nil, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := lockOpenInventoryCount(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	for _, l := range upd.Lines {
+		if err := countInventoryLine(ctx, tx, id, l); err != nil {
+			return nil, perr(err)
+		}
+	}
+
+	ic, err := findInventoryCountByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return ic, tx.Commit()
+}
+
+func (s *InventoryCountService) ApproveInventoryCount(ctx context.Context, id int) (*dots.InventoryCount, error) {
+	if canerr := dots.CanWriteOwn(ctx); canerr != nil {
+		return nil, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := lockOpenInventoryCount(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	if err := approveInventoryCount(ctx, tx, id); err != nil {
+		return nil, perr(err)
+	}
+
+	ic, err := findInventoryCountByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return ic, tx.Commit()
+}
+
+func (s *InventoryCountService) CancelInventoryCount(ctx context.Context, id int) (*dots.InventoryCount, error) {
+	if canerr := dots.CanWriteOwn(ctx); canerr != nil {
+		return nil, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := lockOpenInventoryCount(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`update core.inventory_count set status = $2, closed_at = now() where id = $1`,
+		id, dots.CountCancelled,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("postgres.inventory count: cannot cancel %w", err)
+	}
+
+	ic, err := findInventoryCountByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return ic, tx.Commit()
+}
+
+// lockOpenInventoryCount holds the count till the transaction ends, closed ones do not change
+func lockOpenInventoryCount(ctx context.Context, tx *Tx, id int) error {
+	var status string
+	err := tx.QueryRowContext(ctx, `select status from core.inventory_count where id = $1 for update`, id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return dots.Errorf(dots.ENOTFOUND, "inventory count not found")
+	}
+	if err != nil {
+		return err
+	}
+	if status != dots.CountOpen {
+		return dots.Errorf(dots.ECONFLICT, "inventory count is %s", status)
+	}
+
+	return nil
+}
+
+func countInventoryLine(ctx context.Context, tx *Tx, id int, l *dots.InventoryCountLine) error {
+	var found bool
+	err := tx.QueryRowContext(
+		ctx, `
+select exists(select 1 from api.company where id = $1)
+and exists(select 1 from api.entry_type where id = $2)`,
+		*l.CompanyID, *l.EntryTypeID,
+	).Scan(&found)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return dots.Errorf(dots.ENOTFOUND, "company %d or entry type %d not found", *l.CompanyID, *l.EntryTypeID)
+	}
+
+	_, err = tx.ExecContext(
+		ctx, `
+insert into core.inventory_count_line
+(count_id, company_id, entry_type_id, counted, reason)
+values
+($1, $2, $3, $4, $5)
+on conflict (count_id, company_id, entry_type_id) do update set counted = EXCLUDED.counted, reason = EXCLUDED.reason`,
+		id, *l.CompanyID, *l.EntryTypeID, *l.Counted, l.Reason,
+	)
+
+	return err
+}
+
+// approveInventoryCount drains every loss through a deed of its own and brings every gain in
+// as an entry costed as the latest one of its company and entry type
+func approveInventoryCount(ctx context.Context, tx *Tx, id int) error {
+	ic, err := findInventoryCountByID(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if len(ic.Lines) == 0 {
+		return dots.Errorf(dots.EINVALID, "nothing counted")
+	}
+
+	unexplained := []string{}
+	for _, l := range ic.Lines {
+		if *l.Variance != 0 && l.Reason == nil {
+			unexplained = append(unexplained, fmt.Sprintf("%d/%d", *l.CompanyID, *l.EntryTypeID))
+		}
+	}
+	if len(unexplained) > 0 {
+		return dots.Errorf(dots.EINVALID, "a reason is required for every variance").WithData(map[string]interface{}{"unexplained": unexplained})
+	}
+
+	for _, l := range ic.Lines {
+		var deedID, entryID *int
+		switch {
+		case *l.Variance < 0:
+			deedID, err = writeOffInventoryLine(ctx, tx, id, l)
+		case *l.Variance > 0:
+			entryID, err = gainInventoryLine(ctx, tx, l)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx, `
+update core.inventory_count_line set expected = $4, deed_id = $5, entry_id = $6
+where count_id = $1 and company_id = $2 and entry_type_id = $3`,
+			id, *l.CompanyID, *l.EntryTypeID, *l.Expected, deedID, entryID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`update core.inventory_count set status = $2, closed_at = now() where id = $1`,
+		id, dots.CountApproved,
+	)
+
+	return err
+}
+
+func writeOffInventoryLine(ctx context.Context, tx *Tx, id int, l *dots.InventoryCountLine) (*int, error) {
+	loss := -*l.Variance
+	cid, etid := *l.CompanyID, *l.EntryTypeID
+
+	var unit string
+	if err := tx.QueryRowContext(ctx, `select unit from core.entry_type where id = $1`, etid).Scan(&unit); err != nil {
+		return nil, err
+	}
+
+	distribute, err := writeOffOverEntryType(ctx, tx, map[int]float64{etid: loss}, cid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, dots.Errorf(dots.ENOTFOUND, "no entries of entry type %d to write off", etid)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	title := fmt.Sprintf("inventory count %d %s", id, *l.Reason)
+	d := &dots.Deed{DeedUpdate: dots.DeedUpdate{CompanyID: &cid, Title: &title, Quantity: &loss, Unit: &unit}}
+	if err := checkQuota(ctx, tx, dots.ResourceDeed, map[string]*string{"title": d.Title, "unit": d.Unit}); err != nil {
+		return nil, err
+	}
+	if err := createDeed(ctx, tx, d); err != nil {
+		return nil, err
+	}
+
+	// what is lost cannot be held any longer, holds let go before the drains check them
+	if err := giveUpEntryHolds(ctx, tx, distribute); err != nil {
+		return nil, err
+	}
+	if err := giveUpEntryTypeHolds(ctx, tx, etid, cid, distribute); err != nil {
+		return nil, err
+	}
+	if err := drainInOrder(ctx, tx, *d.ID, distribute, nil); err != nil {
+		return nil, err
+	}
+
+	return d.ID, nil
+}
+
+func gainInventoryLine(ctx context.Context, tx *Tx, l *dots.InventoryCountLine) (*int, error) {
+	var unitcost *decimal.Decimal
+	err := tx.QueryRowContext(
+		ctx, `
+select unitcost from core.entry
+where company_id = $1 and entry_type_id = $2 and deleted_at is null
+order by date_added desc, id desc
+limit 1`,
+		*l.CompanyID, *l.EntryTypeID,
+	).Scan(&unitcost)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if err := checkQuota(ctx, tx, dots.ResourceEntry, nil); err != nil {
+		return nil, err
+	}
+	gain := *l.Variance
+	e := &dots.Entry{EntryTypeID: l.EntryTypeID, Quantity: &gain, CompanyID: l.CompanyID, UnitCost: unitcost}
+	if err := createEntry(ctx, tx, e); err != nil {
+		return nil, err
+	}
+
+	return e.ID, nil
+}
+
+func findInventoryCountByID(ctx context.Context, tx *Tx, id int) (*dots.InventoryCount, error) {
+	cc, _, err := findInventoryCount(ctx, tx, dots.InventoryCountFilter{ID: &id, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(cc) == 0 {
+		return nil, dots.Errorf(dots.ENOTFOUND, "inventory count not found")
+	}
+
+	return cc[0], nil
+}
+
+func findInventoryCount(ctx context.Context, tx *Tx, filter dots.InventoryCountFilter) (_ []*dots.InventoryCount, n int, err error) {
+	where, args := []string{}, []interface{}{}
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.Status; v != nil {
+		where, args = append(where, "status = ?"), append(args, *v)
+	}
+	if v := filter.From; v != nil {
+		where, args = append(where, "created_at >= ?"), append(args, *v)
+	}
+	if v := filter.To; v != nil {
+		where, args = append(where, "created_at < ?"), append(args, *v)
+	}
+
+	wherestr := ""
+	if len(where) > 0 {
+		replaceQuestionMark(where, args)
+		wherestr = "where " + strings.Join(where, " and ")
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+select id, note, status, created_at, closed_at, count(*) over()
+from core.inventory_count
+`+wherestr+`
+order by created_at desc, id desc `+formatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	counts := []*dots.InventoryCount{}
+	byID := map[int]*dots.InventoryCount{}
+	ids := []int{}
+	for rows.Next() {
+		var ic dots.InventoryCount
+		if err := rows.Scan(&ic.ID, &ic.Note, &ic.Status, &ic.CreatedAt, &ic.ClosedAt, &n); err != nil {
+			return nil, 0, err
+		}
+		ic.Lines = []*dots.InventoryCountLine{}
+		counts = append(counts, &ic)
+		byID[ic.ID] = &ic
+		ids = append(ids, ic.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	rows.Close()
+
+	if len(ids) == 0 {
+		return counts, n, nil
+	}
+
+	// open counts are set against the physical stock as it is now, the one losses are written off
+	rows, err = tx.QueryContext(ctx, `
+select l.count_id, l.company_id, l.entry_type_id, l.counted, l.reason,
+	case when c.status = 'open' then coalesce(s.quantity, 0) else l.expected end,
+	l.deed_id, l.entry_id
+from core.inventory_count_line l
+join core.inventory_count c on c.id = l.count_id
+left join lateral (
+	select sum(`+physicalStock+`) quantity
+	from api.entry_with_quantity_drained e
+	where e.company_id = l.company_id and e.entry_type_id = l.entry_type_id
+) s on c.status = 'open'
+where l.count_id = any($1)
+order by l.count_id, l.company_id, l.entry_type_id`, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id int
+			l  dots.InventoryCountLine
+		)
+		if err := rows.Scan(&id, &l.CompanyID, &l.EntryTypeID, &l.Counted, &l.Reason, &l.Expected, &l.DeedID, &l.EntryID); err != nil {
+			return nil, 0, err
+		}
+		if l.Expected != nil {
+			expected := aprox(*l.Expected, 5)
+			variance := aprox(*l.Counted-expected, 5)
+			l.Expected, l.Variance = &expected, &variance
+		}
+		byID[id].Lines = append(byID[id].Lines, &l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return counts, n, nil
+}
@@ -0,0 +1,108 @@
+package postgres_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/innermond/dots"
+	"github.com/innermond/dots/postgres"
+)
+
+func TestInventoryCountService_ApproveInventoryCount(t *testing.T) {
+	t.Run("WriteOff", func(t *testing.T) {
+		db := MustOpenDB(t, DSN)
+		defer MustCloseDB(t, db)
+
+		ctx, deleteTenant := MustCreateTenant(t, db)
+		defer deleteTenant()
+		cid, etid, _ := MustCreateStock(t, ctx, db, 10)
+
+		approved := mustApproveCount(t, ctx, db, cid, etid, 7, dots.ReasonDamage)
+		l := approved.Lines[0]
+		if l.DeedID == nil || l.EntryID != nil {
+			t.Fatalf("line=%+v, want a write-off deed", l)
+		}
+		if got := MustFindStock(t, ctx, db, cid, etid); got != 7 {
+			t.Fatalf("stock=%v, want 7", got)
+		}
+	})
+
+	t.Run("Gain", func(t *testing.T) {
+		db := MustOpenDB(t, DSN)
+		defer MustCloseDB(t, db)
+
+		ctx, deleteTenant := MustCreateTenant(t, db)
+		defer deleteTenant()
+		cid, etid, _ := MustCreateStock(t, ctx, db, 10)
+
+		approved := mustApproveCount(t, ctx, db, cid, etid, 12, dots.ReasonFound)
+		l := approved.Lines[0]
+		if l.EntryID == nil || l.DeedID != nil {
+			t.Fatalf("line=%+v, want a new entry", l)
+		}
+		if got := MustFindStock(t, ctx, db, cid, etid); got != 12 {
+			t.Fatalf("stock=%v, want 12", got)
+		}
+	})
+
+	t.Run("WriteOffHeld", func(t *testing.T) {
+		db := MustOpenDB(t, DSN)
+		defer MustCloseDB(t, db)
+
+		ctx, deleteTenant := MustCreateTenant(t, db)
+		defer deleteTenant()
+		cid, etid, _ := MustCreateStock(t, ctx, db, 10)
+		rs := postgres.NewReservationService(db)
+		deedID := MustCreateDeed(t, ctx, db, cid)
+		MustCreateReservation(t, ctx, rs, deedID, etid, 10, nil)
+
+		// a loss is written off even when it was held, the hold shrinks to what is left
+		mustApproveCount(t, ctx, db, cid, etid, 7, dots.ReasonTheft)
+		rr, _, err := rs.FindReservation(ctx, dots.ReservationFilter{DeedID: &deedID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rr) != 1 || *rr[0].Quantity != 7 {
+			t.Fatalf("reservations=%+v, want 7 held", rr)
+		}
+	})
+
+	t.Run("ErrApproved", func(t *testing.T) {
+		db := MustOpenDB(t, DSN)
+		defer MustCloseDB(t, db)
+
+		ctx, deleteTenant := MustCreateTenant(t, db)
+		defer deleteTenant()
+		cid, etid, _ := MustCreateStock(t, ctx, db, 10)
+
+		approved := mustApproveCount(t, ctx, db, cid, etid, 7, dots.ReasonDamage)
+		if _, err := postgres.NewInventoryCountService(db).ApproveInventoryCount(ctx, approved.ID); dots.ErrorCode(err) != dots.ECONFLICT {
+			t.Fatalf("err=%v, want %s", err, dots.ECONFLICT)
+		}
+		if got := MustFindStock(t, ctx, db, cid, etid); got != 7 {
+			t.Fatalf("stock=%v, want 7 written off once", got)
+		}
+	})
+}
+
+// mustApproveCount counts the entry type of the company and approves the count
+func mustApproveCount(t *testing.T, ctx context.Context, db *postgres.DB, cid, etid int, counted float64, reason string) *dots.InventoryCount {
+	t.Helper()
+
+	s := postgres.NewInventoryCountService(db)
+	ic := &dots.InventoryCount{}
+	if err := s.CreateInventoryCount(ctx, ic); err != nil {
+		t.Fatal(err)
+	}
+	upd := dots.InventoryCountUpdate{Lines: []*dots.InventoryCountLine{
+		{CompanyID: &cid, EntryTypeID: &etid, Counted: &counted, Reason: &reason},
+	}}
+	if _, err := s.UpdateInventoryCount(ctx, ic.ID, upd); err != nil {
+		t.Fatal(err)
+	}
+	approved, err := s.ApproveInventoryCount(ctx, ic.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return approved
+}
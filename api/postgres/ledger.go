@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+
+	"github.com/innermond/dots"
+)
+
+type LedgerService struct {
+	db *DB
+}
+
+func NewLedgerService(db *DB) *LedgerService {
+	return &LedgerService{db: db}
+}
+
+func (s *LedgerService) FindLedger(ctx context.Context, filter dots.LedgerFilter) ([]*dots.LedgerLine, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, 0, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	return findLedger(ctx, tx, filter)
+}
+
+func findLedger(ctx context.Context, tx *Tx, filter dots.LedgerFilter) (_ []*dots.LedgerLine, n int, err error) {
+	where, args := []string{}, []interface{}{}
+	if v := filter.CompanyID; v != nil {
+		where, args = append(where, "company_id = ?"), append(args, *v)
+	}
+	if v := filter.EntryTypeID; v != nil {
+		where, args = append(where, "entry_type_id = ?"), append(args, *v)
+	}
+	if v := filter.From; v != nil {
+		where, args = append(where, "at >= ?"), append(args, *v)
+	}
+	if v := filter.To; v != nil {
+		where, args = append(where, "at < ?"), append(args, *v)
+	}
+
+	wherestr := ""
+	if len(where) > 0 {
+		replaceQuestionMark(where, args)
+		wherestr = "where " + strings.Join(where, " and ")
+	}
+
+	// balances are summed over the whole history of a pool before the filters apply,
+	// at the same time entries go first, then drains and their reversals
+	sqlstr := `
+with moves as (
+	select e.company_id, e.entry_type_id, coalesce(e.date_added, now()) at, 0 ord, 'in' kind, e.id entry_id, null::bigint deed_id, e.quantity
+	from core.entry e
+	where e.deleted_at is null
+	union all
+	select e.company_id, e.entry_type_id, m.moved_at, case when m.quantity > 0 then 1 else 2 end,
+	case when m.quantity > 0 then 'out' else 'reversal' end, m.entry_id, m.deed_id, -m.quantity
+	from core.drain_movement m
+	join core.entry e on e.id = m.entry_id
+	where e.deleted_at is null
+), ledger as (
+	select m.*, sum(m.quantity) over (
+		partition by m.company_id, m.entry_type_id
+		order by m.at, m.ord, m.entry_id, m.deed_id
+		rows between unbounded preceding and current row
+	) balance
+	from moves m
+)
+select company_id, entry_type_id, at, kind, entry_id, deed_id, quantity, balance, count(*) over()
+from ledger
+` + wherestr + `
+order by company_id, entry_type_id, at, ord, entry_id, deed_id
+` + formatLimitOffset(filter.Limit, filter.Offset)
+
+	rows, err := tx.QueryContext(ctx, sqlstr, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	lines := []*dots.LedgerLine{}
+	for rows.Next() {
+		var l dots.LedgerLine
+		err := rows.Scan(&l.CompanyID, &l.EntryTypeID, &l.At, &l.Kind, &l.EntryID, &l.DeedID, &l.Quantity, &l.Balance, &n)
+		if err != nil {
+			return nil, 0, err
+		}
+		l.Balance = aprox(l.Balance, 5)
+		lines = append(lines, &l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return lines, n, nil
+}
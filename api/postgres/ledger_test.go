@@ -0,0 +1,55 @@
+package postgres_test
+
+import (
+	"testing"
+
+	"github.com/innermond/dots"
+	"github.com/innermond/dots/postgres"
+)
+
+func TestLedgerService_FindLedger(t *testing.T) {
+	db := MustOpenDB(t, DSN)
+	defer MustCloseDB(t, db)
+
+	ctx, deleteTenant := MustCreateTenant(t, db)
+	defer deleteTenant()
+	cid, etid, eid := MustCreateStock(t, ctx, db, 10)
+
+	sold := newDeed(cid, "SOLD", 1, map[int]float64{eid: 3})
+	kept := newDeed(cid, "KEPT", 1, map[int]float64{eid: 2})
+	for _, d := range []*dots.Deed{&sold, &kept} {
+		if err := postgres.NewDeedService(db).CreateDeed(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a soft deleted drain gives its quantity back
+	if _, err := postgres.NewDrainService(db).DeleteDrain(ctx, *sold.ID, eid, dots.DrainDelete{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ll, n, err := postgres.NewLedgerService(db).FindLedger(ctx, dots.LedgerFilter{CompanyID: &cid, EntryTypeID: &etid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		kind     string
+		quantity float64
+		balance  float64
+	}{
+		{dots.LedgerIn, 10, 10},
+		{dots.LedgerOut, -3, 7},
+		{dots.LedgerOut, -2, 5},
+		{dots.LedgerReversal, 3, 8},
+	}
+	if n != len(want) || len(ll) != len(want) {
+		t.Fatalf("n=%d lines=%d, want %d", n, len(ll), len(want))
+	}
+	for i, w := range want {
+		if l := ll[i]; l.Kind != w.kind || l.Quantity != w.quantity || l.Balance != w.balance {
+			t.Fatalf("line %d=%+v, want %s %v balance %v", i, l, w.kind, w.quantity, w.balance)
+		}
+	}
+	if got := MustFindStock(t, ctx, db, cid, etid); got != 8 {
+		t.Fatalf("stock=%v, want 8 as the ledger ends", got)
+	}
+}
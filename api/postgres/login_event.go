@@ -0,0 +1,186 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/innermond/dots"
+	"github.com/segmentio/ksuid"
+)
+
+// lockoutWindow bounds how far back failed logins are counted
+const lockoutWindow = 24 * time.Hour
+
+type LoginEventService struct {
+	db *DB
+
+	policy dots.LockoutPolicy
+}
+
+func NewLoginEventService(db *DB, policy dots.LockoutPolicy) *LoginEventService {
+	return &LoginEventService{db: db, policy: policy}
+}
+
+func (s *LoginEventService) CreateLoginEvent(ctx context.Context, e *dots.LoginEvent) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createLoginEvent(ctx, tx, e); err != nil {
+		return perr(err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *LoginEventService) FindLoginEvent(ctx context.Context, filter dots.LoginEventFilter) ([]*dots.LoginEvent, int, error) {
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, 0, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	u := dots.UserFromContext(ctx)
+	return findLoginEvent(ctx, tx, u.ID, filter)
+}
+
+func (s *LoginEventService) PasswordLogin(ctx context.Context, e *dots.LoginEvent, login func() error) (time.Duration, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// attempts for an email wait on each other until the one before is recorded,
+	// otherwise parallel guesses would all pass the check below
+	_, err = tx.ExecContext(ctx, `select pg_advisory_xact_lock(hashtext(lower($1)))`, e.Email)
+	if err != nil {
+		return 0, err
+	}
+
+	e.Kind = dots.LoginPassword
+	wait, err := s.lockout(ctx, tx, e.Email)
+	if err != nil {
+		return 0, err
+	}
+	if wait > 0 {
+		e.Reason = dots.LoginReasonLocked
+		if err := createLoginEvent(ctx, tx, e); err != nil {
+			return 0, perr(err)
+		}
+		return wait, tx.Commit()
+	}
+
+	loginErr := login()
+	e.Success = loginErr == nil
+	if loginErr != nil {
+		e.Reason = dots.ErrorMessage(loginErr)
+	}
+	// an attempt left unrecorded would not count toward the lockout
+	if err := createLoginEvent(ctx, tx, e); err != nil {
+		return 0, perr(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return 0, loginErr
+}
+
+// lockout tells how long password logins of email must wait
+func (s *LoginEventService) lockout(ctx context.Context, tx *Tx, email string) (time.Duration, error) {
+	// failures in a row, a success starts counting again
+	var (
+		failures int
+		last     sql.NullTime
+	)
+	err := tx.QueryRowContext(
+		ctx, `
+select count(*), max(created_at)
+from core.login_event
+where lower(email) = lower($1) and kind = $2 and not success and reason is distinct from $3
+and created_at > $4
+and created_at > coalesce((
+	select max(created_at) from core.login_event
+	where lower(email) = lower($1) and kind = $2 and success
+), '-infinity')`,
+		email, dots.LoginPassword, dots.LoginReasonLocked, tx.now.Add(-lockoutWindow),
+	).Scan(&failures, &last)
+	if err != nil {
+		return 0, err
+	}
+	if !last.Valid {
+		return 0, nil
+	}
+
+	return s.policy.Wait(failures, last.Time, tx.now), nil
+}
+
+// createLoginEvent links the event to the user owning the email when not told who it is,
+// so failed attempts show up in the history of the targeted account
+func createLoginEvent(ctx context.Context, tx *Tx, e *dots.LoginEvent) error {
+	e.CreatedAt = tx.now
+	return tx.QueryRowContext(
+		ctx, `
+insert into core.login_event
+(user_id, email, kind, source, success, reason, ip, user_agent, created_at)
+values
+(coalesce($1, (select id from core."user" where $2 <> '' and lower(email) = lower($2) limit 1)), $2, $3, $4, $5, $6, $7, $8, $9)
+returning id, user_id`,
+		e.UserID, e.Email, e.Kind, e.Source, e.Success, e.Reason, e.IP, e.UserAgent, e.CreatedAt,
+	).Scan(&e.ID, &e.UserID)
+}
+
+func findLoginEvent(ctx context.Context, tx *Tx, uid ksuid.KSUID, filter dots.LoginEventFilter) (_ []*dots.LoginEvent, n int, err error) {
+	where, args := []string{"user_id = ?"}, []interface{}{uid}
+	if v := filter.Success; v != nil {
+		where, args = append(where, "success = ?"), append(args, *v)
+	}
+	if v := filter.Kind; v != nil {
+		where, args = append(where, "kind = ?"), append(args, *v)
+	}
+	replaceQuestionMark(where, args)
+
+	rows, err := tx.QueryContext(
+		ctx, `
+select
+	id, user_id, coalesce(email, ''), kind, coalesce(source, ''), success, coalesce(reason, ''),
+	coalesce(ip, ''), coalesce(user_agent, ''), created_at,
+	count(*) over()
+from core.login_event
+where `+strings.Join(where, " and ")+`
+order by created_at desc, id desc `+formatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []*dots.LoginEvent{}
+	for rows.Next() {
+		var e dots.LoginEvent
+		err := rows.Scan(
+			&e.ID, &e.UserID, &e.Email, &e.Kind, &e.Source, &e.Success, &e.Reason,
+			&e.IP, &e.UserAgent, &e.CreatedAt,
+			&n,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return events, n, nil
+}
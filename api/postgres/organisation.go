@@ -0,0 +1,381 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/innermond/dots"
+	"github.com/segmentio/ksuid"
+)
+
+type OrganisationService struct {
+	db *DB
+}
+
+func NewOrganisationService(db *DB) *OrganisationService {
+	return &OrganisationService{db: db}
+}
+
+func (s *OrganisationService) CreateOrganisation(ctx context.Context, o *dots.Organisation) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	if canerr := dots.CanCreateOwn(ctx); canerr != nil {
+		return canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	u := dots.UserFromContext(ctx)
+	err = tx.QueryRowContext(
+		ctx,
+		`insert into core.organisation (name, created_at) values ($1, $2) returning id`,
+		o.Name, tx.now,
+	).Scan(&o.ID)
+	if err != nil {
+		return perr(err)
+	}
+	o.CreatedAt = tx.now
+	o.Role = dots.RoleOwner
+
+	if err := createMembership(ctx, tx, o.ID, u.ID, dots.RoleOwner); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *OrganisationService) FindOrganisation(ctx context.Context) ([]*dots.Organisation, int, error) {
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, 0, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	u := dots.UserFromContext(ctx)
+	rows, err := tx.QueryContext(
+		ctx, `
+select o.id, o.name, o.created_at, m.role, count(*) over()
+from core.organisation o
+join core.membership m on m.organisation_id = o.id
+where m.user_id = $1
+order by o.created_at`,
+		u.ID,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	oo := []*dots.Organisation{}
+	for rows.Next() {
+		var o dots.Organisation
+		if err := rows.Scan(&o.ID, &o.Name, &o.CreatedAt, &o.Role, &n); err != nil {
+			return nil, 0, err
+		}
+		oo = append(oo, &o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return oo, n, nil
+}
+
+func (s *OrganisationService) FindMember(ctx context.Context, oid ksuid.KSUID) ([]*dots.Member, int, error) {
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, 0, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	u := dots.UserFromContext(ctx)
+	if _, err := findMembershipRole(ctx, tx, oid, u.ID); err != nil {
+		return nil, 0, err
+	}
+
+	return findMember(ctx, tx, oid, nil)
+}
+
+func (s *OrganisationService) AddMember(ctx context.Context, oid ksuid.KSUID, ma dots.MemberAdd) (*dots.Member, error) {
+	if err := ma.Validate(); err != nil {
+		return nil, err
+	}
+
+	if canerr := dots.CanWriteOwn(ctx); canerr != nil {
+		return nil, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	u := dots.UserFromContext(ctx)
+	if err := canManageMembers(ctx, tx, oid, u.ID, ma.Role); err != nil {
+		return nil, err
+	}
+
+	cred, err := findUserCredentials(ctx, tx, dots.UserFilter{Email: &ma.Email})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := createMembership(ctx, tx, oid, cred.id, ma.Role); err != nil {
+		return nil, err
+	}
+
+	mm, _, err := findMember(ctx, tx, oid, &cred.id)
+	if err != nil {
+		return nil, err
+	}
+	if len(mm) == 0 {
+		return nil, dots.Errorf(dots.ENOTFOUND, "member not found")
+	}
+
+	return mm[0], tx.Commit()
+}
+
+func (s *OrganisationService) UpdateMember(ctx context.Context, oid ksuid.KSUID, uid ksuid.KSUID, mu dots.MemberUpdate) (*dots.Member, error) {
+	if err := mu.Validate(); err != nil {
+		return nil, err
+	}
+
+	if canerr := dots.CanWriteOwn(ctx); canerr != nil {
+		return nil, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	u := dots.UserFromContext(ctx)
+	if err := canManageMembers(ctx, tx, oid, u.ID, mu.Role); err != nil {
+		return nil, err
+	}
+
+	current, err := findMembershipRole(ctx, tx, oid, uid)
+	if err != nil {
+		return nil, err
+	}
+	if current == dots.RoleOwner && mu.Role != dots.RoleOwner {
+		if err := canLoseOwner(ctx, tx, oid, u.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`update core.membership set role = $3 where organisation_id = $1 and user_id = $2`,
+		oid, uid, mu.Role,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("postgres.organisation: cannot update member: %w", err)
+	}
+
+	mm, _, err := findMember(ctx, tx, oid, &uid)
+	if err != nil {
+		return nil, err
+	}
+	if len(mm) == 0 {
+		return nil, dots.Errorf(dots.ENOTFOUND, "member not found")
+	}
+
+	return mm[0], tx.Commit()
+}
+
+func (s *OrganisationService) RemoveMember(ctx context.Context, oid ksuid.KSUID, uid ksuid.KSUID) error {
+	if canerr := dots.CanWriteOwn(ctx); canerr != nil {
+		return canerr
+	}
+
+	if oid == uid {
+		return dots.Errorf(dots.ECONFLICT, "cannot leave a personal organisation")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// anyone may leave, only managers may remove others
+	u := dots.UserFromContext(ctx)
+	if u.ID != uid {
+		if err := canManageMembers(ctx, tx, oid, u.ID, dots.RoleMember); err != nil {
+			return err
+		}
+	}
+
+	current, err := findMembershipRole(ctx, tx, oid, uid)
+	if err != nil {
+		return err
+	}
+	if current == dots.RoleOwner {
+		if err := canLoseOwner(ctx, tx, oid, u.ID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`delete from core.membership where organisation_id = $1 and user_id = $2`,
+		oid, uid,
+	)
+	if err != nil {
+		return fmt.Errorf("postgres.organisation: cannot remove member: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *OrganisationService) Membership(ctx context.Context, oid ksuid.KSUID, uid ksuid.KSUID) (dots.Role, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	return findMembershipRole(ctx, tx, oid, uid)
+}
+
+// createPersonalOrganisation gives a new user the tenant sharing its ID
+func createPersonalOrganisation(ctx context.Context, tx *Tx, u *dots.User) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`insert into core.organisation (id, name, created_at) values ($1, $2, $3)`,
+		u.ID, u.Name, tx.now,
+	)
+	if err != nil {
+		return fmt.Errorf("postgres.organisation: cannot create personal organisation: %w", err)
+	}
+
+	return createMembership(ctx, tx, u.ID, u.ID, dots.RoleOwner)
+}
+
+func createMembership(ctx context.Context, tx *Tx, oid ksuid.KSUID, uid ksuid.KSUID, role dots.Role) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`insert into core.membership (organisation_id, user_id, role, created_at) values ($1, $2, $3, $4)`,
+		oid, uid, role, tx.now,
+	)
+	if err != nil {
+		return perr(err)
+	}
+	return nil
+}
+
+func findMembershipRole(ctx context.Context, tx *Tx, oid ksuid.KSUID, uid ksuid.KSUID) (dots.Role, error) {
+	var role dots.Role
+	err := tx.QueryRowContext(
+		ctx,
+		`select role from core.membership where organisation_id = $1 and user_id = $2`,
+		oid, uid,
+	).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", dots.Errorf(dots.ENOTFOUND, "membership not found")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return role, nil
+}
+
+// canManageMembers checks the caller may hand out the role,
+// only owners make other owners
+func canManageMembers(ctx context.Context, tx *Tx, oid ksuid.KSUID, uid ksuid.KSUID, role dots.Role) error {
+	callerRole, err := findMembershipRole(ctx, tx, oid, uid)
+	if err != nil {
+		return err
+	}
+	if !callerRole.CanManage() {
+		return dots.Errorf(dots.EUNAUTHORIZED, "role %s cannot manage members", callerRole)
+	}
+	if role == dots.RoleOwner && callerRole != dots.RoleOwner {
+		return dots.Errorf(dots.EUNAUTHORIZED, "only owners can make owners")
+	}
+	return nil
+}
+
+// canLoseOwner keeps at least one owner and lets only owners touch owners
+func canLoseOwner(ctx context.Context, tx *Tx, oid ksuid.KSUID, caller ksuid.KSUID) error {
+	callerRole, err := findMembershipRole(ctx, tx, oid, caller)
+	if err != nil {
+		return err
+	}
+	if callerRole != dots.RoleOwner {
+		return dots.Errorf(dots.EUNAUTHORIZED, "only owners can change owners")
+	}
+
+	var owners int
+	err = tx.QueryRowContext(
+		ctx,
+		`select count(*) from core.membership where organisation_id = $1 and role = $2`,
+		oid, dots.RoleOwner,
+	).Scan(&owners)
+	if err != nil {
+		return err
+	}
+	if owners < 2 {
+		return dots.Errorf(dots.ECONFLICT, "organisation needs at least one owner")
+	}
+
+	return nil
+}
+
+func findMember(ctx context.Context, tx *Tx, oid ksuid.KSUID, uid *ksuid.KSUID) (_ []*dots.Member, n int, err error) {
+	where, args := []string{"m.organisation_id = ?"}, []interface{}{oid}
+	if uid != nil {
+		where, args = append(where, "m.user_id = ?"), append(args, *uid)
+	}
+	replaceQuestionMark(where, args)
+
+	rows, err := tx.QueryContext(
+		ctx, `
+select m.organisation_id, m.user_id, u.name, coalesce(u.email, ''), m.role, m.created_at, count(*) over()
+from core.membership m
+join core."user" u on u.id = m.user_id
+where `+strings.Join(where, " and ")+`
+order by m.created_at`,
+		args...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	mm := []*dots.Member{}
+	for rows.Next() {
+		var m dots.Member
+		err := rows.Scan(&m.OrganisationID, &m.UserID, &m.Name, &m.Email, &m.Role, &m.CreatedAt, &n)
+		if err != nil {
+			return nil, 0, err
+		}
+		mm = append(mm, &m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return mm, n, nil
+}
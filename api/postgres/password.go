@@ -0,0 +1,298 @@
+package postgres
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/innermond/dots"
+	"github.com/segmentio/ksuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const passwordResetTTL = 1 * time.Hour
+
+var errCredentials = dots.Errorf(dots.EUNAUTHORIZED, "invalid credentials")
+
+type PasswordService struct {
+	db *DB
+}
+
+func NewPasswordService(db *DB) *PasswordService {
+	return &PasswordService{db: db}
+}
+
+func (s *PasswordService) Register(ctx context.Context, ur dots.UserRegister) (*dots.User, error) {
+	if err := ur.Validate(); err != nil {
+		return nil, err
+	}
+
+	hash, err := hashPassword(ur.Pass)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	u := &dots.User{
+		Name:   ur.Name,
+		Email:  strings.ToLower(ur.Email),
+		Powers: dots.PowerToManageOwn,
+	}
+	// nobody verified the address so it cannot claim one already known
+	if _, err := findUserCredentials(ctx, tx, dots.UserFilter{Email: &u.Email}); err == nil {
+		return nil, dots.Errorf(dots.ECONFLICT, "email already registered")
+	} else if dots.ErrorCode(err) != dots.ENOTFOUND {
+		return nil, err
+	}
+	if err := createUser(ctx, tx, u); err != nil {
+		return nil, perr(err)
+	}
+
+	if err := setUserPassHash(ctx, tx, u.ID, hash); err != nil {
+		return nil, err
+	}
+
+	if err := attachUserAuths(ctx, tx, u); err != nil {
+		return nil, err
+	}
+
+	return u, tx.Commit()
+}
+
+func (s *PasswordService) ChangePassword(ctx context.Context, pc dots.PasswordChange) error {
+	if err := pc.Validate(); err != nil {
+		return err
+	}
+
+	if canerr := dots.CanWriteOwn(ctx); canerr != nil {
+		return canerr
+	}
+	u := dots.UserFromContext(ctx)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	cred, err := findUserCredentials(ctx, tx, dots.UserFilter{ID: &u.ID})
+	if err != nil {
+		return err
+	}
+	// users coming from oauth have no password yet so they can set one,
+	// but not through a personal token that stands in for them
+	if cred.passHash == nil && dots.FromPersonalToken(ctx) {
+		return dots.Errorf(dots.EUNAUTHORIZED, "a first password cannot be set with a personal token")
+	}
+	if cred.passHash != nil {
+		if err := comparePassword(*cred.passHash, pc.Old); err != nil {
+			return err
+		}
+	}
+
+	hash, err := hashPassword(pc.New)
+	if err != nil {
+		return err
+	}
+
+	if err := setUserPassHash(ctx, tx, u.ID, hash); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *PasswordService) CreatePasswordReset(ctx context.Context, email string) (*dots.PasswordResetToken, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return nil, dots.Errorf(dots.EINVALID, "email required")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	cred, err := findUserCredentials(ctx, tx, dots.UserFilter{Email: &email})
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	prt := &dots.PasswordResetToken{
+		UserID:    cred.id,
+		Email:     email,
+		Token:     raw,
+		ExpiresAt: tx.now.Add(passwordResetTTL),
+	}
+
+	// only one reset can be alive for a user
+	_, err = tx.ExecContext(
+		ctx,
+		`delete from core.password_reset where user_id = $1 and used_at is null`,
+		prt.UserID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("postgres.password: cannot remove old resets: %w", err)
+	}
+
+	_, err = tx.ExecContext(
+		ctx, `
+insert into core.password_reset
+(user_id, token_hash, expires_at, created_at)
+values
+($1, $2, $3, $4)`,
+		prt.UserID, hashToken(prt.Token), prt.ExpiresAt, tx.now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("postgres.password: cannot create reset: %w", err)
+	}
+
+	return prt, tx.Commit()
+}
+
+func (s *PasswordService) ResetPassword(ctx context.Context, pr dots.PasswordReset) error {
+	if err := pr.Validate(); err != nil {
+		return err
+	}
+
+	hash, err := hashPassword(pr.Pass)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// burn the token first so it cannot be used twice
+	var uid ksuid.KSUID
+	err = tx.QueryRowContext(
+		ctx, `
+update core.password_reset
+set used_at = $2
+where token_hash = $1 and used_at is null and expires_at > $2
+returning user_id`,
+		hashToken(pr.Token), tx.now,
+	).Scan(&uid)
+	if err == sql.ErrNoRows {
+		return dots.Errorf(dots.EUNAUTHORIZED, "reset token is invalid or expired")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := setUserPassHash(ctx, tx, uid, hash); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type userCredentials struct {
+	id         ksuid.KSUID
+	apiKey     string
+	passHash   *string
+	disabledAt *time.Time
+}
+
+// findUserCredentials is kept apart from findUser
+// so secrets never travel inside dots.User
+func findUserCredentials(ctx context.Context, tx *Tx, filter dots.UserFilter) (*userCredentials, error) {
+	where, args := []string{}, []interface{}{}
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.Email; v != nil {
+		where, args = append(where, "lower(email) = lower(?)"), append(args, *v)
+	}
+	if len(where) == 0 {
+		return nil, errors.New("postgres.password: user filter required")
+	}
+	replaceQuestionMark(where, args)
+
+	var cred userCredentials
+	err := tx.QueryRowContext(
+		ctx,
+		`select id, api_key, pass_hash, disabled_at from core."user" where `+strings.Join(where, " and ")+` limit 1`,
+		args...,
+	).Scan(&cred.id, &cred.apiKey, &cred.passHash, &cred.disabledAt)
+	if err == sql.ErrNoRows {
+		return nil, dots.Errorf(dots.ENOTFOUND, "user not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &cred, nil
+}
+
+func setUserPassHash(ctx context.Context, tx *Tx, id ksuid.KSUID, hash string) error {
+	result, err := tx.ExecContext(
+		ctx,
+		`update core."user" set pass_hash = $1, updated_at = $2 where id = $3`,
+		hash, tx.now, id,
+	)
+	if err != nil {
+		return fmt.Errorf("postgres.password: cannot set password: %w", err)
+	}
+
+	n64, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n64 == 0 {
+		return dots.Errorf(dots.ENOTFOUND, "user not found")
+	}
+
+	return nil
+}
+
+func hashPassword(pass string) (string, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func comparePassword(hash, pass string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return errCredentials
+	}
+	return err
+}
+
+// randomToken is an opaque secret handed to the user, only its hash is stored
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
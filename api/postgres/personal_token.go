@@ -0,0 +1,242 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/innermond/dots"
+	"github.com/segmentio/ksuid"
+)
+
+// personalTokenTTL applies when the token is created without an expiry
+const personalTokenTTL = 90 * 24 * time.Hour
+
+type PersonalTokenService struct {
+	db *DB
+}
+
+func NewPersonalTokenService(db *DB) *PersonalTokenService {
+	return &PersonalTokenService{db: db}
+}
+
+func (s *PersonalTokenService) CreatePersonalToken(ctx context.Context, pc dots.PersonalTokenCreate) (*dots.PersonalToken, error) {
+	if err := pc.Validate(); err != nil {
+		return nil, err
+	}
+
+	if canerr := dots.CanCreateOwn(ctx); canerr != nil {
+		return nil, canerr
+	}
+
+	// a token never gets more than its creator has
+	u := dots.UserFromContext(ctx)
+	for _, p := range pc.Scopes {
+		if !dots.PowersContains(u.Powers, p) {
+			return nil, dots.Errorf(dots.EUNAUTHORIZED, "scope %s exceeds user powers", p)
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	raw, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	pt := &dots.PersonalToken{
+		UserID:    u.ID,
+		Name:      pc.Name,
+		Scopes:    pc.Scopes,
+		ExpiresAt: tx.now.Add(personalTokenTTL),
+		CreatedAt: tx.now,
+		Token:     dots.PersonalTokenPrefix + raw,
+	}
+	if pc.ExpiresAt != nil {
+		pt.ExpiresAt = pc.ExpiresAt.UTC()
+	}
+
+	if err := createPersonalToken(ctx, tx, pt); err != nil {
+		return nil, perr(err)
+	}
+
+	return pt, tx.Commit()
+}
+
+func (s *PersonalTokenService) FindPersonalToken(ctx context.Context, filter dots.PersonalTokenFilter) ([]*dots.PersonalToken, int, error) {
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, 0, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	u := dots.UserFromContext(ctx)
+	return findPersonalToken(ctx, tx, u.ID, filter)
+}
+
+func (s *PersonalTokenService) DeletePersonalToken(ctx context.Context, id int) error {
+	// revoking its own credential is not a delete of owned items
+	if canerr := dots.CanWriteOwn(ctx); canerr != nil {
+		return canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	u := dots.UserFromContext(ctx)
+	result, err := tx.ExecContext(
+		ctx,
+		`delete from core.personal_token where id = $1 and user_id = $2`,
+		id, u.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("postgres.personal_token: cannot delete: %w", err)
+	}
+
+	n64, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n64 == 0 {
+		return dots.Errorf(dots.ENOTFOUND, "personal token not found")
+	}
+
+	return tx.Commit()
+}
+
+func (s *PersonalTokenService) AuthenticatePersonalToken(ctx context.Context, raw string) (*dots.User, error) {
+	if !strings.HasPrefix(raw, dots.PersonalTokenPrefix) {
+		return nil, dots.Errorf(dots.EUNAUTHORIZED, "invalid personal token")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		uid ksuid.KSUID
+		pp  string
+	)
+	err = tx.QueryRowContext(
+		ctx, `
+update core.personal_token
+set last_used_at = $2
+where token_hash = $1 and expires_at > $2
+returning user_id, array_to_json(scopes)`,
+		hashToken(raw), tx.now,
+	).Scan(&uid, &pp)
+	if err == sql.ErrNoRows {
+		return nil, dots.Errorf(dots.EUNAUTHORIZED, "personal token is invalid or expired")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var scopes []dots.Power
+	if err := json.Unmarshal([]byte(pp), &scopes); err != nil {
+		return nil, err
+	}
+
+	u, err := findUserByID(ctx, tx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if u.IsDisabled() {
+		return nil, errDisabled
+	}
+	u.Powers = dots.ScopePowers(u.Powers, scopes)
+
+	return u, tx.Commit()
+}
+
+func createPersonalToken(ctx context.Context, tx *Tx, pt *dots.PersonalToken) error {
+	return tx.QueryRowContext(
+		ctx, `
+insert into core.personal_token
+(user_id, name, token_hash, scopes, expires_at, created_at)
+values
+($1, $2, $3, $4, $5, $6)
+returning id`,
+		pt.UserID, pt.Name, hashToken(pt.Token), powerNames(pt.Scopes), pt.ExpiresAt, pt.CreatedAt,
+	).Scan(&pt.ID)
+}
+
+func findPersonalToken(ctx context.Context, tx *Tx, uid ksuid.KSUID, filter dots.PersonalTokenFilter) (_ []*dots.PersonalToken, n int, err error) {
+	where, args := []string{"user_id = ?"}, []interface{}{uid}
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	replaceQuestionMark(where, args)
+
+	rows, err := tx.QueryContext(
+		ctx, `
+select
+	id, user_id, name, array_to_json(scopes),
+	expires_at, last_used_at, created_at,
+	count(*) over()
+from core.personal_token
+where `+strings.Join(where, " and ")+`
+order by created_at desc `+formatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	tokens := []*dots.PersonalToken{}
+	for rows.Next() {
+		var (
+			pt       dots.PersonalToken
+			pp       string
+			lastUsed sql.NullTime
+		)
+		err := rows.Scan(
+			&pt.ID, &pt.UserID, &pt.Name, &pp,
+			&pt.ExpiresAt, &lastUsed, &pt.CreatedAt,
+			&n,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if err := json.Unmarshal([]byte(pp), &pt.Scopes); err != nil {
+			return nil, 0, err
+		}
+		if lastUsed.Valid {
+			pt.LastUsedAt = &lastUsed.Time
+		}
+
+		tokens = append(tokens, &pt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return tokens, n, nil
+}
+
+// powerNames is how powers are kept in text arrays
+func powerNames(pp []dots.Power) []string {
+	names := make([]string, 0, len(pp))
+	for _, p := range pp {
+		names = append(names, p.String())
+	}
+	return names
+}
@@ -0,0 +1,175 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/innermond/dots"
+	"github.com/segmentio/ksuid"
+)
+
+type PlanService struct {
+	db *DB
+}
+
+func NewPlanService(db *DB) *PlanService {
+	return &PlanService{db: db}
+}
+
+func (s *PlanService) FindUsage(ctx context.Context) (*dots.Usage, error) {
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// counting goes through row level security
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, err
+	}
+
+	tid := dots.UserFromContext(ctx).TenantID()
+	q, err := findQuota(ctx, tx, tid)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &dots.Usage{
+		Plan:      q.Plan,
+		Resources: map[string]dots.UsageItem{},
+		FieldLen:  q.FieldLen,
+	}
+	for _, resource := range dots.Resources {
+		used, err := countResource(ctx, tx, tid, resource)
+		if err != nil {
+			return nil, err
+		}
+		usage.Resources[resource] = dots.UsageItem{Used: used, Limit: q.Limits[resource]}
+	}
+
+	return usage, nil
+}
+
+// findQuota reads the plan of the organisation, that is the package of its
+// first owner with the restrictions of that owner laid over it
+func findQuota(ctx context.Context, tx *Tx, tid ksuid.KSUID) (*dots.Quota, error) {
+	sqlstr := `
+select p.name,
+	coalesce(r.company, p.company),
+	coalesce(r.deed, p.deed),
+	coalesce(r.drain, p.drain),
+	coalesce(r.entry_type, p.entry_type),
+	coalesce(r.entry, p.entry),
+	p.field_len || coalesce(r.field_len, '{}'::jsonb)
+from core.membership m
+join core."user" u on u.id = m.user_id
+join core.package p on p.name = u.package_kind
+left join core.user_restriction r on r.user_id = u.id
+where m.organisation_id = $1 and m.role = $2
+order by m.created_at
+limit 1`
+
+	var (
+		q                                  dots.Quota
+		company, deed, drain, etype, entry int64
+		fieldLen                           []byte
+	)
+	err := tx.QueryRowContext(ctx, sqlstr, tid, dots.RoleOwner).Scan(
+		&q.Plan, &company, &deed, &drain, &etype, &entry, &fieldLen,
+	)
+	if err == sql.ErrNoRows {
+		return nil, dots.Errorf(dots.ENOTFOUND, "plan not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	q.Limits = map[string]int64{
+		dots.ResourceCompany:   company,
+		dots.ResourceDeed:      deed,
+		dots.ResourceDrain:     drain,
+		dots.ResourceEntryType: etype,
+		dots.ResourceEntry:     entry,
+	}
+	q.FieldLen = map[string]int{}
+	if err := json.Unmarshal(fieldLen, &q.FieldLen); err != nil {
+		return nil, fmt.Errorf("postgres.plan: cannot read field_len %w", err)
+	}
+
+	return &q, nil
+}
+
+// countResource counts rows of the tenant, soft deleted ones included
+// as they can be brought back anytime
+func countResource(ctx context.Context, tx *Tx, tid ksuid.KSUID, resource string) (int64, error) {
+	var n int64
+	// resource is one of dots.Resources, never an input
+	err := tx.QueryRowContext(ctx, `select count(*) from core.`+resource+` where tid = $1`, tid).Scan(&n)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// quotaFromContext gives the plan of the caller tenant, nil for those
+// who can do anything as they are not limited
+func quotaFromContext(ctx context.Context, tx *Tx) (*dots.Quota, error) {
+	if dots.CanDoAnything(ctx) == nil {
+		return nil, nil
+	}
+
+	u := dots.UserFromContext(ctx)
+	if u == nil {
+		return nil, dots.Errorf(dots.EUNAUTHORIZED, "unauthorized user")
+	}
+
+	return findQuota(ctx, tx, u.TenantID())
+}
+
+// checkQuota refuses a new row of resource over the plan or with text fields too long
+func checkQuota(ctx context.Context, tx *Tx, resource string, fields map[string]*string) error {
+	q, err := quotaFromContext(ctx, tx)
+	if err != nil || q == nil {
+		return err
+	}
+
+	if err := q.CheckFieldLen(resource, fields); err != nil {
+		return err
+	}
+
+	// creates of a tenant count one after another, the lock is held till the tx ends
+	// so the row is inserted before the next count; one lock for all resources keeps
+	// transfers and counts creating deeds and entries from waiting on each other
+	tid := dots.UserFromContext(ctx).TenantID()
+	if _, err := tx.ExecContext(ctx, `select pg_advisory_xact_lock(hashtext('quota ' || $1))`, tid.String()); err != nil {
+		return err
+	}
+
+	used, err := countResource(ctx, tx, tid, resource)
+	if err != nil {
+		return err
+	}
+	limit := q.Limits[resource]
+	if used >= limit {
+		return dots.Errorf(dots.EQUOTA, "plan %s allows %d %s", q.Plan, limit, resource).
+			WithData(map[string]interface{}{"resource": resource, "limit": limit, "used": used})
+	}
+
+	return nil
+}
+
+// checkFieldLen refuses text fields longer than the plan allows
+func checkFieldLen(ctx context.Context, tx *Tx, resource string, fields map[string]*string) error {
+	q, err := quotaFromContext(ctx, tx)
+	if err != nil || q == nil {
+		return err
+	}
+
+	return q.CheckFieldLen(resource, fields)
+}
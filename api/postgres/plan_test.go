@@ -0,0 +1,47 @@
+package postgres_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/innermond/dots"
+	"github.com/innermond/dots/postgres"
+)
+
+func TestCheckQuota(t *testing.T) {
+	t.Run("Concurrent", func(t *testing.T) {
+		db := MustOpenDB(t, DSN)
+		defer MustCloseDB(t, db)
+
+		// the plan of a new tenant allows a single company
+		ctx, deleteTenant := MustCreateTenant(t, db)
+		defer deleteTenant()
+		s := postgres.NewCompanyService(db)
+
+		var wg sync.WaitGroup
+		errs := make([]error, 5)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				c := &dots.Company{Longname: fmt.Sprintf("COMPANY%d", i), TIN: fmt.Sprintf("TIN%d", i), RN: fmt.Sprintf("RN%d", i)}
+				errs[i] = s.CreateCompany(ctx, c)
+			}(i)
+		}
+		wg.Wait()
+
+		created := 0
+		for _, err := range errs {
+			switch {
+			case err == nil:
+				created++
+			case dots.ErrorCode(err) != dots.EQUOTA:
+				t.Fatalf("err=%v, want %s", err, dots.EQUOTA)
+			}
+		}
+		if created != 1 {
+			t.Fatalf("created %d companies, want 1", created)
+		}
+	})
+}
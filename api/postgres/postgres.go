@@ -84,16 +84,18 @@ func (tx *Tx) getUserIDSetting(ctx context.Context) (*ksuid.KSUID, error) {
 	return uidSetting, nil
 }
 
+// setUserIDPerConnection sets the tenant seen by row level security,
+// that is the active organisation of the user
 func (tx *Tx) setUserIDPerConnection(ctx context.Context) error {
 	u := dots.UserFromContext(ctx)
 	if u.ID.IsNil() {
 		return errors.New("user expected to be found")
 	}
-	_, err := tx.ExecContext(ctx, "SELECT set_config('app.uid', $1::core.ksuid, true)", u.ID)
+	_, err := tx.ExecContext(ctx, "SELECT set_config('app.uid', $1::core.ksuid, true)", u.TenantID())
 	if err != nil {
 		return err
 	}
-	fmt.Printf("set uid per connection %v\n:", u.ID.String())
+	fmt.Printf("set uid per connection %v\n:", u.TenantID().String())
 	return nil
 }
 
@@ -155,13 +157,35 @@ func replaceQuestionMark(where []string, args []interface{}) {
 		}
 
 		// we have value in args
-		v = strings.Replace(v, "?", fmt.Sprintf("$%d", i+1), 1)
+		// numbered by args so conditions without placeholder can go anywhere
+		v = strings.Replace(v, "?", fmt.Sprintf("$%d", args_inx+1), 1)
 		where[i] = v
 		// move index
 		args_inx++
 	}
 }
 
+// trashWhere keeps live rows unless isDeleted asks for the trash,
+// a deletion range alone asks for it too; from is inclusive, to is not.
+// The range goes into where before replaceQuestionMark, state goes after it
+func trashWhere(column string, isDeleted *bool, from, to *dots.PartialTime) (where []string, args []interface{}, state string) {
+	trash := isDeleted != nil && *isDeleted
+	if isDeleted == nil && (from != nil || to != nil) {
+		trash = true
+	}
+	if !trash {
+		return nil, nil, column + " is null"
+	}
+
+	if from != nil {
+		where, args = append(where, column+" >= ?"), append(args, *from)
+	}
+	if to != nil {
+		where, args = append(where, column+" < ?"), append(args, *to)
+	}
+	return where, args, column + " is not null"
+}
+
 func aprox(v float64, numberDecimals int) float64 {
 	num := math.Pow(10, float64(numberDecimals))
 	rounded := math.Round(v*num) / num
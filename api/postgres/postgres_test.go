@@ -1,12 +1,16 @@
 package postgres_test
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"testing"
 
+	"github.com/innermond/dots"
 	"github.com/innermond/dots/postgres"
 	"github.com/joho/godotenv"
+	"github.com/segmentio/ksuid"
+	"github.com/shopspring/decimal"
 )
 
 func TestDB(t *testing.T) {
@@ -39,6 +43,106 @@ func MustCloseDB(t *testing.T, db *postgres.DB) {
 	}
 }
 
+// MustCreateTenant creates a user able to manage its own tenant, it goes away with all it owns
+func MustCreateTenant(t *testing.T, db *postgres.DB) (context.Context, func()) {
+	t.Helper()
+
+	u := &dots.User{Name: "TENANT", Powers: dots.PowerToManageOwn}
+	ctx, _ := MustCreateUser(t, context.Background(), db, u)
+
+	deleteTenant := func() {
+		if err := postgres.NewUserAdminService(db).DeleteUser(AdminContext(), u.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return ctx, deleteTenant
+}
+
+// MustSetPlan puts the tenant of ctx, owned by its user, on the plan
+func MustSetPlan(t *testing.T, ctx context.Context, db *postgres.DB, plan string) {
+	t.Helper()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `update core."user" set package_kind = $2 where id = $1`, dots.UserFromContext(ctx).ID, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// AdminContext acts as an administrator that is not in the database
+func AdminContext() context.Context {
+	return dots.NewContextWithUser(context.Background(), &dots.User{ID: ksuid.New(), Powers: []dots.Power{dots.DoAnything}})
+}
+
+// MustCreateStock gives the tenant of ctx a company and an entry type holding qty in one entry
+func MustCreateStock(t *testing.T, ctx context.Context, db *postgres.DB, qty float64) (companyID, entryTypeID, entryID int) {
+	t.Helper()
+
+	c := &dots.Company{Longname: "COMPANY", TIN: "TIN", RN: "RN"}
+	if err := postgres.NewCompanyService(db).CreateCompany(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	code, unit := "CODE", "pcs"
+	et := &dots.EntryType{Code: &code, Unit: &unit}
+	if err := postgres.NewEntryTypeService(db).CreateEntryType(ctx, et); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &dots.Entry{EntryTypeID: et.ID, Quantity: &qty, CompanyID: &c.ID}
+	if err := postgres.NewEntryService(db).CreateEntry(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+
+	return c.ID, *et.ID, *e.ID
+}
+
+// MustCreateEntry adds qty at unit cost to the entry type of the company
+func MustCreateEntry(t *testing.T, ctx context.Context, db *postgres.DB, cid, etid int, qty float64, unitCost string) int {
+	t.Helper()
+
+	cost := decimal.RequireFromString(unitCost)
+	e := &dots.Entry{EntryTypeID: &etid, Quantity: &qty, CompanyID: &cid, UnitCost: &cost}
+	if err := postgres.NewEntryService(db).CreateEntry(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	return *e.ID
+}
+
+// MustCreateDeed creates a deed of the company that drains nothing yet
+func MustCreateDeed(t *testing.T, ctx context.Context, db *postgres.DB, cid int) int {
+	t.Helper()
+
+	d := newDeed(cid, "DEED", 1, nil)
+	if err := postgres.NewDeedService(db).CreateDeed(ctx, &d); err != nil {
+		t.Fatal(err)
+	}
+	return *d.ID
+}
+
+// MustFindStock tells what is left of the entry type in the company
+func MustFindStock(t *testing.T, ctx context.Context, db *postgres.DB, cid, etid int) float64 {
+	t.Helper()
+
+	ss, _, err := postgres.NewEntryService(db).FindStock(ctx, dots.StockFilter{CompanyID: &cid, EntryTypeID: &etid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ss) == 0 {
+		return 0
+	}
+	return ss[0].Quantity
+}
+
 var DSN string
 
 func init() {
@@ -0,0 +1,207 @@
+package postgres
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/innermond/dots"
+	"github.com/shopspring/decimal"
+)
+
+type ProfitService struct {
+	db *DB
+}
+
+func NewProfitService(db *DB) *ProfitService {
+	return &ProfitService{db: db}
+}
+
+func (s *ProfitService) FindDeedProfit(ctx context.Context, id int, filter dots.DeedCostFilter) (*dots.DeedProfit, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, err
+	}
+
+	dd, err := findDeedProfit(ctx, tx, []string{"dd.id = $1"}, []interface{}{id}, filter.Method)
+	if err != nil {
+		return nil, err
+	}
+	if len(dd) == 0 {
+		return nil, dots.Errorf(dots.ENOTFOUND, "deed not found")
+	}
+
+	return dd[0], nil
+}
+
+func (s *ProfitService) FindProfitReport(ctx context.Context, filter dots.ProfitFilter) (*dots.ProfitReport, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, err
+	}
+
+	return findProfitReport(ctx, tx, filter)
+}
+
+func findProfitReport(ctx context.Context, tx *Tx, filter dots.ProfitFilter) (*dots.ProfitReport, error) {
+	where, args := []string{}, []interface{}{}
+	if v := filter.CompanyID; v != nil {
+		where, args = append(where, "dd.company_id = ?"), append(args, *v)
+	}
+	if v := filter.From; v != nil {
+		where, args = append(where, "dd.created_at >= ?"), append(args, *v)
+	}
+	if v := filter.To; v != nil {
+		where, args = append(where, "dd.created_at < ?"), append(args, *v)
+	}
+	replaceQuestionMark(where, args)
+	// transfers and inventory counts move stock, they sell nothing
+	where = append(where,
+		"dd.deleted_at is null",
+		"not exists(select 1 from core.transfer t where t.deed_id = dd.id)",
+		"not exists(select 1 from core.inventory_count_line l where l.deed_id = dd.id)",
+	)
+
+	dd, err := findDeedProfit(ctx, tx, where, args, filter.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &dots.ProfitReport{
+		Method:    filter.Method,
+		Deeds:     dd,
+		Companies: []*dots.CompanyProfit{},
+		Months:    []*dots.MonthProfit{},
+		Total:     dots.Profit{Revenue: decimal.Zero, Cost: decimal.Zero},
+	}
+	if v := filter.From; v != nil {
+		from := time.Time(*v)
+		report.From = &from
+	}
+	if v := filter.To; v != nil {
+		to := time.Time(*v)
+		report.To = &to
+	}
+
+	companies := map[int]*dots.CompanyProfit{}
+	months := map[string]*dots.MonthProfit{}
+	for _, d := range dd {
+		c, found := companies[d.CompanyID]
+		if !found {
+			c = &dots.CompanyProfit{CompanyID: d.CompanyID, Profit: dots.Profit{Revenue: decimal.Zero, Cost: decimal.Zero}}
+			companies[d.CompanyID] = c
+			report.Companies = append(report.Companies, c)
+		}
+		c.Add(d.Profit)
+
+		month := d.CreatedAt.UTC().Format("2006-01")
+		m, found := months[month]
+		if !found {
+			m = &dots.MonthProfit{Month: month, Profit: dots.Profit{Revenue: decimal.Zero, Cost: decimal.Zero}}
+			months[month] = m
+			report.Months = append(report.Months, m)
+		}
+		m.Add(d.Profit)
+
+		report.Total.Add(d.Profit)
+	}
+	report.Total.Settle()
+
+	sort.Slice(report.Companies, func(i, j int) bool {
+		return report.Companies[i].CompanyID < report.Companies[j].CompanyID
+	})
+	sort.Slice(report.Months, func(i, j int) bool {
+		return report.Months[i].Month < report.Months[j].Month
+	})
+
+	return report, nil
+}
+
+// findDeedProfit reads deeds, dd, meeting where, with where already numbered,
+// and sets their revenue against the cost of their drains
+func findDeedProfit(ctx context.Context, tx *Tx, where []string, args []interface{}, method string) ([]*dots.DeedProfit, error) {
+	rows, err := tx.QueryContext(ctx, `
+select dd.id, dd.company_id, dd.title, dd.created_at, dd.quantity, coalesce(dd.unitprice, 0)
+from core.deed dd
+where `+strings.Join(where, " and ")+`
+order by dd.created_at, dd.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dd := []*dots.DeedProfit{}
+	for rows.Next() {
+		var (
+			d         dots.DeedProfit
+			companyID *int
+			quantity  float64
+			unitprice decimal.Decimal
+		)
+		if err := rows.Scan(&d.DeedID, &companyID, &d.Title, &d.CreatedAt, &quantity, &unitprice); err != nil {
+			return nil, err
+		}
+		if companyID != nil {
+			d.CompanyID = *companyID
+		}
+		d.Method = method
+		d.Revenue = unitprice.Mul(decimal.NewFromFloat(quantity)).Round(2)
+		d.Cost = decimal.Zero
+		dd = append(dd, &d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(dd) == 0 {
+		return dd, nil
+	}
+
+	pools, err := findDeedPools(ctx, tx, where, args...)
+	if err != nil {
+		return nil, err
+	}
+	costs, err := costDrains(ctx, tx, pools, method)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range dd {
+		for _, c := range costs[d.DeedID] {
+			d.Cost = d.Cost.Add(c.Cost)
+			d.Uncosted += c.Uncosted
+		}
+		d.Uncosted = aprox(d.Uncosted, 5)
+		d.Settle()
+	}
+
+	return dd, nil
+}
@@ -0,0 +1,52 @@
+package postgres_test
+
+import (
+	"testing"
+
+	"github.com/innermond/dots"
+	"github.com/innermond/dots/postgres"
+	"github.com/shopspring/decimal"
+)
+
+func TestProfitService(t *testing.T) {
+	db := MustOpenDB(t, DSN)
+	defer MustCloseDB(t, db)
+
+	ctx, deleteTenant := MustCreateTenant(t, db)
+	defer deleteTenant()
+	cid, etid, deedID := mustCreateSale(t, ctx, db)
+	s := postgres.NewProfitService(db)
+
+	t.Run("Deed", func(t *testing.T) {
+		dp, err := s.FindDeedProfit(ctx, deedID, dots.DeedCostFilter{Method: dots.ValuationLIFO})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !dp.Revenue.Equal(decimal.RequireFromString("42")) || !dp.Cost.Equal(decimal.RequireFromString("44")) || !dp.Margin.Equal(decimal.RequireFromString("-2")) {
+			t.Fatalf("profit=%+v, want 42 against 44", dp.Profit)
+		}
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		// a write-off drains stock but sells nothing
+		mustApproveCount(t, ctx, db, cid, etid, 7, dots.ReasonDamage)
+
+		report, err := s.FindProfitReport(ctx, dots.ProfitFilter{CompanyID: &cid})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Deeds) != 1 || report.Deeds[0].DeedID != deedID {
+			t.Fatalf("deeds=%+v, want the sale alone", report.Deeds)
+		}
+		total := report.Total
+		if !total.Revenue.Equal(decimal.RequireFromString("42")) || !total.Cost.Equal(decimal.RequireFromString("28")) || !total.Margin.Equal(decimal.RequireFromString("14")) {
+			t.Fatalf("total=%+v, want 42 against 28", total)
+		}
+		if total.MarginPercent == nil || !total.MarginPercent.Equal(decimal.RequireFromString("33.33")) {
+			t.Fatalf("margin percent=%v, want 33.33", total.MarginPercent)
+		}
+		if len(report.Companies) != 1 || len(report.Months) != 1 {
+			t.Fatalf("companies=%d months=%d, want 1 and 1", len(report.Companies), len(report.Months))
+		}
+	})
+}
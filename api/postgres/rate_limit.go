@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/innermond/dots"
+)
+
+// RateLimitService keeps the token buckets in the database
+// so every api server counts against the same ones
+type RateLimitService struct {
+	db *DB
+
+	mu    sync.Mutex
+	swept time.Time
+}
+
+func NewRateLimitService(db *DB) *RateLimitService {
+	return &RateLimitService{db: db}
+}
+
+func (s *RateLimitService) Allow(ctx context.Context, key string, limit dots.RateLimit) (bool, time.Duration, error) {
+	if err := limit.Validate(); err != nil {
+		return false, 0, err
+	}
+
+	if err := s.sweep(ctx); err != nil {
+		return false, 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	defer tx.Rollback()
+
+	// a new bucket starts full
+	_, err = tx.ExecContext(
+		ctx,
+		`insert into core.rate_limit (key, tokens, updated_at) values ($1, $2, clock_timestamp())
+on conflict (key) do nothing`,
+		key, limit.Burst,
+	)
+	if err != nil {
+		return false, 0, err
+	}
+
+	// the row lock makes concurrent servers wait their turn,
+	// elapsed comes from the database clock shared by all of them
+	var tokens, elapsed float64
+	err = tx.QueryRowContext(
+		ctx,
+		`select tokens, extract(epoch from clock_timestamp() - updated_at)
+from core.rate_limit where key = $1 for update`,
+		key,
+	).Scan(&tokens, &elapsed)
+	if err != nil {
+		return false, 0, err
+	}
+
+	tokens, ok, retry := limit.Take(tokens, time.Duration(elapsed*float64(time.Second)))
+
+	_, err = tx.ExecContext(
+		ctx,
+		`update core.rate_limit set tokens = $2, updated_at = clock_timestamp(), full_at = clock_timestamp() + $3 * interval '1 second' where key = $1`,
+		key, tokens, limit.FullIn(tokens).Seconds(),
+	)
+	if err != nil {
+		return false, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, 0, err
+	}
+
+	return ok, retry, nil
+}
+
+// sweep forgets full buckets once a minute, a new one starts as full as them
+func (s *RateLimitService) sweep(ctx context.Context) error {
+	s.mu.Lock()
+	now := time.Now()
+	if now.Sub(s.swept) < time.Minute {
+		s.mu.Unlock()
+		return nil
+	}
+	s.swept = now
+	s.mu.Unlock()
+
+	_, err := s.db.db.ExecContext(ctx, `delete from core.rate_limit where full_at < clock_timestamp()`)
+	return err
+}
@@ -0,0 +1,583 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/innermond/dots"
+)
+
+type ReservationService struct {
+	db *DB
+}
+
+func NewReservationService(db *DB) *ReservationService {
+	return &ReservationService{db: db}
+}
+
+func (s *ReservationService) CreateReservation(ctx context.Context, r *dots.Reservation) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanCreateOwn(ctx); canerr != nil {
+		return canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return err
+	}
+
+	if err := createReservation(ctx, tx, r); err != nil {
+		return perr(err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *ReservationService) FindReservation(ctx context.Context, filter dots.ReservationFilter) ([]*dots.Reservation, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, 0, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	return findReservation(ctx, tx, filter)
+}
+
+func (s *ReservationService) DeleteReservation(ctx context.Context, id int) (int, error) {
+	if canerr := dots.CanDeleteOwn(ctx); canerr != nil {
+		return 0, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, `delete from core.reservation where id = $1`, id)
+	if err != nil {
+		return 0, fmt.Errorf("postgres.reservation: cannot delete %w", err)
+	}
+	n64, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n64 == 0 {
+		return 0, dots.Errorf(dots.ENOTFOUND, "reservation not found")
+	}
+
+	return int(n64), tx.Commit()
+}
+
+func (s *ReservationService) ConfirmReservation(ctx context.Context, deedID int) (*dots.ReservationConfirmed, error) {
+	if canerr := dots.CanWriteOwn(ctx); canerr != nil {
+		return nil, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, err
+	}
+
+	confirmed, err := confirmReservation(ctx, tx, deedID)
+	if err != nil {
+		return nil, perr(err)
+	}
+
+	return confirmed, tx.Commit()
+}
+
+func (s *ReservationService) CancelReservation(ctx context.Context, deedID int) (int, error) {
+	if canerr := dots.CanWriteOwn(ctx); canerr != nil {
+		return 0, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return 0, err
+	}
+
+	if _, err := reservingDeedCompany(ctx, tx, deedID); err != nil {
+		return 0, err
+	}
+
+	n, err := releaseReservations(ctx, tx, deedID)
+	if err != nil {
+		return 0, err
+	}
+
+	return n, tx.Commit()
+}
+
+// reservingDeedCompany tells the company of a live deed that can hold stock
+func reservingDeedCompany(ctx context.Context, tx *Tx, deedID int) (int, error) {
+	var cid int
+	err := tx.QueryRowContext(ctx, `select company_id from core.deed where id = $1 and deleted_at is null`, deedID).Scan(&cid)
+	if err == sql.ErrNoRows {
+		return 0, dots.Errorf(dots.ENOTFOUND, "deed not found")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := deedNotOfDocument(ctx, tx, deedID); err != nil {
+		return 0, err
+	}
+
+	return cid, nil
+}
+
+func createReservation(ctx context.Context, tx *Tx, r *dots.Reservation) error {
+	cid, err := reservingDeedCompany(ctx, tx, *r.DeedID)
+	if err != nil {
+		return err
+	}
+	r.CompanyID = cid
+
+	qty := *r.Quantity
+	needmore := map[string]interface{}{}
+	if v := r.EntryID; v != nil {
+		// the entry is locked as drains lock it, so that holds and drains take turns
+		var etid int
+		err := tx.QueryRowContext(
+			ctx,
+			`select entry_type_id from core.entry where id = $1 and company_id = $2 and deleted_at is null for update`,
+			*v, cid,
+		).Scan(&etid)
+		if err == sql.ErrNoRows {
+			return dots.Errorf(dots.ENOTFOUND, "entry %d not found in company %d", *v, cid)
+		}
+		if err != nil {
+			return err
+		}
+
+		free, err := quantityByEntries(ctx, tx, []int{*v}, cid)
+		if err != nil {
+			return err
+		}
+		if diff := aprox(qty-free[*v], 5); diff > 0 {
+			needmore["entry"] = map[int]float64{*v: diff}
+		}
+		// an entry held is held out of its entry type as well
+		freeByType, err := quantityByEntryTypes(ctx, tx, []int{etid}, cid, true)
+		if err != nil {
+			return err
+		}
+		if diff := aprox(qty-freeByType[etid], 5); diff > 0 {
+			needmore["entry_type"] = map[int]float64{etid: diff}
+		}
+	}
+	if v := r.EntryTypeID; v != nil {
+		// the entries of the entry type are locked in the order drains lock them,
+		// so that holds of the entry type and drains take turns
+		_, err := tx.ExecContext(
+			ctx,
+			`select id from core.entry where company_id = $1 and entry_type_id = $2 and deleted_at is null order by id for update`,
+			cid, *v,
+		)
+		if err != nil {
+			return err
+		}
+
+		// expired entries are not distributed, they cannot be held either
+		free, err := quantityByEntryTypes(ctx, tx, []int{*v}, cid, false)
+		if err == sql.ErrNoRows {
+			return dots.Errorf(dots.ENOTFOUND, "no entries of entry type %d in company %d", *v, cid)
+		}
+		if err != nil {
+			return err
+		}
+		if diff := aprox(qty-free[*v], 5); diff > 0 {
+			needmore["entry_type"] = map[int]float64{*v: diff}
+		}
+	}
+	if len(needmore) > 0 {
+		return dots.Errorf(dots.EINVALID, "not enough quantity").WithData(map[string]interface{}{"needmore": needmore})
+	}
+
+	return tx.QueryRowContext(
+		ctx, `
+insert into core.reservation
+(deed_id, company_id, entry_id, entry_type_id, quantity, expires_at)
+values
+($1, $2, $3, $4, $5, $6) returning id, created_at`,
+		*r.DeedID, cid, r.EntryID, r.EntryTypeID, qty, *r.ExpiresAt,
+	).Scan(&r.ID, &r.CreatedAt)
+}
+
+func findReservation(ctx context.Context, tx *Tx, filter dots.ReservationFilter) (_ []*dots.Reservation, n int, err error) {
+	where, args := []string{}, []interface{}{}
+	if v := filter.ID; v != nil {
+		where, args = append(where, "r.id = ?"), append(args, *v)
+	}
+	if v := filter.DeedID; v != nil {
+		where, args = append(where, "r.deed_id = ?"), append(args, *v)
+	}
+	if v := filter.CompanyID; v != nil {
+		where, args = append(where, "r.company_id = ?"), append(args, *v)
+	}
+	if v := filter.EntryID; v != nil {
+		where, args = append(where, "r.entry_id = ?"), append(args, *v)
+	}
+	if v := filter.EntryTypeID; v != nil {
+		where, args = append(where, "r.entry_type_id = ?"), append(args, *v)
+	}
+	if v := filter.IsExpired; v != nil {
+		where, args = append(where, "(r.expires_at <= now()) = ?"), append(args, *v)
+	}
+
+	wherestr := ""
+	if len(where) > 0 {
+		replaceQuestionMark(where, args)
+		wherestr = "where " + strings.Join(where, " and ")
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+select r.id, r.deed_id, r.company_id, r.entry_id, r.entry_type_id, r.quantity, r.expires_at, r.created_at, r.expires_at <= now(), count(*) over()
+from core.reservation r
+`+wherestr+`
+order by r.expires_at, r.id `+formatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	rr := []*dots.Reservation{}
+	for rows.Next() {
+		var r dots.Reservation
+		err := rows.Scan(&r.ID, &r.DeedID, &r.CompanyID, &r.EntryID, &r.EntryTypeID, &r.Quantity, &r.ExpiresAt, &r.CreatedAt, &r.Expired, &n)
+		if err != nil {
+			return nil, 0, err
+		}
+		rr = append(rr, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return rr, n, nil
+}
+
+// confirmReservation drains what a deed holds: held entries first, then the entry types,
+// distributed with the strategy of the company. Expired reservations are left as they are
+func confirmReservation(ctx context.Context, tx *Tx, deedID int) (*dots.ReservationConfirmed, error) {
+	cid, err := reservingDeedCompany(ctx, tx, deedID)
+	if err != nil {
+		return nil, err
+	}
+
+	rr, err := liveReservations(ctx, tx, `r.deed_id = $1`, deedID)
+	if err != nil {
+		return nil, err
+	}
+
+	byEntry, byEntryType := dots.Held(rr)
+	if len(byEntry) == 0 && len(byEntryType) == 0 {
+		return nil, dots.Errorf(dots.ENOTFOUND, "deed %d holds nothing", deedID)
+	}
+
+	// what the deed holds does not stand in the way of its own drains
+	ids := make([]int, 0, len(rr))
+	for _, r := range rr {
+		ids = append(ids, r.ID)
+	}
+	if _, err := tx.ExecContext(ctx, `delete from core.reservation where id = any($1)`, ids); err != nil {
+		return nil, fmt.Errorf("postgres.reservation: cannot release %w", err)
+	}
+
+	confirmed := &dots.ReservationConfirmed{DeedID: deedID, Distribute: map[int]float64{}}
+	if len(byEntry) > 0 {
+		if err := drainOnTop(ctx, tx, deedID, byEntry); err != nil {
+			return nil, err
+		}
+		for eid, qty := range byEntry {
+			confirmed.Distribute[eid] += qty
+		}
+	}
+
+	if len(byEntryType) > 0 {
+		strategy, err := distributeStrategy(ctx, tx, cid, nil, dots.DistributeNewMany)
+		if err != nil {
+			return nil, err
+		}
+		distribute, err := tryDistributeOverEntryType(ctx, tx, byEntryType, cid, strategy)
+		if err != nil {
+			return nil, err
+		}
+		if err := drainOnTop(ctx, tx, deedID, distribute); err != nil {
+			return nil, err
+		}
+		for eid, qty := range distribute {
+			confirmed.Distribute[eid] = aprox(confirmed.Distribute[eid]+qty, 5)
+		}
+	}
+
+	return confirmed, nil
+}
+
+// drainOnTop adds the quantities to what the deed already drains of the entries
+func drainOnTop(ctx context.Context, tx *Tx, deedID int, distribute map[int]float64) error {
+	rows, err := tx.QueryContext(
+		ctx,
+		`select entry_id, quantity from core.drain where deed_id = $1 and entry_id = any($2) and is_deleted = false`,
+		deedID, keysOf(distribute),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	total := make(map[int]float64, len(distribute))
+	for eid, qty := range distribute {
+		total[eid] = qty
+	}
+	for rows.Next() {
+		var (
+			eid  int
+			prev float64
+		)
+		if err := rows.Scan(&eid, &prev); err != nil {
+			return err
+		}
+		total[eid] = aprox(total[eid]+prev, 5)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	return drainInOrder(ctx, tx, deedID, total, nil)
+}
+
+// liveReservations reads and locks the reservations still holding that match where
+func liveReservations(ctx context.Context, tx *Tx, where string, args ...interface{}) ([]*dots.Reservation, error) {
+	rows, err := tx.QueryContext(ctx, `
+select r.id, r.deed_id, r.company_id, r.entry_id, r.entry_type_id, r.quantity, r.expires_at, r.created_at
+from core.reservation r
+where `+where+` and r.expires_at > now()
+order by r.id
+for update`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rr := []*dots.Reservation{}
+	for rows.Next() {
+		var r dots.Reservation
+		if err := rows.Scan(&r.ID, &r.DeedID, &r.CompanyID, &r.EntryID, &r.EntryTypeID, &r.Quantity, &r.ExpiresAt, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		rr = append(rr, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rr, nil
+}
+
+// giveUpEntryHolds shrinks the reservations of the entries about to be written off
+// to what the entries keep, so the write off is not stopped by them
+func giveUpEntryHolds(ctx context.Context, tx *Tx, distribute map[int]float64) error {
+	eids := keysOf(distribute)
+	rr, err := liveReservations(ctx, tx, `r.entry_id = any($1)`, eids)
+	if err != nil {
+		return err
+	}
+	if len(rr) == 0 {
+		return nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `select e.id, `+physicalStock+` from entry_with_quantity_drained e where e.id = any($1)`, eids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	keeps := map[int]float64{}
+	for rows.Next() {
+		var (
+			eid  int
+			left float64
+		)
+		if err := rows.Scan(&eid, &left); err != nil {
+			return err
+		}
+		keeps[eid] = left - distribute[eid]
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	byEntry := map[int][]*dots.Reservation{}
+	for _, r := range rr {
+		byEntry[*r.EntryID] = append(byEntry[*r.EntryID], r)
+	}
+	for eid, held := range byEntry {
+		sum, _ := dots.Held(held)
+		if over := aprox(sum[eid]-keeps[eid], 5); over > 0 {
+			if err := shrinkReservations(ctx, tx, held, over); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// giveUpEntryTypeHolds shrinks the reservations of an entry type of a company to
+// what its usable entries have free once the write off about to be drained takes its part
+func giveUpEntryTypeHolds(ctx context.Context, tx *Tx, etid, cid int, distribute map[int]float64) error {
+	rr, err := liveReservations(ctx, tx, `r.entry_type_id = $1 and r.company_id = $2`, etid, cid)
+	if err != nil {
+		return err
+	}
+	if len(rr) == 0 {
+		return nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+select e.id, e.quantity_initial - e.quantity_drained - e.quantity_reserved
+from entry_with_quantity_drained e
+where e.entry_type_id = $1 and e.company_id = $2
+and `+usable(false), etid, cid)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var free float64
+	for rows.Next() {
+		var (
+			eid  int
+			left float64
+		)
+		if err := rows.Scan(&eid, &left); err != nil {
+			return err
+		}
+		free += left - distribute[eid]
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, held := dots.Held(rr)
+	if over := aprox(held[etid]-free, 5); over > 0 {
+		return shrinkReservations(ctx, tx, rr, over)
+	}
+
+	return nil
+}
+
+// shrinkReservations takes over out of the reservations, letting go the ones left holding nothing
+func shrinkReservations(ctx context.Context, tx *Tx, rr []*dots.Reservation, over float64) error {
+	for id, keep := range dots.GiveUp(rr, over) {
+		var err error
+		if keep = aprox(keep, 5); keep > 0 {
+			_, err = tx.ExecContext(ctx, `update core.reservation set quantity = $2 where id = $1`, id, keep)
+		} else {
+			_, err = tx.ExecContext(ctx, `delete from core.reservation where id = $1`, id)
+		}
+		if err != nil {
+			return fmt.Errorf("postgres.reservation: cannot give up %w", err)
+		}
+	}
+
+	return nil
+}
+
+// releaseReservations lets go all a deed holds
+func releaseReservations(ctx context.Context, tx *Tx, deedID int) (int, error) {
+	result, err := tx.ExecContext(ctx, `delete from core.reservation where deed_id = $1`, deedID)
+	if err != nil {
+		return 0, fmt.Errorf("postgres.reservation: cannot release %w", err)
+	}
+	n64, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n64), nil
+}
+
+// entryTypesNotHeld refuses to drain entries when that takes what reservations
+// hold of their entry types
+func entryTypesNotHeld(ctx context.Context, tx *Tx, eq map[int]float64, cid int) error {
+	rows, err := tx.QueryContext(ctx, `select id, entry_type_id from core.entry where id = any($1)`, keysOf(eq))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	wanted := map[int]float64{}
+	for rows.Next() {
+		var eid, etid int
+		if err := rows.Scan(&eid, &etid); err != nil {
+			return err
+		}
+		wanted[etid] += eq[eid]
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	if len(wanted) == 0 {
+		return nil
+	}
+
+	free, err := quantityByEntryTypes(ctx, tx, keysOf(wanted), cid, true)
+	if err != nil {
+		return err
+	}
+
+	needmore := map[int]float64{}
+	for etid, qty := range wanted {
+		if diff := aprox(qty-free[etid], 5); diff > 0 {
+			needmore[etid] = diff
+		}
+	}
+	if len(needmore) > 0 {
+		return dots.Errorf(dots.EINVALID, "not enough quantity, reservations hold it").WithData(map[string]interface{}{"needmore_entry_type": needmore})
+	}
+
+	return nil
+}
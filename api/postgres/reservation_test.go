@@ -0,0 +1,120 @@
+package postgres_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/innermond/dots"
+	"github.com/innermond/dots/postgres"
+)
+
+func TestReservationService(t *testing.T) {
+	t.Run("Confirm", func(t *testing.T) {
+		db := MustOpenDB(t, DSN)
+		defer MustCloseDB(t, db)
+
+		ctx, deleteTenant := MustCreateTenant(t, db)
+		defer deleteTenant()
+		cid, etid, eid := MustCreateStock(t, ctx, db, 10)
+		s := postgres.NewReservationService(db)
+
+		deedID := MustCreateDeed(t, ctx, db, cid)
+		MustCreateReservation(t, ctx, s, deedID, etid, 4, nil)
+
+		// what is held cannot be held again
+		other := MustCreateDeed(t, ctx, db, cid)
+		qty := 7.0
+		err := s.CreateReservation(ctx, &dots.Reservation{DeedID: &other, EntryTypeID: &etid, Quantity: &qty})
+		if dots.ErrorCode(err) != dots.EINVALID {
+			t.Fatalf("err=%v, want %s", err, dots.EINVALID)
+		}
+
+		confirmed, err := s.ConfirmReservation(ctx, deedID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if confirmed.Distribute[eid] != 4 {
+			t.Fatalf("distribute=%v, want 4 of entry %d", confirmed.Distribute, eid)
+		}
+
+		dd, _, err := postgres.NewDrainService(db).FindDrain(ctx, dots.DrainFilter{DeedID: &deedID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dd) != 1 || dd[0].EntryID != eid || dd[0].Quantity != 4 {
+			t.Fatalf("drains=%+v", dd)
+		}
+		if _, n, err := s.FindReservation(ctx, dots.ReservationFilter{DeedID: &deedID}); err != nil || n != 0 {
+			t.Fatalf("n=%d, err=%v, want the reservation released", n, err)
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		db := MustOpenDB(t, DSN)
+		defer MustCloseDB(t, db)
+
+		ctx, deleteTenant := MustCreateTenant(t, db)
+		defer deleteTenant()
+		cid, etid, _ := MustCreateStock(t, ctx, db, 10)
+		s := postgres.NewReservationService(db)
+
+		deedID := MustCreateDeed(t, ctx, db, cid)
+		MustCreateReservation(t, ctx, s, deedID, etid, 4, nil)
+
+		n, err := s.CancelReservation(ctx, deedID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Fatalf("released %d, want 1", n)
+		}
+
+		// all of it is free again
+		MustCreateReservation(t, ctx, s, MustCreateDeed(t, ctx, db, cid), etid, 10, nil)
+		if _, err := s.ConfirmReservation(ctx, deedID); dots.ErrorCode(err) != dots.ENOTFOUND {
+			t.Fatalf("err=%v, want %s", err, dots.ENOTFOUND)
+		}
+	})
+
+	t.Run("Expire", func(t *testing.T) {
+		db := MustOpenDB(t, DSN)
+		defer MustCloseDB(t, db)
+
+		ctx, deleteTenant := MustCreateTenant(t, db)
+		defer deleteTenant()
+		cid, etid, _ := MustCreateStock(t, ctx, db, 10)
+		s := postgres.NewReservationService(db)
+
+		deedID := MustCreateDeed(t, ctx, db, cid)
+		expiresAt := time.Now().Add(2 * time.Second)
+		MustCreateReservation(t, ctx, s, deedID, etid, 10, &expiresAt)
+		time.Sleep(time.Until(expiresAt) + time.Second)
+
+		expired := true
+		rr, _, err := s.FindReservation(ctx, dots.ReservationFilter{DeedID: &deedID, IsExpired: &expired})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rr) != 1 || !rr[0].Expired {
+			t.Fatalf("reservations=%+v, want one expired", rr)
+		}
+
+		// an expired reservation holds nothing and confirms nothing
+		MustCreateReservation(t, ctx, s, MustCreateDeed(t, ctx, db, cid), etid, 10, nil)
+		if _, err := s.ConfirmReservation(ctx, deedID); dots.ErrorCode(err) != dots.ENOTFOUND {
+			t.Fatalf("err=%v, want %s", err, dots.ENOTFOUND)
+		}
+	})
+}
+
+// MustCreateReservation holds qty of the entry type for the deed
+func MustCreateReservation(t *testing.T, ctx context.Context, s *postgres.ReservationService, deedID, etid int, qty float64, expiresAt *time.Time) *dots.Reservation {
+	t.Helper()
+
+	r := &dots.Reservation{DeedID: &deedID, EntryTypeID: &etid, Quantity: &qty, ExpiresAt: expiresAt}
+	if err := s.CreateReservation(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
@@ -0,0 +1,358 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/innermond/dots"
+)
+
+type StockThresholdService struct {
+	db *DB
+}
+
+func NewStockThresholdService(db *DB) *StockThresholdService {
+	return &StockThresholdService{db: db}
+}
+
+func (s *StockThresholdService) CreateStockThreshold(ctx context.Context, st *dots.StockThreshold) error {
+	if err := st.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanCreateOwn(ctx); canerr != nil {
+		return canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return err
+	}
+
+	if err := thresholdTargetsOwn(ctx, tx, *st.EntryTypeID, st.CompanyID); err != nil {
+		return err
+	}
+
+	if err := createStockThreshold(ctx, tx, st); err != nil {
+		return perr(err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *StockThresholdService) UpdateStockThreshold(ctx context.Context, id int, upd dots.StockThresholdUpdate) (*dots.StockThreshold, error) {
+	if err := upd.Validate(); err != nil {
+		return nil, err
+	}
+
+	if canerr := dots.CanWriteOwn(ctx); canerr != nil {
+		return nil, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, err
+	}
+
+	st, err := updateStockThreshold(ctx, tx, id, upd)
+	if err != nil {
+		return nil, err
+	}
+
+	return st, tx.Commit()
+}
+
+func (s *StockThresholdService) FindStockThreshold(ctx context.Context, filter dots.StockThresholdFilter) ([]*dots.StockThreshold, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, 0, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	return findStockThreshold(ctx, tx, filter)
+}
+
+func (s *StockThresholdService) DeleteStockThreshold(ctx context.Context, id int) (int, error) {
+	if canerr := dots.CanDeleteOwn(ctx); canerr != nil {
+		return 0, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, `delete from core.stock_threshold where id = $1`, id)
+	if err != nil {
+		return 0, fmt.Errorf("postgres.stock threshold: cannot delete %w", err)
+	}
+	n64, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n64 == 0 {
+		return 0, dots.Errorf(dots.ENOTFOUND, "stock threshold not found")
+	}
+
+	return int(n64), tx.Commit()
+}
+
+func (s *StockThresholdService) FindStockAlert(ctx context.Context, filter dots.StockAlertFilter) ([]*dots.StockAlert, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, 0, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	return findStockAlert(ctx, tx, filter)
+}
+
+// thresholdTargetsOwn checks the entry type and the company are alive ones of the caller
+func thresholdTargetsOwn(ctx context.Context, tx *Tx, entryTypeID int, companyID *int) error {
+	var found bool
+	err := tx.QueryRowContext(
+		ctx, `
+select exists(select 1 from api.entry_type where id = $1)
+and ($2::integer is null or exists(select 1 from api.company where id = $2))`,
+		entryTypeID, companyID,
+	).Scan(&found)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return dots.Errorf(dots.ENOTFOUND, "entry type or company not found")
+	}
+
+	return nil
+}
+
+func createStockThreshold(ctx context.Context, tx *Tx, st *dots.StockThreshold) error {
+	return tx.QueryRowContext(
+		ctx, `
+insert into core.stock_threshold
+(entry_type_id, company_id, reorder_level, target_level)
+values
+($1, $2, $3, $4) returning id`,
+		st.EntryTypeID, st.CompanyID, st.ReorderLevel, st.TargetLevel,
+	).Scan(&st.ID)
+}
+
+func updateStockThreshold(ctx context.Context, tx *Tx, id int, upd dots.StockThresholdUpdate) (*dots.StockThreshold, error) {
+	tt, _, err := findStockThreshold(ctx, tx, dots.StockThresholdFilter{ID: &id, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(tt) == 0 {
+		return nil, dots.Errorf(dots.ENOTFOUND, "stock threshold not found")
+	}
+	st := tt[0]
+
+	if v := upd.ReorderLevel; v != nil {
+		st.ReorderLevel = v
+	}
+	if v := upd.TargetLevel; v != nil {
+		st.TargetLevel = v
+	}
+	if err := st.Validate(); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`update core.stock_threshold set reorder_level = $2, target_level = $3 where id = $1`,
+		id, *st.ReorderLevel, *st.TargetLevel,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("postgres.stock threshold: cannot update %w", err)
+	}
+
+	return st, nil
+}
+
+func findStockThreshold(ctx context.Context, tx *Tx, filter dots.StockThresholdFilter) (_ []*dots.StockThreshold, n int, err error) {
+	where, args := []string{}, []interface{}{}
+	if v := filter.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := filter.EntryTypeID; v != nil {
+		where, args = append(where, "entry_type_id = ?"), append(args, *v)
+	}
+	if v := filter.CompanyID; v != nil {
+		where, args = append(where, "company_id = ?"), append(args, *v)
+	}
+
+	wherestr := ""
+	if len(where) > 0 {
+		replaceQuestionMark(where, args)
+		wherestr = "where " + strings.Join(where, " and ")
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+select id, entry_type_id, company_id, reorder_level, target_level, count(*) over()
+from core.stock_threshold
+`+wherestr+`
+order by entry_type_id, company_id nulls first, id `+formatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	tt := []*dots.StockThreshold{}
+	for rows.Next() {
+		var st dots.StockThreshold
+		if err := rows.Scan(&st.ID, &st.EntryTypeID, &st.CompanyID, &st.ReorderLevel, &st.TargetLevel, &n); err != nil {
+			return nil, 0, err
+		}
+		tt = append(tt, &st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return tt, n, nil
+}
+
+func findStockAlert(ctx context.Context, tx *Tx, filter dots.StockAlertFilter) (_ []*dots.StockAlert, n int, err error) {
+	where, args := []string{}, []interface{}{}
+	if v := filter.CompanyID; v != nil {
+		where, args = append(where, "company_id = ?"), append(args, *v)
+	}
+	if v := filter.EntryTypeID; v != nil {
+		where, args = append(where, "entry_type_id = ?"), append(args, *v)
+	}
+	if v := filter.From; v != nil {
+		where, args = append(where, "created_at >= ?"), append(args, *v)
+	}
+	if v := filter.To; v != nil {
+		where, args = append(where, "created_at < ?"), append(args, *v)
+	}
+
+	wherestr := ""
+	if len(where) > 0 {
+		replaceQuestionMark(where, args)
+		wherestr = "where " + strings.Join(where, " and ")
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+select id, company_id, entry_type_id, deed_id, entry_id, quantity, reorder_level, target_level, created_at, count(*) over()
+from core.stock_alert
+`+wherestr+`
+order by created_at desc, id desc `+formatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	aa := []*dots.StockAlert{}
+	for rows.Next() {
+		var a dots.StockAlert
+		err := rows.Scan(&a.ID, &a.CompanyID, &a.EntryTypeID, &a.DeedID, &a.EntryID, &a.Quantity, &a.ReorderLevel, &a.TargetLevel, &a.CreatedAt, &n)
+		if err != nil {
+			return nil, 0, err
+		}
+		aa = append(aa, &a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return aa, n, nil
+}
+
+// alertOnLowStock raises an alert when a drain that took more from an entry
+// crossed the stock of its company and entry type below the reorder level.
+// A company threshold goes before the one for every company
+func alertOnLowStock(ctx context.Context, tx *Tx, deedID, entryID int, took float64) error {
+	if took <= 0 {
+		return nil
+	}
+
+	var (
+		companyID, entryTypeID int
+		reorder, target, left  float64
+	)
+	err := tx.QueryRowContext(
+		ctx, `
+select e.company_id, e.entry_type_id, st.reorder_level, st.target_level,
+	coalesce((
+		select sum(s.quantity) from core.entry s
+		where s.company_id = e.company_id and s.entry_type_id = e.entry_type_id and s.deleted_at is null
+	), 0) - coalesce((
+		select sum(d.quantity) from core.drain d
+		join core.entry s on s.id = d.entry_id
+		where s.company_id = e.company_id and s.entry_type_id = e.entry_type_id and s.deleted_at is null and d.is_deleted = false
+	), 0)
+from core.entry e
+join lateral (
+	select t.reorder_level, t.target_level from core.stock_threshold t
+	where t.entry_type_id = e.entry_type_id and (t.company_id = e.company_id or t.company_id is null)
+	order by t.company_id nulls last
+	limit 1
+) st on true
+where e.id = $1`,
+		entryID,
+	).Scan(&companyID, &entryTypeID, &reorder, &target, &left)
+	// no threshold
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	left = aprox(left, 5)
+	if left >= reorder || aprox(left+took, 5) < reorder {
+		return nil
+	}
+
+	_, err = tx.ExecContext(
+		ctx, `
+insert into core.stock_alert
+(company_id, entry_type_id, deed_id, entry_id, quantity, reorder_level, target_level)
+values
+($1, $2, $3, $4, $5, $6, $7)`,
+		companyID, entryTypeID, deedID, entryID, left, reorder, target,
+	)
+	if err != nil {
+		return fmt.Errorf("postgres.stock alert: cannot raise %w", err)
+	}
+
+	return nil
+}
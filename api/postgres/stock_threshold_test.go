@@ -0,0 +1,59 @@
+package postgres_test
+
+import (
+	"testing"
+
+	"github.com/innermond/dots"
+	"github.com/innermond/dots/postgres"
+)
+
+func TestStockThresholdService(t *testing.T) {
+	db := MustOpenDB(t, DSN)
+	defer MustCloseDB(t, db)
+
+	ctx, deleteTenant := MustCreateTenant(t, db)
+	defer deleteTenant()
+	cid, etid, eid := MustCreateStock(t, ctx, db, 10)
+
+	s := postgres.NewStockThresholdService(db)
+	reorder, target := 5.0, 12.0
+	if err := s.CreateStockThreshold(ctx, &dots.StockThreshold{EntryTypeID: &etid, ReorderLevel: &reorder, TargetLevel: &target}); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the drain going below the reorder level raises an alert
+	deeds := []int{}
+	for _, qty := range []float64{3, 3, 1} {
+		d := newDeed(cid, "DEED", 1, map[int]float64{eid: qty})
+		if err := postgres.NewDeedService(db).CreateDeed(ctx, &d); err != nil {
+			t.Fatal(err)
+		}
+		deeds = append(deeds, *d.ID)
+	}
+
+	t.Run("Alert", func(t *testing.T) {
+		aa, n, err := s.FindStockAlert(ctx, dots.StockAlertFilter{CompanyID: &cid})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 || len(aa) != 1 {
+			t.Fatalf("n=%d alerts=%+v, want 1", n, aa)
+		}
+		if a := aa[0]; a.DeedID == nil || *a.DeedID != deeds[1] || a.Quantity != 4 || a.ReorderLevel != 5 || a.TargetLevel != 12 {
+			t.Fatalf("alert=%+v, want deed %d leaving 4", a, deeds[1])
+		}
+	})
+
+	t.Run("Depletion", func(t *testing.T) {
+		dd, n, err := postgres.NewCompanyService(db).DepletionCompany(ctx, dots.CompanyFilter{ID: &cid})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 || len(dd) != 1 {
+			t.Fatalf("n=%d depletion=%+v, want 1", n, dd)
+		}
+		if d := dd[0]; *d.EntryTypeID != etid || d.Quantity != 3 || d.ReorderQuantity != 9 {
+			t.Fatalf("depletion=%+v, want 3 left and 9 to reorder", d)
+		}
+	})
+}
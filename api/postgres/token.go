@@ -2,12 +2,16 @@ package postgres
 
 import (
 	"context"
+	"crypto/subtle"
+	"database/sql"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/innermond/dots"
 	"github.com/innermond/dots/http/token"
+	"github.com/segmentio/ksuid"
 )
 
 type TokenService struct {
@@ -20,9 +24,15 @@ type TokenService struct {
 	userService *UserService
 }
 
+// refreshTokenTTL bounds a login session kept alive by refreshing
+const refreshTokenTTL = 30 * 24 * time.Hour
+
 var (
 	tokener   token.Tokener
 	errPrefix error = errors.New("token prefix not found")
+
+	errRefresh  = dots.Errorf(dots.EUNAUTHORIZED, "invalid refresh token")
+	errDisabled = dots.Errorf(dots.EUNAUTHORIZED, "account disabled")
 )
 
 func NewTokenService(db *DB, secret string, prefix string, ttl time.Duration, userService *UserService) *TokenService {
@@ -43,41 +53,341 @@ func NewTokenService(db *DB, secret string, prefix string, ttl time.Duration, us
 
 type loginData = dots.TokenCredentials
 
-func (s *TokenService) Create(ctx context.Context, login loginData) (string, error) {
+func (s *TokenService) Create(ctx context.Context, login loginData) (*dots.TokenPair, error) {
 	if err := validateCreateFrom(login); err != nil {
-		return "", err
+		return nil, err
+	}
+
+	uid, err := s.authenticate(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// every login starts a new family of refresh tokens
+	pair, err := s.issue(ctx, tx, uid, ksuid.Nil, ksuid.New())
+	if err != nil {
+		return nil, err
+	}
+
+	return pair, tx.Commit()
+}
+
+func (s *TokenService) Refresh(ctx context.Context, refresh string) (*dots.TokenPair, error) {
+	if refresh == "" {
+		return nil, dots.Errorf(dots.EINVALID, "refresh token required")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		id                int
+		uid, family       ksuid.KSUID
+		oid               *ksuid.KSUID
+		usedAt, revokedAt sql.NullTime
+		expiresAt         time.Time
+	)
+	err = tx.QueryRowContext(
+		ctx, `
+select id, user_id, family_id, organisation_id, used_at, revoked_at, expires_at
+from core.refresh_token
+where token_hash = $1
+for update`,
+		hashToken(refresh),
+	).Scan(&id, &uid, &family, &oid, &usedAt, &revokedAt, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, errRefresh
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if revokedAt.Valid || !expiresAt.After(tx.now) {
+		return nil, errRefresh
+	}
+
+	// a rotated token coming back means a copy of it is in other hands
+	// so nobody of the family can be trusted anymore
+	if usedAt.Valid {
+		if err := revokeTokenFamily(ctx, tx, family); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, dots.Errorf(dots.EUNAUTHORIZED, "refresh token reused")
+	}
+
+	_, err = tx.ExecContext(ctx, `update core.refresh_token set used_at = $2 where id = $1`, id, tx.now)
+	if err != nil {
+		return nil, fmt.Errorf("postgres.token: cannot use refresh token: %w", err)
+	}
+
+	// a membership lost meanwhile falls back to the personal organisation
+	active := ksuid.Nil
+	if oid != nil {
+		if _, err := findMembershipRole(ctx, tx, *oid, uid); err == nil {
+			active = *oid
+		}
+	}
+
+	pair, err := s.issue(ctx, tx, uid, active, family)
+	if err != nil {
+		return nil, err
+	}
+
+	return pair, tx.Commit()
+}
+
+func (s *TokenService) Switch(ctx context.Context, payload *dots.TokenPayload, oid ksuid.KSUID) (*dots.TokenPair, error) {
+	if payload == nil || payload.ID.IsNil() {
+		return nil, dots.Errorf(dots.EINVALID, "token required")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := findMembershipRole(ctx, tx, oid, payload.UID); err != nil {
+		return nil, err
+	}
+
+	// the old pair must not outlive the switch
+	expiresAt := payload.ExpiresAt
+	if expiresAt.IsZero() {
+		expiresAt = tx.now.Add(s.ttl)
+	}
+	if err := revokeAccessToken(ctx, tx, payload.ID, payload.UID, expiresAt); err != nil {
+		return nil, err
+	}
+	var family ksuid.KSUID
+	err = tx.QueryRowContext(
+		ctx,
+		`select family_id from core.refresh_token where access_id = $1`,
+		payload.ID,
+	).Scan(&family)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil {
+		if err := revokeTokenFamily(ctx, tx, family); err != nil {
+			return nil, err
+		}
+	}
+
+	pair, err := s.issue(ctx, tx, payload.UID, oid, ksuid.New())
+	if err != nil {
+		return nil, err
 	}
 
-	findByEmailApiKey := dots.UserFilter{
-		Email:  &login.Email,
-		ApiKey: &login.Pass,
-		Limit:  1,
+	return pair, tx.Commit()
+}
+
+func (s *TokenService) Revoke(ctx context.Context, payload *dots.TokenPayload, refresh string) error {
+	if payload == nil || payload.ID.IsNil() {
+		return dots.Errorf(dots.EINVALID, "token required")
 	}
-	uu, n, err := s.userService.FindUser(ctx, findByEmailApiKey)
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return "", err
+		return err
+	}
+	defer tx.Rollback()
+
+	expiresAt := payload.ExpiresAt
+	if expiresAt.IsZero() {
+		expiresAt = tx.now.Add(s.ttl)
 	}
-	if n == 0 {
-		return "", errors.New("data not found")
+	if err := revokeAccessToken(ctx, tx, payload.ID, payload.UID, expiresAt); err != nil {
+		return err
 	}
-	uid := uu[0].ID
 
-	tokenstr, err := s.tk.CreateToken(uid, s.ttl)
+	rows, err := tx.QueryContext(
+		ctx, `
+select distinct family_id
+from core.refresh_token
+where user_id = $1 and (access_id = $2 or token_hash = $3)`,
+		payload.UID, payload.ID, hashToken(refresh),
+	)
 	if err != nil {
-		return "", err
+		return err
 	}
+	defer rows.Close()
 
-	after, found := strings.CutPrefix(tokenstr, s.prefix)
+	families := []ksuid.KSUID{}
+	for rows.Next() {
+		var family ksuid.KSUID
+		if err := rows.Scan(&family); err != nil {
+			return err
+		}
+		families = append(families, family)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, family := range families {
+		if err := revokeTokenFamily(ctx, tx, family); err != nil {
+			return err
+		}
+	}
+
+	// revocations are useless once their token expired
+	_, err = tx.ExecContext(ctx, `delete from core.token_revocation where expires_at < $1`, tx.now)
+	if err != nil {
+		return fmt.Errorf("postgres.token: cannot prune revocations: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *TokenService) IsRevoked(ctx context.Context, id ksuid.KSUID) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var revoked bool
+	err = tx.QueryRowContext(
+		ctx,
+		`select exists(select 1 from core.token_revocation where token_id = $1)`,
+		id,
+	).Scan(&revoked)
+	if err != nil {
+		return false, err
+	}
+
+	return revoked, tx.Commit()
+}
+
+// issue mints an access token together with the refresh token able to replace it
+func (s *TokenService) issue(ctx context.Context, tx *Tx, uid ksuid.KSUID, oid ksuid.KSUID, family ksuid.KSUID) (*dots.TokenPair, error) {
+	tokenstr, payload, err := s.tk.CreateToken(uid, oid, s.ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	access, found := strings.CutPrefix(tokenstr, s.prefix)
 	if !found {
-		return "", errPrefix
+		return nil, errPrefix
+	}
+
+	refresh, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx, `
+insert into core.refresh_token
+(user_id, family_id, organisation_id, token_hash, access_id, access_expires_at, expires_at, created_at)
+values
+($1, $2, $3, $4, $5, $6, $7, $8)`,
+		uid, family, oid, hashToken(refresh), payload.ID, payload.ExpiresAt, tx.now.Add(refreshTokenTTL), tx.now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("postgres.token: cannot create refresh token: %w", err)
+	}
+
+	return &dots.TokenPair{Access: access, Refresh: refresh, ExpiresAt: payload.ExpiresAt}, nil
+}
+
+func revokeAccessToken(ctx context.Context, tx *Tx, id ksuid.KSUID, uid ksuid.KSUID, expiresAt time.Time) error {
+	_, err := tx.ExecContext(
+		ctx, `
+insert into core.token_revocation
+(token_id, user_id, expires_at, created_at)
+values
+($1, $2, $3, $4)
+on conflict (token_id) do nothing`,
+		id, uid, expiresAt, tx.now,
+	)
+	if err != nil {
+		return fmt.Errorf("postgres.token: cannot revoke token: %w", err)
+	}
+	return nil
+}
+
+// revokeTokenFamily revokes the refresh tokens of a family
+// and the access tokens still alive that were issued with them
+func revokeTokenFamily(ctx context.Context, tx *Tx, family ksuid.KSUID) error {
+	_, err := tx.ExecContext(
+		ctx, `
+insert into core.token_revocation
+(token_id, user_id, expires_at, created_at)
+select access_id, user_id, access_expires_at, $2
+from core.refresh_token
+where family_id = $1 and access_expires_at > $2
+on conflict (token_id) do nothing`,
+		family, tx.now,
+	)
+	if err != nil {
+		return fmt.Errorf("postgres.token: cannot revoke family tokens: %w", err)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`update core.refresh_token set revoked_at = $2 where family_id = $1 and revoked_at is null`,
+		family, tx.now,
+	)
+	if err != nil {
+		return fmt.Errorf("postgres.token: cannot revoke family: %w", err)
+	}
+
+	return nil
+}
+
+func (s *TokenService) authenticate(ctx context.Context, login loginData) (ksuid.KSUID, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ksuid.Nil, err
+	}
+	defer tx.Rollback()
+
+	cred, err := findUserCredentials(ctx, tx, dots.UserFilter{Email: &login.Email})
+	if err != nil {
+		if dots.ErrorCode(err) == dots.ENOTFOUND {
+			return ksuid.Nil, errCredentials
+		}
+		return ksuid.Nil, err
+	}
+
+	if cred.disabledAt != nil {
+		return ksuid.Nil, errDisabled
+	}
+
+	if cred.passHash != nil {
+		if err := comparePassword(*cred.passHash, login.Pass); err != nil {
+			return ksuid.Nil, err
+		}
+		return cred.id, nil
+	}
+
+	// accounts without a password still login with their api key
+	// until they set one
+	if subtle.ConstantTimeCompare([]byte(cred.apiKey), []byte(login.Pass)) != 1 {
+		return ksuid.Nil, errCredentials
 	}
 
-	return after, nil
+	return cred.id, nil
 }
 
 func validateCreateFrom(data loginData) error {
 	if data.Email == "" || data.Pass == "" {
-		return errors.New("missing or invalid credentials")
+		return dots.Errorf(dots.EINVALID, "missing or invalid credentials")
 	}
 	return nil
 }
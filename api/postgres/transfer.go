@@ -0,0 +1,243 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/innermond/dots"
+	"github.com/shopspring/decimal"
+)
+
+type TransferService struct {
+	db *DB
+}
+
+func NewTransferService(db *DB) *TransferService {
+	return &TransferService{db: db}
+}
+
+func (s *TransferService) CreateTransfer(ctx context.Context, t *dots.Transfer) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanCreateOwn(ctx); canerr != nil {
+		return canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return err
+	}
+
+	if err := createTransfer(ctx, tx, t); err != nil {
+		return perr(err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *TransferService) FindTransfer(ctx context.Context, filter dots.TransferFilter) ([]*dots.Transfer, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, 0, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	return findTransfer(ctx, tx, filter)
+}
+
+func createTransfer(ctx context.Context, tx *Tx, t *dots.Transfer) error {
+	from, to, etid := *t.FromCompanyID, *t.ToCompanyID, *t.EntryTypeID
+
+	// both companies and the entry type must be alive ones of the caller
+	var (
+		companies int
+		unit      *string
+	)
+	err := tx.QueryRowContext(
+		ctx, `
+select
+	(select count(*) from api.company where id = any($1)),
+	(select unit from api.entry_type where id = $2)`,
+		[]int{from, to}, etid,
+	).Scan(&companies, &unit)
+	if err != nil {
+		return err
+	}
+	if companies != 2 || unit == nil {
+		return dots.Errorf(dots.ENOTFOUND, "company or entry type not found")
+	}
+
+	strategy, err := distributeStrategy(ctx, tx, from, t.Strategy, dots.DistributeOldMany)
+	if err != nil {
+		return err
+	}
+	t.Strategy = &strategy
+
+	distribute, err := tryDistributeOverEntryType(ctx, tx, map[int]float64{etid: *t.Quantity}, from, strategy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return dots.Errorf(dots.ENOTFOUND, "no entries of entry type %d to transfer", etid)
+	}
+	if err != nil {
+		return err
+	}
+
+	title := fmt.Sprintf("transfer to company %d", to)
+	d := &dots.Deed{DeedUpdate: dots.DeedUpdate{CompanyID: &from, Title: &title, Quantity: t.Quantity, Unit: unit}}
+	if err := checkQuota(ctx, tx, dots.ResourceDeed, map[string]*string{"title": d.Title, "unit": d.Unit}); err != nil {
+		return err
+	}
+	if err := createDeed(ctx, tx, d); err != nil {
+		return err
+	}
+	t.DeedID = *d.ID
+
+	err = tx.QueryRowContext(
+		ctx, `
+insert into core.transfer
+(from_company_id, to_company_id, entry_type_id, quantity, deed_id, note)
+values
+($1, $2, $3, $4, $5, $6) returning id, created_at`,
+		from, to, etid, *t.Quantity, t.DeedID, t.Note,
+	).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	t.Lines = []*dots.TransferLine{}
+	return drainInOrder(ctx, tx, t.DeedID, distribute, func(eid int, qty float64) error {
+		// the moved quantity keeps its cost, lot and expiry
+		var (
+			unitcost  *decimal.Decimal
+			lot       *string
+			expiresAt *dots.PartialTime
+		)
+		err := tx.QueryRowContext(ctx, `select unitcost, lot, expires_at from core.entry where id = $1`, eid).Scan(&unitcost, &lot, &expiresAt)
+		if err != nil {
+			return err
+		}
+
+		if err := checkQuota(ctx, tx, dots.ResourceEntry, nil); err != nil {
+			return err
+		}
+		q := qty
+		e := &dots.Entry{EntryTypeID: &etid, Quantity: &q, CompanyID: &to, UnitCost: unitcost, Lot: lot, ExpiresAt: expiresAt}
+		if err := createEntry(ctx, tx, e); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`insert into core.transfer_line (transfer_id, source_entry_id, target_entry_id, quantity) values ($1, $2, $3, $4)`,
+			t.ID, eid, *e.ID, qty,
+		)
+		if err != nil {
+			return err
+		}
+		t.Lines = append(t.Lines, &dots.TransferLine{SourceEntryID: eid, TargetEntryID: *e.ID, Quantity: qty})
+		return nil
+	})
+}
+
+func findTransfer(ctx context.Context, tx *Tx, filter dots.TransferFilter) (_ []*dots.Transfer, n int, err error) {
+	where, args := []string{}, []interface{}{}
+	if v := filter.ID; v != nil {
+		where, args = append(where, "t.id = ?"), append(args, *v)
+	}
+	if v := filter.CompanyID; v != nil {
+		where, args = append(where, "? in (t.from_company_id, t.to_company_id)"), append(args, *v)
+	}
+	if v := filter.EntryTypeID; v != nil {
+		where, args = append(where, "t.entry_type_id = ?"), append(args, *v)
+	}
+	if v := filter.From; v != nil {
+		where, args = append(where, "t.created_at >= ?"), append(args, *v)
+	}
+	if v := filter.To; v != nil {
+		where, args = append(where, "t.created_at < ?"), append(args, *v)
+	}
+
+	wherestr := ""
+	if len(where) > 0 {
+		replaceQuestionMark(where, args)
+		wherestr = "where " + strings.Join(where, " and ")
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+select t.id, t.from_company_id, t.to_company_id, t.entry_type_id, t.quantity, t.note, t.deed_id, t.created_at, count(*) over()
+from core.transfer t
+`+wherestr+`
+order by t.created_at desc, t.id desc `+formatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	transfers := []*dots.Transfer{}
+	byID := map[int]*dots.Transfer{}
+	ids := []int{}
+	for rows.Next() {
+		var t dots.Transfer
+		err := rows.Scan(&t.ID, &t.FromCompanyID, &t.ToCompanyID, &t.EntryTypeID, &t.Quantity, &t.Note, &t.DeedID, &t.CreatedAt, &n)
+		if err != nil {
+			return nil, 0, err
+		}
+		t.Lines = []*dots.TransferLine{}
+		transfers = append(transfers, &t)
+		byID[t.ID] = &t
+		ids = append(ids, t.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	rows.Close()
+
+	if len(ids) == 0 {
+		return transfers, n, nil
+	}
+
+	rows, err = tx.QueryContext(ctx, `
+select transfer_id, source_entry_id, target_entry_id, quantity
+from core.transfer_line
+where transfer_id = any($1)
+order by transfer_id, source_entry_id`, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id int
+			l  dots.TransferLine
+		)
+		if err := rows.Scan(&id, &l.SourceEntryID, &l.TargetEntryID, &l.Quantity); err != nil {
+			return nil, 0, err
+		}
+		byID[id].Lines = append(byID[id].Lines, &l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return transfers, n, nil
+}
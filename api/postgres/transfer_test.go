@@ -0,0 +1,74 @@
+package postgres_test
+
+import (
+	"testing"
+
+	"github.com/innermond/dots"
+	"github.com/innermond/dots/postgres"
+)
+
+func TestTransferService_CreateTransfer(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		db := MustOpenDB(t, DSN)
+		defer MustCloseDB(t, db)
+
+		ctx, deleteTenant := MustCreateTenant(t, db)
+		defer deleteTenant()
+		MustSetPlan(t, ctx, db, "two eyes")
+		cid, etid, eid := MustCreateStock(t, ctx, db, 10)
+		to := &dots.Company{Longname: "TARGET", TIN: "TIN2", RN: "RN2"}
+		if err := postgres.NewCompanyService(db).CreateCompany(ctx, to); err != nil {
+			t.Fatal(err)
+		}
+
+		s := postgres.NewTransferService(db)
+		qty := 4.0
+		tr := &dots.Transfer{FromCompanyID: &cid, ToCompanyID: &to.ID, EntryTypeID: &etid, Quantity: &qty}
+		if err := s.CreateTransfer(ctx, tr); err != nil {
+			t.Fatal(err)
+		}
+		if len(tr.Lines) != 1 || tr.Lines[0].SourceEntryID != eid || tr.Lines[0].Quantity != 4 {
+			t.Fatalf("lines=%+v", tr.Lines)
+		}
+
+		if got := MustFindStock(t, ctx, db, cid, etid); got != 6 {
+			t.Fatalf("source stock=%v, want 6", got)
+		}
+		if got := MustFindStock(t, ctx, db, to.ID, etid); got != 4 {
+			t.Fatalf("target stock=%v, want 4", got)
+		}
+
+		// the transfer is found from both ends
+		for _, id := range []int{cid, to.ID} {
+			if _, n, err := s.FindTransfer(ctx, dots.TransferFilter{CompanyID: &id}); err != nil || n != 1 {
+				t.Fatalf("company %d: n=%d, err=%v, want 1", id, n, err)
+			}
+		}
+	})
+
+	t.Run("ErrHeld", func(t *testing.T) {
+		db := MustOpenDB(t, DSN)
+		defer MustCloseDB(t, db)
+
+		ctx, deleteTenant := MustCreateTenant(t, db)
+		defer deleteTenant()
+		MustSetPlan(t, ctx, db, "two eyes")
+		cid, etid, _ := MustCreateStock(t, ctx, db, 10)
+		to := &dots.Company{Longname: "TARGET", TIN: "TIN2", RN: "RN2"}
+		if err := postgres.NewCompanyService(db).CreateCompany(ctx, to); err != nil {
+			t.Fatal(err)
+		}
+
+		// what a reservation holds cannot be moved away
+		MustCreateReservation(t, ctx, postgres.NewReservationService(db), MustCreateDeed(t, ctx, db, cid), etid, 8, nil)
+
+		qty := 4.0
+		tr := &dots.Transfer{FromCompanyID: &cid, ToCompanyID: &to.ID, EntryTypeID: &etid, Quantity: &qty}
+		if err := postgres.NewTransferService(db).CreateTransfer(ctx, tr); dots.ErrorCode(err) != dots.EINVALID {
+			t.Fatalf("err=%v, want %s", err, dots.EINVALID)
+		}
+		if got := MustFindStock(t, ctx, db, cid, etid); got != 10 {
+			t.Fatalf("source stock=%v, want 10", got)
+		}
+	})
+}
@@ -0,0 +1,21 @@
+package postgres
+
+import (
+	"github.com/innermond/dots"
+)
+
+// restoreEach resurects ids one by one, a single miss undoes the lot
+func restoreEach(resource string, ids []int, resurect func(id int) (int, error)) (int, error) {
+	total := 0
+	for _, id := range ids {
+		n, err := resurect(id)
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			return 0, dots.Errorf(dots.ENOTAFFECTED, "%s %d not affected (resurect)", resource, id)
+		}
+		total += n
+	}
+	return total, nil
+}
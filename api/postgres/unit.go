@@ -0,0 +1,415 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/innermond/dots"
+)
+
+type UnitService struct {
+	db *DB
+}
+
+func NewUnitService(db *DB) *UnitService {
+	return &UnitService{db: db}
+}
+
+func (s *UnitService) FindUnit(ctx context.Context) ([]*dots.Unit, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, 0, canerr
+	}
+
+	return findUnit(ctx, tx)
+}
+
+func (s *UnitService) CreateUnitConversion(ctx context.Context, uc *dots.UnitConversion) error {
+	if err := uc.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanCreateOwn(ctx); canerr != nil {
+		return canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return err
+	}
+
+	if v := uc.EntryTypeID; v != nil {
+		var found bool
+		err := tx.QueryRowContext(ctx, `select exists(select 1 from api.entry_type where id = $1)`, *v).Scan(&found)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return dots.Errorf(dots.ENOTFOUND, "entry type not found")
+		}
+	}
+
+	if err := createUnitConversion(ctx, tx, uc); err != nil {
+		return perr(err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *UnitService) FindUnitConversion(ctx context.Context, filter dots.UnitConversionFilter) ([]*dots.UnitConversion, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, 0, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	return findUnitConversion(ctx, tx, filter)
+}
+
+func (s *UnitService) DeleteUnitConversion(ctx context.Context, id int) (int, error) {
+	if canerr := dots.CanDeleteOwn(ctx); canerr != nil {
+		return 0, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return 0, err
+	}
+
+	// shared conversions are seen but not owned
+	result, err := tx.ExecContext(ctx, `delete from core.unit_conversion where id = $1 and tid is not null`, id)
+	if err != nil {
+		return 0, fmt.Errorf("postgres.unit conversion: cannot delete %w", err)
+	}
+	n64, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n64 == 0 {
+		return 0, dots.Errorf(dots.ENOTFOUND, "unit conversion not found")
+	}
+
+	return int(n64), tx.Commit()
+}
+
+func (s *UnitService) ConvertUnit(ctx context.Context, convert dots.UnitConvert) (*dots.UnitConverted, error) {
+	if err := convert.Validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, err
+	}
+
+	entryTypeID := 0
+	if v := convert.EntryTypeID; v != nil {
+		entryTypeID = *v
+	}
+	f, err := unitFactor(ctx, tx, *convert.From, *convert.To, entryTypeID)
+	if err != nil {
+		return nil, err
+	}
+	if f.factor == nil {
+		return nil, dots.Errorf(dots.ENOTFOUND, "no conversion from %s to %s", f.from, f.to)
+	}
+
+	return &dots.UnitConverted{
+		Quantity: aprox(*convert.Quantity**f.factor, 5),
+		From:     f.from,
+		To:       f.to,
+		Factor:   *f.factor,
+	}, nil
+}
+
+func findUnit(ctx context.Context, tx *Tx) ([]*dots.Unit, int, error) {
+	rows, err := tx.QueryContext(ctx, `
+select u.code, u.name, coalesce(string_agg(a.alias, ',' order by a.alias), '')
+from core.unit u
+left join core.unit_alias a on a.code = u.code
+group by u.code, u.name
+order by u.code`)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	uu := []*dots.Unit{}
+	for rows.Next() {
+		var (
+			u       dots.Unit
+			aliases string
+		)
+		if err := rows.Scan(&u.Code, &u.Name, &aliases); err != nil {
+			return nil, 0, err
+		}
+		u.Aliases = []string{}
+		if aliases != "" {
+			u.Aliases = strings.Split(aliases, ",")
+		}
+		uu = append(uu, &u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return uu, len(uu), nil
+}
+
+func createUnitConversion(ctx context.Context, tx *Tx, uc *dots.UnitConversion) error {
+	from, err := unitCode(ctx, tx, *uc.From)
+	if err != nil {
+		return err
+	}
+	to, err := unitCode(ctx, tx, *uc.To)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		return dots.Errorf(dots.EINVALID, "from and to are both %s", from)
+	}
+	uc.From, uc.To = &from, &to
+
+	return tx.QueryRowContext(
+		ctx, `
+insert into core.unit_conversion
+(from_unit, to_unit, factor, entry_type_id)
+values
+($1, $2, $3, $4) returning id`,
+		from, to, uc.Factor, uc.EntryTypeID,
+	).Scan(&uc.ID)
+}
+
+func findUnitConversion(ctx context.Context, tx *Tx, filter dots.UnitConversionFilter) (_ []*dots.UnitConversion, n int, err error) {
+	where, args := []string{}, []interface{}{}
+	if v := filter.Unit; v != nil {
+		code, err := unitCode(ctx, tx, *v)
+		if err != nil {
+			return nil, 0, err
+		}
+		where, args = append(where, "? in (from_unit, to_unit)"), append(args, code)
+	}
+	if v := filter.EntryTypeID; v != nil {
+		where, args = append(where, "(entry_type_id = ? or entry_type_id is null)"), append(args, *v)
+	}
+
+	wherestr := ""
+	if len(where) > 0 {
+		replaceQuestionMark(where, args)
+		wherestr = "where " + strings.Join(where, " and ")
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+select id, from_unit, to_unit, factor, entry_type_id, tid is null, count(*) over()
+from core.unit_conversion
+`+wherestr+`
+order by tid nulls first, entry_type_id nulls first, from_unit, to_unit `+formatLimitOffset(filter.Limit, filter.Offset),
+		args...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	cc := []*dots.UnitConversion{}
+	for rows.Next() {
+		var uc dots.UnitConversion
+		if err := rows.Scan(&uc.ID, &uc.From, &uc.To, &uc.Factor, &uc.EntryTypeID, &uc.Shared, &n); err != nil {
+			return nil, 0, err
+		}
+		cc = append(cc, &uc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return cc, n, nil
+}
+
+// unitCode resolves a unit written as a code or an alias to its code
+func unitCode(ctx context.Context, tx *Tx, unit string) (string, error) {
+	var code string
+	err := tx.QueryRowContext(ctx, `
+select code from core.unit where code = upper(trim($1))
+union all
+select code from core.unit_alias where alias = lower(trim($1))
+limit 1`, unit).Scan(&code)
+	if err == sql.ErrNoRows {
+		return "", dots.Errorf(dots.ENOTFOUND, "unit %q not found", unit)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+type conversion struct {
+	// from and to are codes, or the units as given when not in the catalog
+	from, to string
+	// factor is nil when no conversion is known
+	factor *float64
+	// catalogued tells both units are in the catalog
+	catalogued bool
+}
+
+// unitFactor finds how to turn a quantity in from into to. A conversion of the entry type
+// goes before the ones of the tenant and then the shared ones, each read forward or backwards
+func unitFactor(ctx context.Context, tx *Tx, from, to string, entryTypeID int) (conversion, error) {
+	f := conversion{catalogued: true}
+	var err error
+	if f.from, err = unitCodeOr(ctx, tx, from, &f.catalogued); err != nil {
+		return f, err
+	}
+	if f.to, err = unitCodeOr(ctx, tx, to, &f.catalogued); err != nil {
+		return f, err
+	}
+	if f.from == f.to {
+		one := 1.0
+		f.factor = &one
+		return f, nil
+	}
+
+	err = tx.QueryRowContext(ctx, `
+select case when from_unit = $1 then factor else 1 / factor end
+from core.unit_conversion
+where ((from_unit = $1 and to_unit = $2) or (from_unit = $2 and to_unit = $1))
+and (entry_type_id = $3 or entry_type_id is null)
+order by entry_type_id nulls last, tid nulls last
+limit 1`, f.from, f.to, entryTypeID).Scan(&f.factor)
+	if err != nil && err != sql.ErrNoRows {
+		return f, err
+	}
+
+	return f, nil
+}
+
+// unitCodeOr is unitCode keeping a unit out of the catalog as it is written,
+// catalogued turns false when it is not found
+func unitCodeOr(ctx context.Context, tx *Tx, unit string, catalogued *bool) (string, error) {
+	code, err := unitCode(ctx, tx, unit)
+	if dots.ErrorCode(err) == dots.ENOTFOUND {
+		*catalogued = false
+		return strings.TrimSpace(unit), nil
+	}
+	return code, err
+}
+
+// toEntryTypeUnit turns qty in unit into the unit of the entry type.
+// Quantities with no unit or a unit out of the catalog go as they are,
+// catalog units having no conversion between them are refused
+func toEntryTypeUnit(ctx context.Context, tx *Tx, unit *string, entryTypeID int, qty float64) (float64, error) {
+	if unit == nil {
+		return qty, nil
+	}
+
+	var etUnit string
+	err := tx.QueryRowContext(ctx, `select unit from core.entry_type where id = $1`, entryTypeID).Scan(&etUnit)
+	if err == sql.ErrNoRows {
+		return 0, dots.Errorf(dots.ENOTFOUND, "entry type not found")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := unitFactor(ctx, tx, *unit, etUnit, entryTypeID)
+	if err != nil {
+		return 0, err
+	}
+	if f.factor == nil {
+		if f.catalogued {
+			return 0, dots.Errorf(dots.EINVALID, "no conversion from %s to %s", f.from, f.to)
+		}
+		return qty, nil
+	}
+
+	return aprox(qty**f.factor, 5), nil
+}
+
+// toEntryUnit is toEntryTypeUnit for the entry type of an entry
+func toEntryUnit(ctx context.Context, tx *Tx, unit *string, entryID int, qty float64) (float64, error) {
+	if unit == nil {
+		return qty, nil
+	}
+
+	var entryTypeID int
+	err := tx.QueryRowContext(ctx, `select entry_type_id from core.entry where id = $1`, entryID).Scan(&entryTypeID)
+	if err == sql.ErrNoRows {
+		return 0, dots.Errorf(dots.ENOTFOUND, "entry not found")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return toEntryTypeUnit(ctx, tx, unit, entryTypeID, qty)
+}
+
+// distributeInEntryUnits turns the quantities to distribute, given in the distribute unit,
+// into the units of the entry types they get drained from
+func distributeInEntryUnits(ctx context.Context, tx *Tx, upd *dots.DeedUpdate) error {
+	unit := upd.DistributeUnit
+	if unit == nil {
+		return nil
+	}
+
+	if len(upd.EntryTypeDistribute) > 0 {
+		converted := make(map[int]float64, len(upd.EntryTypeDistribute))
+		for etid, qty := range upd.EntryTypeDistribute {
+			q, err := toEntryTypeUnit(ctx, tx, unit, etid, qty)
+			if err != nil {
+				return err
+			}
+			converted[etid] = q
+		}
+		upd.EntryTypeDistribute = converted
+	}
+
+	if len(upd.Distribute) > 0 {
+		converted := make(map[int]float64, len(upd.Distribute))
+		for eid, qty := range upd.Distribute {
+			q, err := toEntryUnit(ctx, tx, unit, eid, qty)
+			if err != nil {
+				return err
+			}
+			converted[eid] = q
+		}
+		upd.Distribute = converted
+	}
+	upd.DistributeUnit = nil
+
+	return nil
+}
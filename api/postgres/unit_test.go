@@ -0,0 +1,80 @@
+package postgres_test
+
+import (
+	"testing"
+
+	"github.com/innermond/dots"
+	"github.com/innermond/dots/postgres"
+)
+
+func TestUnitService_ConvertUnit(t *testing.T) {
+	db := MustOpenDB(t, DSN)
+	defer MustCloseDB(t, db)
+
+	ctx, deleteTenant := MustCreateTenant(t, db)
+	defer deleteTenant()
+	_, etid, _ := MustCreateStock(t, ctx, db, 10)
+
+	s := postgres.NewUnitService(db)
+	for _, uc := range []struct {
+		factor      float64
+		entryTypeID *int
+	}{{20, nil}, {25, &etid}} {
+		from, to, factor := "rola", "m2", uc.factor
+		if err := s.CreateUnitConversion(ctx, &dots.UnitConversion{From: &from, To: &to, Factor: &factor, EntryTypeID: uc.entryTypeID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		quantity    float64
+		from, to    string
+		entryTypeID *int
+		want        float64
+	}{
+		{"shared read backwards", 250, "cm", "M", nil, 2.5},
+		{"tenant", 1.5, "rola", "m2", nil, 30},
+		{"entry type before tenant", 1.5, "rola", "m2", &etid, 37.5},
+		{"same unit", 3, "pcs", "H87", nil, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qty, from, to := tt.quantity, tt.from, tt.to
+			got, err := s.ConvertUnit(ctx, dots.UnitConvert{Quantity: &qty, From: &from, To: &to, EntryTypeID: tt.entryTypeID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Quantity != tt.want {
+				t.Fatalf("converted=%+v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("ErrNoConversion", func(t *testing.T) {
+		qty, from, to := 1.0, "kg", "m"
+		if _, err := s.ConvertUnit(ctx, dots.UnitConvert{Quantity: &qty, From: &from, To: &to}); dots.ErrorCode(err) != dots.ENOTFOUND {
+			t.Fatalf("err=%v, want %s", err, dots.ENOTFOUND)
+		}
+	})
+}
+
+func TestUnitService_DistributeUnit(t *testing.T) {
+	db := MustOpenDB(t, DSN)
+	defer MustCloseDB(t, db)
+
+	ctx, deleteTenant := MustCreateTenant(t, db)
+	defer deleteTenant()
+	cid, etid, eid := MustCreateStock(t, ctx, db, 10)
+
+	// pairs drain two pieces each
+	unit := "pair"
+	d := newDeed(cid, "DEED", 1, map[int]float64{eid: 2})
+	d.DistributeUnit = &unit
+	if err := postgres.NewDeedService(db).CreateDeed(ctx, &d); err != nil {
+		t.Fatal(err)
+	}
+	if got := MustFindStock(t, ctx, db, cid, etid); got != 6 {
+		t.Fatalf("stock=%v, want 6", got)
+	}
+}
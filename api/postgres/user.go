@@ -80,15 +80,33 @@ func (s *UserService) FindUser(ctx context.Context, filter dots.UserFilter) ([]*
 }
 
 func (s *UserService) UpdateUser(ctx context.Context, id ksuid.KSUID, upd dots.UserUpdate) (*dots.User, error) {
+	if err := upd.Validate(); err != nil {
+		return nil, err
+	}
+
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
+	// logins are linked by email, an address nobody verified cannot take its place
+	if v := upd.Email; v != nil {
+		uu, _, err := findUser(ctx, tx, dots.UserFilter{ID: &id, Limit: 1})
+		if err != nil {
+			return nil, err
+		}
+		if len(uu) == 0 {
+			return nil, dots.Errorf(dots.ENOTFOUND, "user not found")
+		}
+		if !strings.EqualFold(uu[0].Email, *v) {
+			return nil, dots.Errorf(dots.EINVALID, "email cannot be changed without verifying the new address")
+		}
+	}
+
 	user, err := updateUser(ctx, tx, id, &upd)
 	if err != nil {
-		return user, err
+		return user, perr(err)
 	}
 
 	err = attachUserAuths(ctx, tx, user)
@@ -147,12 +165,16 @@ func createUser(ctx context.Context, tx *Tx, u *dots.User) error {
 		)
 		values ($1, $2, $3, $4, $5, $6) returning id
 	`,
-		u.Name, email, u.ApiKey, u.Powers, tx.now, tx.now,
+		u.Name, email, u.ApiKey, powerNames(u.Powers), tx.now, tx.now,
 	).Scan(&u.ID)
 	if err != nil {
 		return err
 	}
 
+	if err := createPersonalOrganisation(ctx, tx, u); err != nil {
+		return err
+	}
+
 	u.CreatedAt = tx.now
 	u.UpdatedAt = tx.now
 
@@ -207,11 +229,21 @@ func findUser(ctx context.Context, tx *Tx, filter dots.UserFilter) (_ []*dots.Us
 		where, args = append(where, "id = ?"), append(args, *v)
 	}
 	if v := filter.Email; v != nil {
-		where, args = append(where, "email = ?"), append(args, *v)
+		where, args = append(where, "lower(email) = lower(?)"), append(args, *v)
 	}
 	if v := filter.ApiKey; v != nil {
 		where, args = append(where, "api_key = ?"), append(args, *v)
 	}
+	if v := filter.Search; v != nil {
+		where, args = append(where, "concat_ws(' ', name, email) ilike ?"), append(args, "%"+*v+"%")
+	}
+	if v := filter.IsDisabled; v != nil {
+		if *v {
+			where = append(where, "disabled_at is not null")
+		} else {
+			where = append(where, "disabled_at is null")
+		}
+	}
 	for inx, v := range where {
 		if !strings.Contains(v, "?") {
 			continue
@@ -224,7 +256,7 @@ func findUser(ctx context.Context, tx *Tx, filter dots.UserFilter) (_ []*dots.Us
 	select
 		id, name, email, api_key,
     array_to_json(powers),
-		created_at, updated_at,
+		created_at, updated_at, disabled_at,
 		count(*) over()
 	from core."user" u
 	where	%s %s`,
@@ -248,6 +280,7 @@ func findUser(ctx context.Context, tx *Tx, filter dots.UserFilter) (_ []*dots.Us
 		var email sql.NullString
 		var createdAt sql.NullTime
 		var updatedAt sql.NullTime
+		var disabledAt sql.NullTime
 
 		err := rows.Scan(
 			&u.ID,
@@ -257,6 +290,7 @@ func findUser(ctx context.Context, tx *Tx, filter dots.UserFilter) (_ []*dots.Us
 			&pp,
 			&createdAt,
 			&updatedAt,
+			&disabledAt,
 			&n,
 		)
 		if err != nil {
@@ -274,6 +308,10 @@ func findUser(ctx context.Context, tx *Tx, filter dots.UserFilter) (_ []*dots.Us
 		}
 		u.CreatedAt = timeRFC3339(createdAt)
 		u.UpdatedAt = timeRFC3339(updatedAt)
+		if disabledAt.Valid {
+			disabled := timeRFC3339(disabledAt)
+			u.DisabledAt = &disabled
+		}
 
 		users = append(users, &u)
 	}
@@ -0,0 +1,175 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/innermond/dots"
+	"github.com/segmentio/ksuid"
+)
+
+// UserAdminService lets an administrator manage every account
+type UserAdminService struct {
+	db *DB
+}
+
+func NewUserAdminService(db *DB) *UserAdminService {
+	return &UserAdminService{db: db}
+}
+
+func (s *UserAdminService) FindUser(ctx context.Context, filter dots.UserFilter) ([]*dots.User, int, error) {
+	if canerr := dots.CanDoAnything(ctx); canerr != nil {
+		return nil, 0, canerr
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	uu, n, err := findUser(ctx, tx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, u := range uu {
+		if err := attachUserAuths(ctx, tx, u); err != nil {
+			return nil, 0, err
+		}
+		// keys belong to their owners only
+		u.ApiKey = ""
+	}
+
+	return uu, n, nil
+}
+
+func (s *UserAdminService) UpdateUser(ctx context.Context, id ksuid.KSUID, upd dots.UserAdminUpdate) (*dots.User, error) {
+	if err := upd.Validate(); err != nil {
+		return nil, err
+	}
+
+	if canerr := dots.CanDoAnything(ctx); canerr != nil {
+		return nil, canerr
+	}
+
+	// an administrator must not lock itself out
+	if dots.UserFromContext(ctx).ID == id {
+		if upd.Disabled != nil && *upd.Disabled {
+			return nil, dots.Errorf(dots.ECONFLICT, "cannot disable yourself")
+		}
+		if dots.PowersContains(upd.Revoke, dots.DoAnything) {
+			return nil, dots.Errorf(dots.ECONFLICT, "cannot revoke your own %s", dots.DoAnything)
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	u, err := updateUser(ctx, tx, id, &upd.UserUpdate)
+	if err != nil {
+		return nil, perr(err)
+	}
+
+	if len(upd.Grant) > 0 || len(upd.Revoke) > 0 {
+		u.Powers = upd.Apply(u.Powers)
+		_, err := tx.ExecContext(
+			ctx,
+			`update core."user" set powers = $1 where id = $2`,
+			powerNames(u.Powers), id,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("postgres.user: cannot set powers %w", err)
+		}
+	}
+
+	if v := upd.Disabled; v != nil {
+		u.DisabledAt = nil
+		if *v {
+			now := tx.now
+			u.DisabledAt = &now
+		}
+		_, err := tx.ExecContext(
+			ctx,
+			`update core."user" set disabled_at = $1 where id = $2`,
+			u.DisabledAt, id,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("postgres.user: cannot set disabled %w", err)
+		}
+	}
+
+	if err := attachUserAuths(ctx, tx, u); err != nil {
+		return nil, err
+	}
+	u.ApiKey = ""
+
+	return u, tx.Commit()
+}
+
+func (s *UserAdminService) DeleteUser(ctx context.Context, id ksuid.KSUID) error {
+	if canerr := dots.CanDoAnything(ctx); canerr != nil {
+		return canerr
+	}
+
+	if dots.UserFromContext(ctx).ID == id {
+		return dots.Errorf(dots.ECONFLICT, "cannot delete yourself")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteTenantData(ctx, tx, id); err != nil {
+		return err
+	}
+
+	if err := deleteUser(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// deleteTenantData removes the personal organisation of a user, children first
+func deleteTenantData(ctx context.Context, tx *Tx, id ksuid.KSUID) error {
+	// row level security shows only the rows of the tenant being removed
+	_, err := tx.ExecContext(ctx, "SELECT set_config('app.uid', $1::core.ksuid, true)", id)
+	if err != nil {
+		return err
+	}
+
+	// count and transfer lines point to the deeds and entries they posted,
+	// tenant wide unit conversions are not gone with the entry types
+	tables := []string{
+		"inventory_count_line", "inventory_count", "transfer_line", "transfer",
+		"drain", "entry", "deed", "unit_conversion", "entry_type", "company",
+	}
+	for _, table := range tables {
+		_, err := tx.ExecContext(ctx, `delete from core.`+table+` where tid = $1`, id)
+		if err != nil {
+			return fmt.Errorf("postgres.user: cannot delete tenant %s: %w", table, err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from core.user_restriction where user_id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("postgres.user: cannot delete restrictions: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `delete from core.auth where user_id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("postgres.user: cannot delete auths: %w", err)
+	}
+	// shared organisations stay with their other members
+	_, err = tx.ExecContext(ctx, `delete from core.organisation where id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("postgres.user: cannot delete personal organisation: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package postgres_test
+
+import (
+	"testing"
+
+	"github.com/innermond/dots"
+	"github.com/innermond/dots/postgres"
+)
+
+func TestUserAdminService_DeleteUser(t *testing.T) {
+	t.Run("InventoryCount", func(t *testing.T) {
+		db := MustOpenDB(t, DSN)
+		defer MustCloseDB(t, db)
+
+		ctx, deleteTenant := MustCreateTenant(t, db)
+		cid, etid, _ := MustCreateStock(t, ctx, db, 10)
+
+		// a loss is written off through a deed of the count
+		approved := mustApproveCount(t, ctx, db, cid, etid, 7, dots.ReasonDamage)
+		if approved.Lines[0].DeedID == nil {
+			t.Fatal("expected a write-off deed")
+		}
+
+		deleteTenant()
+	})
+
+	t.Run("Transfer", func(t *testing.T) {
+		db := MustOpenDB(t, DSN)
+		defer MustCloseDB(t, db)
+
+		ctx, deleteTenant := MustCreateTenant(t, db)
+		MustSetPlan(t, ctx, db, "two eyes")
+		cid, etid, _ := MustCreateStock(t, ctx, db, 10)
+		to := &dots.Company{Longname: "TARGET", TIN: "TIN2", RN: "RN2"}
+		if err := postgres.NewCompanyService(db).CreateCompany(ctx, to); err != nil {
+			t.Fatal(err)
+		}
+
+		qty := 4.0
+		tr := &dots.Transfer{FromCompanyID: &cid, ToCompanyID: &to.ID, EntryTypeID: &etid, Quantity: &qty}
+		if err := postgres.NewTransferService(db).CreateTransfer(ctx, tr); err != nil {
+			t.Fatal(err)
+		}
+
+		deleteTenant()
+	})
+
+	t.Run("UnitConversion", func(t *testing.T) {
+		db := MustOpenDB(t, DSN)
+		defer MustCloseDB(t, db)
+
+		ctx, deleteTenant := MustCreateTenant(t, db)
+
+		from, to, factor := "box", "pcs", 12.0
+		uc := &dots.UnitConversion{From: &from, To: &to, Factor: &factor}
+		if err := postgres.NewUnitService(db).CreateUnitConversion(ctx, uc); err != nil {
+			t.Fatal(err)
+		}
+
+		deleteTenant()
+	})
+}
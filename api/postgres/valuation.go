@@ -0,0 +1,295 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/innermond/dots"
+	"github.com/shopspring/decimal"
+)
+
+type ValuationService struct {
+	db *DB
+}
+
+func NewValuationService(db *DB) *ValuationService {
+	return &ValuationService{db: db}
+}
+
+func (s *ValuationService) FindStockValue(ctx context.Context, filter dots.StockValueFilter) ([]*dots.StockValue, int, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, 0, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	return findStockValue(ctx, tx, filter)
+}
+
+func (s *ValuationService) FindDeedCost(ctx context.Context, id int, filter dots.DeedCostFilter) (*dots.DeedCost, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if canerr := dots.CanReadOwn(ctx); canerr != nil {
+		return nil, canerr
+	}
+
+	if err := tx.setUserIDPerConnection(ctx); err != nil {
+		return nil, err
+	}
+
+	return findDeedCost(ctx, tx, id, filter)
+}
+
+// stockPool gathers entries of a company having the same entry type
+type stockPool struct {
+	companyID   int
+	entryTypeID int
+}
+
+func findStockValue(ctx context.Context, tx *Tx, filter dots.StockValueFilter) ([]*dots.StockValue, int, error) {
+	asOf := time.Now()
+	if v := filter.AsOf; v != nil {
+		asOf = time.Time(*v)
+	}
+
+	moves, err := findStockMoves(ctx, tx, filter.CompanyID, filter.EntryTypeID, &asOf)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pools := make([]stockPool, 0, len(moves))
+	for p := range moves {
+		pools = append(pools, p)
+	}
+	sort.Slice(pools, func(i, j int) bool {
+		if pools[i].companyID != pools[j].companyID {
+			return pools[i].companyID < pools[j].companyID
+		}
+		return pools[i].entryTypeID < pools[j].entryTypeID
+	})
+
+	n := len(pools)
+	if filter.Offset > 0 {
+		if filter.Offset > len(pools) {
+			filter.Offset = len(pools)
+		}
+		pools = pools[filter.Offset:]
+	}
+	if filter.Limit > 0 && filter.Limit < len(pools) {
+		pools = pools[:filter.Limit]
+	}
+
+	values := []*dots.StockValue{}
+	for _, p := range pools {
+		left, value, _, err := dots.Valuate(filter.Method, moves[p])
+		if err != nil {
+			return nil, 0, err
+		}
+		values = append(values, &dots.StockValue{
+			CompanyID:   p.companyID,
+			EntryTypeID: p.entryTypeID,
+			Method:      filter.Method,
+			AsOf:        asOf,
+			Quantity:    aprox(left, 5),
+			Value:       value,
+		})
+	}
+
+	return values, n, nil
+}
+
+func findDeedCost(ctx context.Context, tx *Tx, id int, filter dots.DeedCostFilter) (*dots.DeedCost, error) {
+	var exists bool
+	err := tx.QueryRowContext(ctx, `select exists(select 1 from core.deed where id = $1)`, id).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, dots.Errorf(dots.ENOTFOUND, "deed not found")
+	}
+
+	pools, err := findDeedPools(ctx, tx, []string{"dd.id = $1"}, id)
+	if err != nil {
+		return nil, err
+	}
+	costs, err := costDrains(ctx, tx, pools, filter.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	dc := &dots.DeedCost{DeedID: id, Method: filter.Method, Cost: decimal.Zero, Drains: []*dots.DrainCost{}}
+	for _, c := range costs[id] {
+		dc.Cost = dc.Cost.Add(c.Cost)
+		dc.Uncosted += c.Uncosted
+		dc.Drains = append(dc.Drains, c)
+	}
+	dc.Uncosted = aprox(dc.Uncosted, 5)
+
+	return dc, nil
+}
+
+// findDeedPools lists the pools drained by deeds, dd, meeting where
+func findDeedPools(ctx context.Context, tx *Tx, where []string, args ...interface{}) ([]stockPool, error) {
+	where = append(append([]string{}, where...), "d.is_deleted = false")
+	rows, err := tx.QueryContext(ctx, `
+select distinct e.company_id, e.entry_type_id
+from core.drain d
+join core.entry e on e.id = d.entry_id
+join core.deed dd on dd.id = d.deed_id
+where `+strings.Join(where, " and "), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pools := []stockPool{}
+	for rows.Next() {
+		var p stockPool
+		if err := rows.Scan(&p.companyID, &p.entryTypeID); err != nil {
+			return nil, err
+		}
+		pools = append(pools, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pools, nil
+}
+
+// costDrains values pools on their whole history, as a drain
+// is costed by all that came in and went out before it, and gives the drains per deed
+func costDrains(ctx context.Context, tx *Tx, pools []stockPool, method string) (map[int][]*dots.DrainCost, error) {
+	costs := map[int][]*dots.DrainCost{}
+	for _, p := range pools {
+		p := p
+		moves, err := findStockMoves(ctx, tx, &p.companyID, &p.entryTypeID, nil)
+		if err != nil {
+			return nil, err
+		}
+		_, _, cc, err := dots.Valuate(method, moves[p])
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range cc {
+			costs[c.DeedID] = append(costs[c.DeedID], c)
+		}
+	}
+
+	return costs, nil
+}
+
+// findStockMoves reads entries and drains made up to as of, grouped by pool
+func findStockMoves(ctx context.Context, tx *Tx, companyID, entryTypeID *int, asOf *time.Time) (map[stockPool][]dots.StockMove, error) {
+	where, args := []string{}, []interface{}{}
+	if v := companyID; v != nil {
+		where, args = append(where, "e.company_id = ?"), append(args, *v)
+	}
+	if v := entryTypeID; v != nil {
+		where, args = append(where, "e.entry_type_id = ?"), append(args, *v)
+	}
+	entryWhere, drainWhere := where, append([]string{}, where...)
+	entryArgs, drainArgs := args, append([]interface{}{}, args...)
+	if asOf != nil {
+		entryWhere, entryArgs = append(entryWhere, "e.date_added <= ?"), append(entryArgs, *asOf)
+		drainWhere, drainArgs = append(drainWhere, "m.moved_at <= ?"), append(drainArgs, *asOf)
+	}
+
+	replaceQuestionMark(entryWhere, entryArgs)
+	replaceQuestionMark(drainWhere, drainArgs)
+	if asOf != nil {
+		// rows deleted later still stood at that time
+		p := fmt.Sprintf("$%d", len(entryArgs))
+		entryWhere = append(entryWhere, "(e.deleted_at is null or e.deleted_at > "+p+")")
+		drainWhere = append(drainWhere, "(e.deleted_at is null or e.deleted_at > "+p+")")
+	} else {
+		entryWhere = append(entryWhere, "e.deleted_at is null")
+		drainWhere = append(drainWhere, "e.deleted_at is null", "d.is_deleted = false")
+	}
+	entrystr := `
+select e.company_id, e.entry_type_id, e.id, coalesce(e.date_added, now()), e.quantity, coalesce(e.unitcost, 0)
+from core.entry e
+where ` + strings.Join(entryWhere, " and ")
+
+	drainstr := `
+select e.company_id, e.entry_type_id, d.entry_id, d.deed_id, d.drained_at, d.quantity
+from core.drain d
+join core.entry e on e.id = d.entry_id
+where ` + strings.Join(drainWhere, " and ")
+	if asOf != nil {
+		// drains as their movements left them by then, later edits do not reach back
+		drainstr = `
+select e.company_id, e.entry_type_id, m.entry_id, m.deed_id, min(m.moved_at), sum(m.quantity)
+from core.drain_movement m
+join core.entry e on e.id = m.entry_id
+where ` + strings.Join(drainWhere, " and ") + `
+group by e.company_id, e.entry_type_id, m.entry_id, m.deed_id
+having sum(m.quantity) > 1e-9`
+	}
+
+	moves := map[stockPool][]dots.StockMove{}
+
+	rows, err := tx.QueryContext(ctx, entrystr, entryArgs...)
+	if err != nil {
+		return nil, err
+	}
+	err = scanStockMoves(rows, moves, func(p *stockPool, m *dots.StockMove) []interface{} {
+		return []interface{}{&p.companyID, &p.entryTypeID, &m.EntryID, &m.At, &m.Quantity, &m.UnitCost}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.QueryContext(ctx, drainstr, drainArgs...)
+	if err != nil {
+		return nil, err
+	}
+	err = scanStockMoves(rows, moves, func(p *stockPool, m *dots.StockMove) []interface{} {
+		return []interface{}{&p.companyID, &p.entryTypeID, &m.EntryID, &m.DeedID, &m.At, &m.Quantity}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return moves, nil
+}
+
+func scanStockMoves(rows *sql.Rows, moves map[stockPool][]dots.StockMove, dest func(*stockPool, *dots.StockMove) []interface{}) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			p stockPool
+			m dots.StockMove
+		)
+		if err := rows.Scan(dest(&p, &m)...); err != nil {
+			return err
+		}
+		moves[p] = append(moves[p], m)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,73 @@
+package postgres_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/innermond/dots"
+	"github.com/innermond/dots/postgres"
+	"github.com/shopspring/decimal"
+)
+
+func TestValuationService(t *testing.T) {
+	db := MustOpenDB(t, DSN)
+	defer MustCloseDB(t, db)
+
+	ctx, deleteTenant := MustCreateTenant(t, db)
+	defer deleteTenant()
+	cid, etid, deedID := mustCreateSale(t, ctx, db)
+
+	tests := []struct {
+		method string
+		cost   string
+		value  string
+	}{
+		{dots.ValuationFIFO, "28", "32"},
+		{dots.ValuationLIFO, "44", "16"},
+		{dots.ValuationAverage, "36", "24"},
+	}
+
+	s := postgres.NewValuationService(db)
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			dc, err := s.FindDeedCost(ctx, deedID, dots.DeedCostFilter{Method: tt.method})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !dc.Cost.Equal(decimal.RequireFromString(tt.cost)) || dc.Uncosted != 0 {
+				t.Fatalf("cost=%v uncosted=%v, want %s", dc.Cost, dc.Uncosted, tt.cost)
+			}
+
+			vv, _, err := s.FindStockValue(ctx, dots.StockValueFilter{CompanyID: &cid, EntryTypeID: &etid, Method: tt.method})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(vv) != 1 || vv[0].Quantity != 8 || !vv[0].Value.Equal(decimal.RequireFromString(tt.value)) {
+				t.Fatalf("values=%+v, want 8 worth %s", vv, tt.value)
+			}
+		})
+	}
+
+	if _, err := s.FindDeedCost(ctx, deedID+1, dots.DeedCostFilter{}); dots.ErrorCode(err) != dots.ENOTFOUND {
+		t.Fatalf("err=%v, want %s", err, dots.ENOTFOUND)
+	}
+}
+
+// mustCreateSale stocks 10 at 2 then 10 at 4 and sells 4 for 10.5 each,
+// draining 12 that cost 28 fifo, 44 lifo and 36 on average
+func mustCreateSale(t *testing.T, ctx context.Context, db *postgres.DB) (cid, etid, deedID int) {
+	t.Helper()
+
+	cid, etid, first := MustCreateStock(t, ctx, db, 10)
+	cost := decimal.NewFromInt(2)
+	if _, err := postgres.NewEntryService(db).UpdateEntry(ctx, first, dots.EntryUpdate{UnitCost: &cost}); err != nil {
+		t.Fatal(err)
+	}
+	second := MustCreateEntry(t, ctx, db, cid, etid, 10, "4")
+
+	d := newDeed(cid, "SALE", 4, map[int]float64{first: 10, second: 2})
+	if err := postgres.NewDeedService(db).CreateDeed(ctx, &d); err != nil {
+		t.Fatal(err)
+	}
+	return cid, etid, *d.ID
+}
@@ -83,10 +83,9 @@ func (p *Power) UnmarshalJSON(b []byte) error {
 }
 
 func (p *Power) MarshalJSON() ([]byte, error) {
-	bb := p.Bytes()
-	if bb == nil {
+	if p.Bytes() == nil {
 		return nil, errors.New("marshaling power")
 	}
 
-	return bb, nil
+	return json.Marshal(p.String())
 }
@@ -0,0 +1,96 @@
+package dots
+
+import (
+	"context"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var hundred = decimal.NewFromInt(100)
+
+// Profit sets what a deed brings, its quantity times unit price,
+// against the material cost of its drains
+type Profit struct {
+	Revenue decimal.Decimal `json:"revenue"`
+	Cost    decimal.Decimal `json:"cost"`
+	Margin  decimal.Decimal `json:"margin"`
+	// MarginPercent is nil without revenue
+	MarginPercent *decimal.Decimal `json:"margin_percent"`
+	// Uncosted is the drained quantity the stock could not cover
+	Uncosted float64 `json:"uncosted,omitempty"`
+}
+
+// Add sums q into p, margins are settled again
+func (p *Profit) Add(q Profit) {
+	p.Revenue = p.Revenue.Add(q.Revenue)
+	p.Cost = p.Cost.Add(q.Cost)
+	p.Uncosted += q.Uncosted
+	p.Settle()
+}
+
+// Settle works out the margins from revenue and cost
+func (p *Profit) Settle() {
+	p.Margin = p.Revenue.Sub(p.Cost)
+	p.MarginPercent = nil
+	if !p.Revenue.IsZero() {
+		pct := p.Margin.Mul(hundred).Div(p.Revenue).Round(2)
+		p.MarginPercent = &pct
+	}
+}
+
+type DeedProfit struct {
+	DeedID    int       `json:"deed_id"`
+	CompanyID int       `json:"company_id"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+	Method    string    `json:"method"`
+	Profit
+}
+
+type CompanyProfit struct {
+	CompanyID int `json:"company_id"`
+	Profit
+}
+
+type MonthProfit struct {
+	// Month is as in 2023-04
+	Month string `json:"month"`
+	Profit
+}
+
+// ProfitReport gives the profit of deeds made in a period,
+// summed up per company and per month as well
+type ProfitReport struct {
+	Method    string           `json:"method"`
+	From      *time.Time       `json:"from,omitempty"`
+	To        *time.Time       `json:"to,omitempty"`
+	Deeds     []*DeedProfit    `json:"deeds"`
+	Companies []*CompanyProfit `json:"companies"`
+	Months    []*MonthProfit   `json:"months"`
+	Total     Profit           `json:"total"`
+}
+
+type ProfitFilter struct {
+	CompanyID *int `json:"company_id"`
+	// From is inclusive, To is not
+	From   *PartialTime `json:"from,omitempty"`
+	To     *PartialTime `json:"to,omitempty"`
+	Method string       `json:"method"`
+}
+
+// Validate defaults to fifo
+func (f *ProfitFilter) Validate() error {
+	if f.Method == "" {
+		f.Method = ValuationFIFO
+	}
+	if f.From != nil && f.To != nil && !time.Time(*f.From).Before(time.Time(*f.To)) {
+		return Errorf(EINVALID, "from must be before to")
+	}
+	return validValuation(f.Method)
+}
+
+type ProfitService interface {
+	FindDeedProfit(context.Context, int, DeedCostFilter) (*DeedProfit, error)
+	FindProfitReport(context.Context, ProfitFilter) (*ProfitReport, error)
+}
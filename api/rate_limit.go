@@ -0,0 +1,84 @@
+package dots
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit is a token bucket holding at most Burst tokens,
+// refilled with Requests tokens every Per
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+// Interval is the time it takes to get a token back
+func (l RateLimit) Interval() time.Duration {
+	return l.Per / time.Duration(l.Requests)
+}
+
+// FullIn tells how long a bucket left with tokens takes to get back to burst,
+// after that it is as good as a new one
+func (l RateLimit) FullIn(tokens float64) time.Duration {
+	return time.Duration((float64(l.Burst) - tokens) * float64(l.Interval()))
+}
+
+func (l RateLimit) Validate() error {
+	if l.Requests < 1 || l.Per <= 0 || l.Burst < 1 {
+		return Errorf(EINVALID, "rate limit needs positive requests, period and burst")
+	}
+	return nil
+}
+
+// Take refills a bucket left with tokens elapsed ago and takes one token from it,
+// without a whole token retry tells how long to wait for one
+func (l RateLimit) Take(tokens float64, elapsed time.Duration) (left float64, ok bool, retry time.Duration) {
+	interval := l.Interval()
+	tokens += float64(elapsed) / float64(interval)
+	if tokens > float64(l.Burst) {
+		tokens = float64(l.Burst)
+	}
+
+	if tokens < 1 {
+		return tokens, false, time.Duration((1 - tokens) * float64(interval))
+	}
+	return tokens - 1, true, 0
+}
+
+// ParseRateLimit reads "requests/period" or "requests/period:burst" as in "10/1m:20",
+// burst defaults to requests
+func ParseRateLimit(s string) (RateLimit, error) {
+	var l RateLimit
+
+	spec, burst, hasBurst := strings.Cut(s, ":")
+	requests, per, found := strings.Cut(spec, "/")
+	if !found {
+		return l, Errorf(EINVALID, "rate limit %q: want requests/period", s)
+	}
+
+	var err error
+	if l.Requests, err = strconv.Atoi(requests); err != nil {
+		return l, Errorf(EINVALID, "rate limit %q: bad requests", s)
+	}
+	if l.Per, err = time.ParseDuration(per); err != nil {
+		return l, Errorf(EINVALID, "rate limit %q: bad period", s)
+	}
+	l.Burst = l.Requests
+	if hasBurst {
+		if l.Burst, err = strconv.Atoi(burst); err != nil {
+			return l, Errorf(EINVALID, "rate limit %q: bad burst", s)
+		}
+	}
+
+	return l, l.Validate()
+}
+
+// RateLimiter keeps the buckets, a shared store lets many servers count together
+type RateLimiter interface {
+	// Allow takes a token from the bucket of key,
+	// when there is none retry tells how long to wait
+	Allow(ctx context.Context, key string, limit RateLimit) (ok bool, retry time.Duration, err error)
+}
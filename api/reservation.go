@@ -0,0 +1,123 @@
+package dots
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// ReservationTTL is how long a reservation holds when it is not told
+const ReservationTTL = 7 * 24 * time.Hour
+
+// Reservation holds a quantity for a deed without draining it, so that other deeds
+// cannot take it. It holds either an entry or any entries of an entry type
+// of the company of the deed, till it expires or the deed is confirmed or cancelled
+type Reservation struct {
+	ID          int        `json:"id"`
+	DeedID      *int       `json:"deed_id"`
+	EntryID     *int       `json:"entry_id,omitempty"`
+	EntryTypeID *int       `json:"entry_type_id,omitempty"`
+	Quantity    *float64   `json:"quantity"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+
+	CompanyID int       `json:"company_id"`
+	CreatedAt time.Time `json:"created_at"`
+	// Expired reservations hold nothing, confirming the deed leaves them out
+	Expired bool `json:"expired"`
+}
+
+func (r *Reservation) Validate() error {
+	if r.DeedID == nil || r.Quantity == nil {
+		return Errorf(EINVALID, "deed and quantity are required")
+	}
+	if (r.EntryID == nil) == (r.EntryTypeID == nil) {
+		return Errorf(EINVALID, "either an entry or an entry type is required")
+	}
+	if *r.Quantity <= 0 {
+		return Errorf(EINVALID, "quantity must be greater than zero")
+	}
+	if r.ExpiresAt == nil {
+		at := time.Now().Add(ReservationTTL)
+		r.ExpiresAt = &at
+	}
+	if !r.ExpiresAt.After(time.Now()) {
+		return Errorf(EINVALID, "expires at must be in the future")
+	}
+	return nil
+}
+
+type ReservationFilter struct {
+	ID          *int  `json:"id"`
+	DeedID      *int  `json:"deed_id"`
+	CompanyID   *int  `json:"company_id"`
+	EntryID     *int  `json:"entry_id"`
+	EntryTypeID *int  `json:"entry_type_id"`
+	IsExpired   *bool `json:"is_expired"`
+
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// ReservationConfirmed is what confirming a deed drained, per entry
+type ReservationConfirmed struct {
+	DeedID     int             `json:"deed_id"`
+	Distribute map[int]float64 `json:"distribute"`
+}
+
+// Held sums what live reservations hold, per entry and per entry type; expired ones hold nothing
+func Held(rr []*Reservation) (byEntry, byEntryType map[int]float64) {
+	byEntry, byEntryType = map[int]float64{}, map[int]float64{}
+	for _, r := range rr {
+		if r.Expired || r.Quantity == nil {
+			continue
+		}
+		if r.EntryID != nil {
+			byEntry[*r.EntryID] += *r.Quantity
+		} else if r.EntryTypeID != nil {
+			byEntryType[*r.EntryTypeID] += *r.Quantity
+		}
+	}
+	return byEntry, byEntryType
+}
+
+// GiveUp takes over out of the reservations when the stock they hold is lost, the latest made first.
+// It tells what each of the reservations it touched keeps, the ones keeping nothing are to be let go
+func GiveUp(rr []*Reservation, over float64) map[int]float64 {
+	latest := make([]*Reservation, 0, len(rr))
+	for _, r := range rr {
+		if !r.Expired && r.Quantity != nil {
+			latest = append(latest, r)
+		}
+	}
+	sort.SliceStable(latest, func(i, j int) bool {
+		a, b := latest[i], latest[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID > b.ID
+	})
+
+	kept := map[int]float64{}
+	for _, r := range latest {
+		if over <= stockEpsilon {
+			break
+		}
+		took := *r.Quantity
+		if over < took {
+			took = over
+		}
+		kept[r.ID] = *r.Quantity - took
+		over -= took
+	}
+	return kept
+}
+
+type ReservationService interface {
+	CreateReservation(context.Context, *Reservation) error
+	FindReservation(context.Context, ReservationFilter) ([]*Reservation, int, error)
+	DeleteReservation(context.Context, int) (int, error)
+	// ConfirmReservation turns what a deed holds into drains
+	ConfirmReservation(context.Context, int) (*ReservationConfirmed, error)
+	// CancelReservation lets go all a deed holds
+	CancelReservation(context.Context, int) (int, error)
+}
@@ -0,0 +1,64 @@
+package dots
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHeld(t *testing.T) {
+	eid, etid := 1, 2
+	qty := func(v float64) *float64 { return &v }
+	rr := []*Reservation{
+		{ID: 1, EntryID: &eid, Quantity: qty(2)},
+		{ID: 2, EntryID: &eid, Quantity: qty(1.5)},
+		{ID: 3, EntryTypeID: &etid, Quantity: qty(4)},
+		// confirming leaves expired ones out
+		{ID: 4, EntryTypeID: &etid, Quantity: qty(10), Expired: true},
+	}
+
+	byEntry, byEntryType := Held(rr)
+	if !reflect.DeepEqual(byEntry, map[int]float64{1: 3.5}) {
+		t.Fatalf("by entry=%v", byEntry)
+	}
+	if !reflect.DeepEqual(byEntryType, map[int]float64{2: 4}) {
+		t.Fatalf("by entry type=%v", byEntryType)
+	}
+
+	byEntry, byEntryType = Held(nil)
+	if len(byEntry) != 0 || len(byEntryType) != 0 {
+		t.Fatalf("held %v %v out of nothing", byEntry, byEntryType)
+	}
+}
+
+func TestGiveUp(t *testing.T) {
+	t0 := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	qty := func(v float64) *float64 { return &v }
+	rr := []*Reservation{
+		{ID: 1, Quantity: qty(5), CreatedAt: t0},
+		{ID: 2, Quantity: qty(3), CreatedAt: t0.Add(time.Hour)},
+		{ID: 3, Quantity: qty(2), CreatedAt: t0.Add(time.Hour)},
+		{ID: 4, Quantity: qty(9), CreatedAt: t0.Add(2 * time.Hour), Expired: true},
+	}
+
+	tests := []struct {
+		name string
+		over float64
+		kept map[int]float64
+	}{
+		{"nothing over", 0, map[int]float64{}},
+		{"the latest shrinks", 1, map[int]float64{3: 1}},
+		{"the latest is let go", 2, map[int]float64{3: 0}},
+		{"same time goes by id", 4, map[int]float64{3: 0, 2: 1}},
+		{"down to the oldest", 7, map[int]float64{3: 0, 2: 0, 1: 3}},
+		{"more than held lets all go", 20, map[int]float64{3: 0, 2: 0, 1: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if kept := GiveUp(rr, tt.over); !reflect.DeepEqual(kept, tt.kept) {
+				t.Fatalf("kept=%v, want %v", kept, tt.kept)
+			}
+		})
+	}
+}
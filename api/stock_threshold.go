@@ -0,0 +1,90 @@
+package dots
+
+import (
+	"context"
+	"time"
+)
+
+// StockThreshold tells when an entry type runs low, below ReorderLevel,
+// and up to where it is worth refilling, TargetLevel
+type StockThreshold struct {
+	ID          int  `json:"id"`
+	EntryTypeID *int `json:"entry_type_id"`
+	// CompanyID is nil for a threshold every company falls back to
+	CompanyID    *int     `json:"company_id"`
+	ReorderLevel *float64 `json:"reorder_level"`
+	TargetLevel  *float64 `json:"target_level"`
+}
+
+func (st *StockThreshold) Validate() error {
+	if st.EntryTypeID == nil || st.ReorderLevel == nil {
+		return Errorf(EINVALID, "entry type and reorder level are required")
+	}
+	if st.TargetLevel == nil {
+		st.TargetLevel = st.ReorderLevel
+	}
+	return validLevels(*st.ReorderLevel, *st.TargetLevel)
+}
+
+func validLevels(reorder, target float64) error {
+	if reorder < 0 {
+		return Errorf(EINVALID, "reorder level cannot be negative")
+	}
+	if target < reorder {
+		return Errorf(EINVALID, "target level cannot be below reorder level")
+	}
+	return nil
+}
+
+type StockThresholdUpdate struct {
+	ReorderLevel *float64 `json:"reorder_level"`
+	TargetLevel  *float64 `json:"target_level"`
+}
+
+func (u *StockThresholdUpdate) Validate() error {
+	if u.ReorderLevel == nil && u.TargetLevel == nil {
+		return Errorf(EINVALID, "reorder or target level required")
+	}
+	return nil
+}
+
+type StockThresholdFilter struct {
+	ID          *int `json:"id"`
+	EntryTypeID *int `json:"entry_type_id"`
+	CompanyID   *int `json:"company_id"`
+
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// StockAlert is raised when a drain takes the stock below its reorder level
+type StockAlert struct {
+	ID          int  `json:"id"`
+	CompanyID   int  `json:"company_id"`
+	EntryTypeID int  `json:"entry_type_id"`
+	DeedID      *int `json:"deed_id"`
+	EntryID     *int `json:"entry_id"`
+	// Quantity is the stock left after the drain
+	Quantity     float64   `json:"quantity"`
+	ReorderLevel float64   `json:"reorder_level"`
+	TargetLevel  float64   `json:"target_level"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type StockAlertFilter struct {
+	CompanyID   *int         `json:"company_id"`
+	EntryTypeID *int         `json:"entry_type_id"`
+	From        *PartialTime `json:"from,omitempty"`
+	To          *PartialTime `json:"to,omitempty"`
+
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+type StockThresholdService interface {
+	CreateStockThreshold(context.Context, *StockThreshold) error
+	UpdateStockThreshold(context.Context, int, StockThresholdUpdate) (*StockThreshold, error)
+	FindStockThreshold(context.Context, StockThresholdFilter) ([]*StockThreshold, int, error)
+	DeleteStockThreshold(context.Context, int) (int, error)
+	FindStockAlert(context.Context, StockAlertFilter) ([]*StockAlert, int, error)
+}
@@ -0,0 +1,65 @@
+package dots
+
+import (
+	"context"
+	"time"
+)
+
+// Transfer moves a quantity of an entry type from a company to another of the same tenant.
+// The source entries are drained by the deed of the transfer and every piece taken
+// becomes a new entry of the target company, at the same unit cost
+type Transfer struct {
+	ID            int      `json:"id"`
+	FromCompanyID *int     `json:"from_company_id"`
+	ToCompanyID   *int     `json:"to_company_id"`
+	EntryTypeID   *int     `json:"entry_type_id"`
+	Quantity      *float64 `json:"quantity"`
+	Note          *string  `json:"note,omitempty"`
+	// Strategy picks the source entries as distribution does, the company default
+	// or else oldest first when not given
+	Strategy *DistributeDrain `json:"strategy,omitempty"`
+
+	DeedID    int             `json:"deed_id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Lines     []*TransferLine `json:"lines"`
+}
+
+func (t *Transfer) Validate() error {
+	if t.FromCompanyID == nil || t.ToCompanyID == nil || t.EntryTypeID == nil || t.Quantity == nil {
+		return Errorf(EINVALID, "from company, to company, entry type and quantity are required")
+	}
+	if *t.FromCompanyID == *t.ToCompanyID {
+		return Errorf(EINVALID, "cannot transfer to the same company")
+	}
+	if *t.Quantity <= 0 {
+		return Errorf(EINVALID, "quantity must be greater than zero")
+	}
+	if t.Strategy != nil {
+		return t.Strategy.Validate()
+	}
+	return nil
+}
+
+// TransferLine is what a transfer took from a source entry into a target entry
+type TransferLine struct {
+	SourceEntryID int     `json:"source_entry_id"`
+	TargetEntryID int     `json:"target_entry_id"`
+	Quantity      float64 `json:"quantity"`
+}
+
+type TransferFilter struct {
+	ID *int `json:"id"`
+	// CompanyID matches both ends of a transfer
+	CompanyID   *int         `json:"company_id"`
+	EntryTypeID *int         `json:"entry_type_id"`
+	From        *PartialTime `json:"from,omitempty"`
+	To          *PartialTime `json:"to,omitempty"`
+
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+type TransferService interface {
+	CreateTransfer(context.Context, *Transfer) error
+	FindTransfer(context.Context, TransferFilter) ([]*Transfer, int, error)
+}
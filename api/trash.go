@@ -0,0 +1,15 @@
+package dots
+
+// TrashRestore brings soft deleted items back in one go, all or none
+type TrashRestore struct {
+	ID []int `json:"id"`
+	// Undrain gives back the drains of restored deeds
+	Undrain bool `json:"undrain"`
+}
+
+func (tr TrashRestore) Validate() error {
+	if len(tr.ID) == 0 {
+		return Errorf(EINVALID, "nothing to restore")
+	}
+	return nil
+}
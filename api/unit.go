@@ -0,0 +1,83 @@
+package dots
+
+import (
+	"context"
+	"strings"
+)
+
+// Unit is a unit of measure known by its UN/ECE Recommendation 20 code
+type Unit struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+	// Aliases are the free text units taken as this one, as pcs or buc for H87
+	Aliases []string `json:"aliases"`
+}
+
+// UnitConversion turns a quantity in From into To by multiplying it with Factor;
+// it is read backwards as well. Without an entry type it holds for all of them
+type UnitConversion struct {
+	ID          int      `json:"id"`
+	From        *string  `json:"from"`
+	To          *string  `json:"to"`
+	Factor      *float64 `json:"factor"`
+	EntryTypeID *int     `json:"entry_type_id"`
+	// Shared conversions come with the catalog and cannot be changed
+	Shared bool `json:"shared"`
+}
+
+func (uc *UnitConversion) Validate() error {
+	if uc.From == nil || uc.To == nil || uc.Factor == nil {
+		return Errorf(EINVALID, "from, to and factor are required")
+	}
+	from, to := strings.TrimSpace(*uc.From), strings.TrimSpace(*uc.To)
+	if from == "" || to == "" {
+		return Errorf(EINVALID, "from and to must not be empty")
+	}
+	if strings.EqualFold(from, to) {
+		return Errorf(EINVALID, "from and to must differ")
+	}
+	if *uc.Factor <= 0 {
+		return Errorf(EINVALID, "factor must be positive")
+	}
+	uc.From, uc.To = &from, &to
+	return nil
+}
+
+type UnitConversionFilter struct {
+	Unit        *string `json:"unit"`
+	EntryTypeID *int    `json:"entry_type_id"`
+
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// UnitConvert asks for a quantity in another unit, the entry type picks its own conversions
+type UnitConvert struct {
+	Quantity    *float64 `json:"quantity"`
+	From        *string  `json:"from"`
+	To          *string  `json:"to"`
+	EntryTypeID *int     `json:"entry_type_id"`
+}
+
+func (uc *UnitConvert) Validate() error {
+	if uc.Quantity == nil || uc.From == nil || uc.To == nil {
+		return Errorf(EINVALID, "quantity, from and to are required")
+	}
+	return nil
+}
+
+type UnitConverted struct {
+	Quantity float64 `json:"quantity"`
+	// From and To are the codes the units were resolved to
+	From   string  `json:"from"`
+	To     string  `json:"to"`
+	Factor float64 `json:"factor"`
+}
+
+type UnitService interface {
+	FindUnit(context.Context) ([]*Unit, int, error)
+	CreateUnitConversion(context.Context, *UnitConversion) error
+	FindUnitConversion(context.Context, UnitConversionFilter) ([]*UnitConversion, int, error)
+	DeleteUnitConversion(context.Context, int) (int, error)
+	ConvertUnit(context.Context, UnitConvert) (*UnitConverted, error)
+}
@@ -5,6 +5,8 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"net/mail"
+	"strings"
 	"time"
 
 	"github.com/segmentio/ksuid"
@@ -19,6 +21,12 @@ type User struct {
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// DisabledAt marks an account not allowed to login anymore
+	DisabledAt *time.Time `json:"disabled_at"`
+
+	// OrganisationID is the active organisation and Role the user's role in it
+	OrganisationID ksuid.KSUID `json:"organisation_id"`
+	Role           Role        `json:"role,omitempty"`
 
 	Auths []*Auth `json:"auths"`
 }
@@ -27,6 +35,9 @@ type UserFilter struct {
 	ID     *ksuid.KSUID `json:"id"`
 	Email  *string      `json:"email"`
 	ApiKey *string      `json:"api_key"`
+	// Search looks into name and email
+	Search     *string `json:"q"`
+	IsDisabled *bool   `json:"is_disabled"`
 
 	Offset int `json:"offset"`
 	Limit  int `json:"limit"`
@@ -37,6 +48,76 @@ type UserUpdate struct {
 	Email *string `json:"email"`
 }
 
+func (uu *UserUpdate) Validate() error {
+	if uu.Name == nil && uu.Email == nil {
+		return Errorf(EINVALID, "at least one of name or email is required")
+	}
+
+	if uu.Name != nil {
+		name := strings.TrimSpace(*uu.Name)
+		uu.Name = &name
+	}
+	if uu.Email != nil {
+		email := strings.ToLower(strings.TrimSpace(*uu.Email))
+		uu.Email = &email
+	}
+
+	err := printable(map[string]*string{"name": uu.Name, "email": uu.Email})
+	if err != nil {
+		return err
+	}
+
+	if uu.Email != nil {
+		if _, err := mail.ParseAddress(*uu.Email); err != nil {
+			return Errorf(EINVALID, "invalid email")
+		}
+	}
+
+	return nil
+}
+
+// UserAdminUpdate is what an administrator may change on any account
+type UserAdminUpdate struct {
+	UserUpdate
+
+	Grant    []Power `json:"grant"`
+	Revoke   []Power `json:"revoke"`
+	Disabled *bool   `json:"disabled"`
+}
+
+func (ua *UserAdminUpdate) Validate() error {
+	if ua.Name == nil && ua.Email == nil && len(ua.Grant) == 0 && len(ua.Revoke) == 0 && ua.Disabled == nil {
+		return Errorf(EINVALID, "nothing to update")
+	}
+
+	for _, p := range ua.Grant {
+		if PowersContains(ua.Revoke, p) {
+			return Errorf(EINVALID, "power %s both granted and revoked", p)
+		}
+	}
+
+	if ua.Name == nil && ua.Email == nil {
+		return nil
+	}
+	return ua.UserUpdate.Validate()
+}
+
+// Apply returns powers after grants and revokes
+func (ua *UserAdminUpdate) Apply(powers []Power) []Power {
+	pp := []Power{}
+	for _, p := range powers {
+		if !PowersContains(ua.Revoke, p) && !PowersContains(pp, p) {
+			pp = append(pp, p)
+		}
+	}
+	for _, p := range ua.Grant {
+		if !PowersContains(pp, p) {
+			pp = append(pp, p)
+		}
+	}
+	return pp
+}
+
 func (u *User) ValidateCreate() error {
 	// TODO regex for detecting white spaces
 	if u.Name == "" {
@@ -57,10 +138,32 @@ func UserIsZero(u *User) bool {
 		u.UpdatedAt.IsZero()
 }
 
+// TenantID is the organisation whose data the user works on,
+// the personal organisation when none was selected
+func (u *User) TenantID() ksuid.KSUID {
+	if !u.OrganisationID.IsNil() {
+		return u.OrganisationID
+	}
+	return u.ID
+}
+
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
+}
+
 type UserService interface {
 	CreateUser(context.Context, *User) error
 	FindUser(context.Context, UserFilter) ([]*User, int, error)
 	FindUserByID(context.Context, ksuid.KSUID) (*User, error)
+	UpdateUser(context.Context, ksuid.KSUID, UserUpdate) (*User, error)
+}
+
+// UserAdminService manages every account, it requires DoAnything
+type UserAdminService interface {
+	FindUser(context.Context, UserFilter) ([]*User, int, error)
+	UpdateUser(context.Context, ksuid.KSUID, UserAdminUpdate) (*User, error)
+	// DeleteUser removes the account together with its tenant data
+	DeleteUser(context.Context, ksuid.KSUID) error
 }
 
 func (u User) Value() (driver.Value, error) {
@@ -107,6 +210,7 @@ func (u *User) UnmarshalJSON(b []byte) error {
 	u.Email = user.Email
 	u.ApiKey = user.ApiKey
 	u.Powers = user.Powers
+	u.DisabledAt = user.DisabledAt
 	u.CreatedAt = createdAt
 	u.UpdatedAt = updatedAt
 
@@ -0,0 +1,209 @@
+package dots
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+type validator interface {
+	Validate() error
+}
+
+// mustValidate decodes body into v as a request would and validates it
+func mustValidate(t *testing.T, v validator, body string) error {
+	t.Helper()
+
+	if err := json.Unmarshal([]byte(body), v); err != nil {
+		t.Fatalf("%s: %v", body, err)
+	}
+	return v.Validate()
+}
+
+func TestValidate_invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		new  func() validator
+		body []string
+	}{
+		{"company setting", func() validator { return &CompanySettingUpdate{} }, []string{
+			`{}`,
+			`{"distribute_strategy": "newest"}`,
+		}},
+		{"deed", func() validator { return &Deed{} }, []string{
+			`{"distribute_strategy": "old"}`,
+			`{"distribute_strategy": "NEW_MANY"}`,
+		}},
+		{"distribute preview", func() validator { return &DistributePreview{} }, []string{
+			`{"entry_type_distribute": {"2": 1}}`,
+			`{"company_id": 1}`,
+			`{"company_id": 1, "entry_type_distribute": {"2": 0}}`,
+			`{"company_id": 1, "entry_type_distribute": {"2": 1}, "distribute_strategy": "lifo"}`,
+		}},
+		{"drain", func() validator { return &DrainUpdate{} }, []string{
+			`{}`,
+			`{"quantity": 0}`,
+		}},
+		{"inventory count", func() validator { return &InventoryCountUpdate{} }, []string{
+			`{"lines": []}`,
+			`{"lines": [{"company_id": 1, "entry_type_id": 2, "counted": -1}]}`,
+			`{"lines": [{"company_id": 1, "entry_type_id": 2, "counted": 1, "reason": "lost"}]}`,
+			`{"lines": [{"company_id": 1, "counted": 1}]}`,
+		}},
+		{"profit", func() validator { return &ProfitFilter{} }, []string{
+			`{"from": "2023-04", "to": "2023-01"}`,
+			`{"method": "newest"}`,
+		}},
+		{"stock value", func() validator { return &StockValueFilter{} }, []string{
+			`{"method": "newest"}`,
+		}},
+		{"reservation", func() validator { return &Reservation{} }, []string{
+			`{"deed_id": 3, "quantity": 4}`,
+			`{"deed_id": 3, "entry_id": 5, "entry_type_id": 2, "quantity": 4}`,
+			`{"deed_id": 3, "entry_id": 5, "quantity": 0}`,
+			`{"entry_id": 5, "quantity": 1}`,
+			`{"deed_id": 3, "entry_id": 5, "quantity": 1, "expires_at": "2020-01-01T00:00:00Z"}`,
+		}},
+		{"expiring", func() validator { return &ExpiringFilter{} }, []string{
+			`{"days": -1}`,
+		}},
+		{"forecast", func() validator { return &ForecastFilter{} }, []string{
+			`{"method": "median"}`,
+			`{"window": 400}`,
+			`{"alpha": 0}`,
+		}},
+		{"stock threshold", func() validator { return &StockThreshold{} }, []string{
+			`{"reorder_level": 5}`,
+			`{"entry_type_id": 2, "company_id": 1, "reorder_level": 5, "target_level": 4}`,
+		}},
+		{"transfer", func() validator { return &Transfer{} }, []string{
+			`{"from_company_id": 1, "to_company_id": 1, "entry_type_id": 4, "quantity": 1}`,
+			`{"from_company_id": 1, "to_company_id": 2, "entry_type_id": 4, "quantity": 0}`,
+			`{"from_company_id": 1, "entry_type_id": 4, "quantity": 1}`,
+			`{"from_company_id": 1, "to_company_id": 2, "entry_type_id": 4, "quantity": 1, "strategy": "random"}`,
+		}},
+		{"restore", func() validator { return &TrashRestore{} }, []string{
+			`{"id": []}`,
+		}},
+		{"unit convert", func() validator { return &UnitConvert{} }, []string{
+			`{"from": "rola", "to": "m2"}`,
+		}},
+		{"unit conversion", func() validator { return &UnitConversion{} }, []string{
+			`{"from": "m", "to": "M", "factor": 1}`,
+			`{"from": "m", "to": "cm", "factor": 0}`,
+			`{"from": "m", "factor": 100}`,
+		}},
+		{"register", func() validator { return &UserRegister{} }, []string{
+			`{"name": "N", "email": "n@example.com", "pwd": "short"}`,
+			`{"name": "N", "email": "n@example.com", "pwd": "` + strings.Repeat("p", PasswordMaxBytes+1) + `"}`,
+			`{"name": "N", "email": "n@example.com", "pwd": "` + strings.Repeat("ă", PasswordMaxBytes/2+1) + `"}`,
+		}},
+		{"password change", func() validator { return &PasswordChange{} }, []string{
+			`{"old": "password", "new": "` + strings.Repeat("p", PasswordMaxBytes+1) + `"}`,
+		}},
+		{"password reset", func() validator { return &PasswordReset{} }, []string{
+			`{"token": "T", "pwd": "` + strings.Repeat("p", PasswordMaxBytes+1) + `"}`,
+		}},
+		{"user admin", func() validator { return &UserAdminUpdate{} }, []string{
+			`{}`,
+			`{"grant": ["read_own"], "revoke": ["read_own"]}`,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, body := range tt.body {
+				err := mustValidate(t, tt.new(), body)
+				if ErrorCode(err) != EINVALID {
+					t.Fatalf("%s: err=%v, want %s", body, err, EINVALID)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate_defaults(t *testing.T) {
+	t.Run("company setting clears the strategy", func(t *testing.T) {
+		if err := mustValidate(t, &CompanySettingUpdate{}, `{"distribute_strategy": ""}`); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("password fills what bcrypt hashes", func(t *testing.T) {
+		body := `{"name": "N", "email": "n@example.com", "pwd": "` + strings.Repeat("p", PasswordMaxBytes) + `"}`
+		if err := mustValidate(t, &UserRegister{}, body); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("profit is fifo", func(t *testing.T) {
+		var f ProfitFilter
+		if err := mustValidate(t, &f, `{"from": "2023-01", "to": "2023-04"}`); err != nil {
+			t.Fatal(err)
+		}
+		if f.Method != ValuationFIFO {
+			t.Fatalf("method=%s, want %s", f.Method, ValuationFIFO)
+		}
+	})
+
+	t.Run("deed cost is fifo", func(t *testing.T) {
+		var f DeedCostFilter
+		if err := mustValidate(t, &f, `{}`); err != nil {
+			t.Fatal(err)
+		}
+		if f.Method != ValuationFIFO {
+			t.Fatalf("method=%s, want %s", f.Method, ValuationFIFO)
+		}
+	})
+
+	t.Run("reservation expires after its ttl", func(t *testing.T) {
+		var r Reservation
+		if err := mustValidate(t, &r, `{"deed_id": 3, "entry_type_id": 2, "quantity": 4}`); err != nil {
+			t.Fatal(err)
+		}
+		if r.ExpiresAt == nil || r.ExpiresAt.Before(time.Now().Add(ReservationTTL-time.Minute)) {
+			t.Fatalf("expires at=%v, want in %v", r.ExpiresAt, ReservationTTL)
+		}
+	})
+
+	t.Run("expiring looks 30 days ahead", func(t *testing.T) {
+		var f ExpiringFilter
+		if err := mustValidate(t, &f, `{"company_id": 1}`); err != nil {
+			t.Fatal(err)
+		}
+		if f.Days == nil || *f.Days != 30 {
+			t.Fatalf("days=%v, want 30", f.Days)
+		}
+	})
+
+	t.Run("forecast is a moving average", func(t *testing.T) {
+		var f ForecastFilter
+		if err := mustValidate(t, &f, `{"company_id": 1}`); err != nil {
+			t.Fatal(err)
+		}
+		if f.Method != ForecastMovingAverage || f.Window != forecastWindow || f.Alpha == nil || *f.Alpha != forecastAlpha {
+			t.Fatalf("filter=%+v", f)
+		}
+	})
+
+	t.Run("stock threshold targets its reorder level", func(t *testing.T) {
+		var st StockThreshold
+		if err := mustValidate(t, &st, `{"entry_type_id": 2, "reorder_level": 5}`); err != nil {
+			t.Fatal(err)
+		}
+		if st.TargetLevel == nil || *st.TargetLevel != 5 {
+			t.Fatalf("target level=%v, want 5", st.TargetLevel)
+		}
+	})
+
+	t.Run("unit conversion is trimmed", func(t *testing.T) {
+		var uc UnitConversion
+		if err := mustValidate(t, &uc, `{"from": " rola ", "to": "m2", "factor": 25}`); err != nil {
+			t.Fatal(err)
+		}
+		if *uc.From != "rola" {
+			t.Fatalf("from=%q, want rola", *uc.From)
+		}
+	})
+}
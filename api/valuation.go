@@ -0,0 +1,189 @@
+package dots
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ways of pricing what leaves the stock
+const (
+	ValuationFIFO    = "fifo"
+	ValuationLIFO    = "lifo"
+	ValuationAverage = "average"
+)
+
+// quantities closer than this to zero are taken as gone
+const stockEpsilon = 1e-9
+
+func validValuation(method string) error {
+	switch method {
+	case ValuationFIFO, ValuationLIFO, ValuationAverage:
+		return nil
+	}
+	return Errorf(EINVALID, "unknown valuation method %q, want fifo, lifo or average", method)
+}
+
+// StockMove is an entry coming into or a drain going out of a stock pool,
+// a pool being the entries of a company having the same entry type
+type StockMove struct {
+	At      time.Time
+	EntryID int
+	// DeedID is zero for entries
+	DeedID   int
+	Quantity float64
+	// UnitCost is what a unit of an entry cost, drains have none
+	UnitCost decimal.Decimal
+}
+
+func (m StockMove) isEntry() bool {
+	return m.DeedID == 0
+}
+
+// DrainCost is what a drain took out of stock valued by a method
+type DrainCost struct {
+	DeedID   int             `json:"deed_id"`
+	EntryID  int             `json:"entry_id"`
+	Quantity float64         `json:"quantity"`
+	Cost     decimal.Decimal `json:"cost"`
+	// Uncosted is the quantity taken while the pool had none left
+	Uncosted float64 `json:"uncosted,omitempty"`
+}
+
+type stockLayer struct {
+	quantity float64
+	unitCost decimal.Decimal
+}
+
+// Valuate walks the moves of a single pool in time, entries go first when at the same time.
+// It tells what is left in stock, its value and the cost of every drain
+func Valuate(method string, moves []StockMove) (left float64, value decimal.Decimal, costs []*DrainCost, err error) {
+	if err := validValuation(method); err != nil {
+		return 0, decimal.Zero, nil, err
+	}
+
+	sorted := make([]StockMove, len(moves))
+	copy(sorted, moves)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if !a.At.Equal(b.At) {
+			return a.At.Before(b.At)
+		}
+		if a.isEntry() != b.isEntry() {
+			return a.isEntry()
+		}
+		if a.EntryID != b.EntryID {
+			return a.EntryID < b.EntryID
+		}
+		return a.DeedID < b.DeedID
+	})
+
+	// average keeps a single layer
+	layers := []stockLayer{}
+	for _, m := range sorted {
+		if m.isEntry() {
+			if method == ValuationAverage && len(layers) > 0 {
+				l := &layers[0]
+				total := l.unitCost.Mul(decimal.NewFromFloat(l.quantity)).Add(m.UnitCost.Mul(decimal.NewFromFloat(m.Quantity)))
+				l.quantity += m.Quantity
+				l.unitCost = total.Div(decimal.NewFromFloat(l.quantity))
+				continue
+			}
+			layers = append(layers, stockLayer{quantity: m.Quantity, unitCost: m.UnitCost})
+			continue
+		}
+
+		dc := &DrainCost{DeedID: m.DeedID, EntryID: m.EntryID, Quantity: m.Quantity, Cost: decimal.Zero}
+		need := m.Quantity
+		for need > stockEpsilon && len(layers) > 0 {
+			// lifo takes from the newest layer
+			inx := 0
+			if method == ValuationLIFO {
+				inx = len(layers) - 1
+			}
+			l := &layers[inx]
+
+			took := need
+			if l.quantity < took {
+				took = l.quantity
+			}
+			dc.Cost = dc.Cost.Add(l.unitCost.Mul(decimal.NewFromFloat(took)))
+			l.quantity -= took
+			need -= took
+
+			if l.quantity <= stockEpsilon {
+				layers = append(layers[:inx], layers[inx+1:]...)
+			}
+		}
+		if need > stockEpsilon {
+			dc.Uncosted = need
+		}
+		dc.Cost = dc.Cost.Round(2)
+		costs = append(costs, dc)
+	}
+
+	value = decimal.Zero
+	for _, l := range layers {
+		left += l.quantity
+		value = value.Add(l.unitCost.Mul(decimal.NewFromFloat(l.quantity)))
+	}
+
+	return left, value.Round(2), costs, nil
+}
+
+// StockValue is what is left in stock of an entry type of a company at a date
+type StockValue struct {
+	CompanyID   int             `json:"company_id"`
+	EntryTypeID int             `json:"entry_type_id"`
+	Method      string          `json:"method"`
+	AsOf        time.Time       `json:"as_of"`
+	Quantity    float64         `json:"quantity"`
+	Value       decimal.Decimal `json:"value"`
+}
+
+type StockValueFilter struct {
+	CompanyID   *int         `json:"company_id"`
+	EntryTypeID *int         `json:"entry_type_id"`
+	AsOf        *PartialTime `json:"as_of,omitempty"`
+	Method      string       `json:"method"`
+
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// Validate defaults to fifo
+func (f *StockValueFilter) Validate() error {
+	if f.Method == "" {
+		f.Method = ValuationFIFO
+	}
+	return validValuation(f.Method)
+}
+
+// DeedCost is the material cost of a deed, the sum of its drains
+type DeedCost struct {
+	DeedID   int             `json:"deed_id"`
+	Method   string          `json:"method"`
+	Cost     decimal.Decimal `json:"cost"`
+	Uncosted float64         `json:"uncosted,omitempty"`
+	Drains   []*DrainCost    `json:"drains"`
+}
+
+type DeedCostFilter struct {
+	Method string `json:"method"`
+}
+
+// Validate defaults to fifo
+func (f *DeedCostFilter) Validate() error {
+	if f.Method == "" {
+		f.Method = ValuationFIFO
+	}
+	return validValuation(f.Method)
+}
+
+type ValuationService interface {
+	// FindStockValue values the stock left per company and entry type
+	FindStockValue(context.Context, StockValueFilter) ([]*StockValue, int, error)
+	FindDeedCost(context.Context, int, DeedCostFilter) (*DeedCost, error)
+}
@@ -0,0 +1,87 @@
+package dots
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestValuate(t *testing.T) {
+	t0 := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	t1, t2 := t0.Add(time.Hour), t0.Add(2*time.Hour)
+	entry := func(at time.Time, id int, qty float64, cost string) StockMove {
+		return StockMove{At: at, EntryID: id, Quantity: qty, UnitCost: decimal.RequireFromString(cost)}
+	}
+	drain := func(at time.Time, id, deed int, qty float64) StockMove {
+		return StockMove{At: at, EntryID: id, DeedID: deed, Quantity: qty}
+	}
+	twoLayers := []StockMove{
+		drain(t2, 1, 7, 15),
+		entry(t0, 1, 10, "2"),
+		entry(t1, 2, 10, "3"),
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		moves    []StockMove
+		left     float64
+		value    string
+		cost     []string
+		uncosted []float64
+	}{
+		{"fifo takes the oldest", ValuationFIFO, twoLayers, 5, "15", []string{"35"}, []float64{0}},
+		{"lifo takes the newest", ValuationLIFO, twoLayers, 5, "10", []string{"40"}, []float64{0}},
+		{"average blends", ValuationAverage, twoLayers, 5, "12.5", []string{"37.5"}, []float64{0}},
+		{
+			"drain beyond stock is uncosted", ValuationFIFO,
+			[]StockMove{entry(t0, 1, 5, "2"), drain(t1, 1, 7, 8)},
+			0, "0", []string{"10"}, []float64{3},
+		},
+		{
+			"drain after stock is gone is all uncosted", ValuationLIFO,
+			[]StockMove{entry(t0, 1, 2, "2"), drain(t1, 1, 7, 2), drain(t2, 1, 8, 1)},
+			0, "0", []string{"4", "0"}, []float64{0, 1},
+		},
+		{
+			"entry goes before a drain at the same time", ValuationFIFO,
+			[]StockMove{drain(t0, 1, 7, 4), entry(t0, 1, 4, "1.5")},
+			0, "0", []string{"6"}, []float64{0},
+		},
+		{
+			"entries at the same time go by id", ValuationFIFO,
+			[]StockMove{entry(t0, 2, 1, "5"), entry(t0, 1, 1, "1"), drain(t0, 1, 7, 1)},
+			1, "5", []string{"1"}, []float64{0},
+		},
+		{
+			"drains at the same time go by deed", ValuationFIFO,
+			[]StockMove{entry(t0, 1, 1, "1"), entry(t0, 2, 1, "5"), drain(t1, 1, 8, 1), drain(t1, 1, 7, 1)},
+			0, "0", []string{"1", "5"}, []float64{0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			left, value, costs, err := Valuate(tt.method, tt.moves)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if left != tt.left || !value.Equal(decimal.RequireFromString(tt.value)) {
+				t.Fatalf("left=%v value=%v, want %v %v", left, value, tt.left, tt.value)
+			}
+			if len(costs) != len(tt.cost) {
+				t.Fatalf("costs=%d, want %d", len(costs), len(tt.cost))
+			}
+			for i, c := range costs {
+				if !c.Cost.Equal(decimal.RequireFromString(tt.cost[i])) || c.Uncosted != tt.uncosted[i] {
+					t.Fatalf("cost[%d]=%+v, want %v uncosted %v", i, c, tt.cost[i], tt.uncosted[i])
+				}
+			}
+		})
+	}
+
+	if _, _, _, err := Valuate("newest", twoLayers); ErrorCode(err) != EINVALID {
+		t.Fatalf("err=%v, want %s", err, EINVALID)
+	}
+}